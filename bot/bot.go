@@ -1,17 +1,44 @@
 package bot
 
 import (
+	"net/http"
 	"os"
 	"time"
 
 	"github.com/botaas/telegram-bot-connector/models"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	log "github.com/sirupsen/logrus"
 )
 
+// DefaultAllowedUpdates are the update types handled by the connector,
+// chat_member updates are only delivered when explicitly allowed.
+var DefaultAllowedUpdates = []string{
+	"message",
+	"edited_message",
+	"channel_post",
+	"edited_channel_post",
+	"inline_query",
+	"chosen_inline_result",
+	"callback_query",
+	"shipping_query",
+	"pre_checkout_query",
+	"my_chat_member",
+	"chat_member",
+	"chat_join_request",
+	"poll",
+	"poll_answer",
+}
+
 type Bot struct {
 	api          *tgbotapi.BotAPI
 	editInterval time.Duration
 	Self         *models.User
+
+	sent *sentExtras
+
+	server      *http.Server
+	webhookChan chan Update
+	stopPolling chan struct{}
 }
 
 func New(token string, editInterval time.Duration) (*Bot, error) {
@@ -27,6 +54,9 @@ func New(token string, editInterval time.Duration) (*Bot, error) {
 		return nil, err
 	}
 
+	sent := &sentExtras{extras: map[sentKey]*MessageExtras{}}
+	api.Client = &extrasClient{client: api.Client, sent: sent}
+
 	return &Bot{
 		Self: &models.User{
 			ID:                      api.Self.ID,
@@ -41,19 +71,43 @@ func New(token string, editInterval time.Duration) (*Bot, error) {
 		},
 		api:          api,
 		editInterval: editInterval,
+		sent:         sent,
 	}, nil
 }
 
-func (b *Bot) GetUpdatesChan() tgbotapi.UpdatesChannel {
-	cfg := tgbotapi.NewUpdate(0)
-	cfg.Timeout = 30
-	return b.api.GetUpdatesChan(cfg)
+// GetUpdatesChan polls the updates of the allowedUpdates types,
+// DefaultAllowedUpdates when empty.
+func (b *Bot) GetUpdatesChan(allowedUpdates []string) UpdatesChannel {
+	// getUpdates is refused while a webhook is set
+	_, err := b.api.Request(tgbotapi.DeleteWebhookConfig{})
+	if err != nil {
+		log.Errorf("delete webhook error: %v", err)
+	}
+
+	if len(allowedUpdates) == 0 {
+		allowedUpdates = DefaultAllowedUpdates
+	}
+
+	b.stopPolling = make(chan struct{})
+	return b.pollUpdates(allowedUpdates, b.stopPolling)
 }
 
 func (b *Bot) Stop() {
-	b.api.StopReceivingUpdates()
+	if b.server != nil {
+		b.stopWebhook()
+		return
+	}
+
+	if b.stopPolling != nil {
+		close(b.stopPolling)
+	}
 }
 
 func (b *Bot) API() *tgbotapi.BotAPI {
 	return b.api
 }
+
+// EditInterval is the minimum interval between two edits of a message.
+func (b *Bot) EditInterval() time.Duration {
+	return b.editInterval
+}
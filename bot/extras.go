@@ -0,0 +1,134 @@
+package bot
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// maxSentExtras bounds the extras kept for sent messages nobody asked for,
+// e.g. the edits of streams outside of an outbox event.
+const maxSentExtras = 1024
+
+type sentKey struct {
+	chatID    int64
+	messageID int
+}
+
+// sentExtras are the extras of the messages the bot sent or edited, decoded
+// from the responses of the Bot API as tgbotapi drops them.
+type sentExtras struct {
+	mu     sync.Mutex
+	extras map[sentKey]*MessageExtras
+	// order of the keys, oldest first
+	order []sentKey
+}
+
+func (s *sentExtras) add(key sentKey, e *MessageExtras) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exist := s.extras[key]; !exist {
+		s.order = append(s.order, key)
+	}
+	s.extras[key] = e
+
+	for len(s.order) > maxSentExtras {
+		delete(s.extras, s.order[0])
+		s.order = s.order[1:]
+	}
+}
+
+func (s *sentExtras) take(key sentKey) *MessageExtras {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, exist := s.extras[key]
+	if !exist {
+		return nil
+	}
+	delete(s.extras, key)
+	for i, k := range s.order {
+		if k == key {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+	return e
+}
+
+// SentMessageExtras returns the extras of m, a message the bot sent or
+// edited. They are returned once, later calls return empty extras.
+func (b *Bot) SentMessageExtras(m *tgbotapi.Message) *MessageExtras {
+	if m.Chat != nil {
+		if e := b.sent.take(sentKey{chatID: m.Chat.ID, messageID: m.MessageID}); e != nil {
+			return e
+		}
+	}
+	return &MessageExtras{}
+}
+
+// extrasClient records the extras of the messages in the results of the
+// requests into sent.
+type extrasClient struct {
+	client tgbotapi.HTTPClient
+	sent   *sentExtras
+}
+
+func (c *extrasClient) Do(req *http.Request) (*http.Response, error) {
+	resp, err := c.client.Do(req)
+	// the updates carry their extras themselves
+	if err != nil || strings.HasSuffix(req.URL.Path, "/getUpdates") {
+		return resp, err
+	}
+
+	b, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(b))
+
+	var r struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if json.Unmarshal(b, &r) != nil {
+		return resp, nil
+	}
+
+	// sendMediaGroup results in a list of messages
+	results := []json.RawMessage{r.Result}
+	if bytes.HasPrefix(r.Result, []byte("[")) {
+		results = nil
+		_ = json.Unmarshal(r.Result, &results)
+	}
+	for _, result := range results {
+		c.record(result)
+	}
+
+	return resp, nil
+}
+
+func (c *extrasClient) record(result json.RawMessage) {
+	var m struct {
+		MessageID int `json:"message_id"`
+		Chat      struct {
+			ID int64 `json:"id"`
+		} `json:"chat"`
+	}
+	var e MessageExtras
+	if json.Unmarshal(result, &m) != nil || json.Unmarshal(result, &e) != nil {
+		return
+	}
+
+	// most messages have no extras, they are not kept
+	if m.MessageID == 0 || e == (MessageExtras{}) {
+		return
+	}
+	c.sent.add(sentKey{chatID: m.Chat.ID, messageID: m.MessageID}, &e)
+}
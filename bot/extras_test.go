@@ -0,0 +1,37 @@
+package bot
+
+import (
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestSentMessageExtrasAreReturnedOnce(t *testing.T) {
+	b := &Bot{sent: &sentExtras{extras: map[sentKey]*MessageExtras{}}}
+	b.sent.add(sentKey{chatID: 42, messageID: 7}, &MessageExtras{MessageThreadID: 5})
+
+	m := &tgbotapi.Message{MessageID: 7, Chat: &tgbotapi.Chat{ID: 42}}
+	if e := b.SentMessageExtras(m); e.MessageThreadID != 5 {
+		t.Errorf("extras thread = %d, want 5", e.MessageThreadID)
+	}
+	if e := b.SentMessageExtras(m); e.MessageThreadID != 0 {
+		t.Errorf("extras thread = %d the second time, want none", e.MessageThreadID)
+	}
+}
+
+func TestSentExtrasForgetTheOldestMessages(t *testing.T) {
+	s := &sentExtras{extras: map[sentKey]*MessageExtras{}}
+	for i := 1; i <= maxSentExtras+1; i++ {
+		s.add(sentKey{chatID: 42, messageID: i}, &MessageExtras{MessageThreadID: 5})
+	}
+
+	if len(s.extras) != maxSentExtras {
+		t.Errorf("kept the extras of %d messages, want %d", len(s.extras), maxSentExtras)
+	}
+	if s.take(sentKey{chatID: 42, messageID: 1}) != nil {
+		t.Error("kept the extras of the oldest message")
+	}
+	if s.take(sentKey{chatID: 42, messageID: maxSentExtras + 1}) == nil {
+		t.Error("forgot the extras of the newest message")
+	}
+}
@@ -0,0 +1,144 @@
+package bot
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// APIWithParams returns the API of the bot adding params to every request,
+// for the parameters tgbotapi configs do not support yet such as
+// message_thread_id.
+func (b *Bot) APIWithParams(params tgbotapi.Params) *tgbotapi.BotAPI {
+	if len(params) == 0 {
+		return b.api
+	}
+
+	api := *b.api
+	api.Client = &paramsClient{
+		client: b.api.Client,
+		params: params,
+	}
+	return &api
+}
+
+// ThreadAPI returns the API of the bot sending messages to the forum topic
+// messageThreadID, the API itself when 0.
+func (b *Bot) ThreadAPI(messageThreadID int) *tgbotapi.BotAPI {
+	if messageThreadID == 0 {
+		return b.api
+	}
+
+	return b.APIWithParams(tgbotapi.Params{"message_thread_id": strconv.Itoa(messageThreadID)})
+}
+
+// paramsClient adds params to the form of the requests, urlencoded or
+// multipart alike, unless they are already set.
+type paramsClient struct {
+	client tgbotapi.HTTPClient
+	params tgbotapi.Params
+}
+
+func (c *paramsClient) Do(req *http.Request) (*http.Response, error) {
+	mediaType, mediaParams, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil {
+		return c.client.Do(req)
+	}
+
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		b, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		values, err := url.ParseQuery(string(b))
+		if err != nil {
+			return nil, err
+		}
+
+		for k, v := range c.params {
+			if !values.Has(k) {
+				values.Set(k, v)
+			}
+		}
+
+		body := values.Encode()
+		req.Body = io.NopCloser(strings.NewReader(body))
+		req.ContentLength = int64(len(body))
+	case "multipart/form-data":
+		// the form is streamed by tgbotapi, its parts are copied as they come
+		// and the params missing from it appended
+		pr, pw := io.Pipe()
+		go func(body io.Reader) {
+			pw.CloseWithError(c.addMultipartParams(pw, body, mediaParams["boundary"]))
+		}(req.Body)
+
+		// the length of the copied form is unknown
+		req.ContentLength = -1
+		req.Body = &pipedBody{PipeReader: pr, body: req.Body}
+	}
+
+	return c.client.Do(req)
+}
+
+// addMultipartParams copies the multipart form body to w, appending the
+// params it does not set.
+func (c *paramsClient) addMultipartParams(w io.Writer, body io.Reader, boundary string) error {
+	mr := multipart.NewReader(body, boundary)
+	mw := multipart.NewWriter(w)
+	err := mw.SetBoundary(boundary)
+	if err != nil {
+		return err
+	}
+
+	set := map[string]bool{}
+	for {
+		part, err := mr.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		set[part.FormName()] = true
+
+		pw, err := mw.CreatePart(part.Header)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(pw, part)
+		if err != nil {
+			return err
+		}
+	}
+
+	for k, v := range c.params {
+		if !set[k] {
+			err = mw.WriteField(k, v)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return mw.Close()
+}
+
+// pipedBody is a request body copied through a pipe, closing it closes the
+// pipe so that the copy stops, and the original body.
+type pipedBody struct {
+	*io.PipeReader
+	body io.Closer
+}
+
+func (b *pipedBody) Close() error {
+	b.PipeReader.Close()
+	return b.body.Close()
+}
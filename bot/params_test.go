@@ -0,0 +1,115 @@
+package bot
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// recordingClient keeps the request it is given.
+type recordingClient struct {
+	req *http.Request
+}
+
+func (c *recordingClient) Do(req *http.Request) (*http.Response, error) {
+	c.req = req
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"ok":true}`))}, nil
+}
+
+func TestParamsClientAddsParamsToUrlencodedForms(t *testing.T) {
+	rc := &recordingClient{}
+	c := &paramsClient{client: rc, params: tgbotapi.Params{"message_thread_id": "7", "chat_id": "1"}}
+
+	body := url.Values{"chat_id": {"42"}, "text": {"hello"}}.Encode()
+	req, err := http.NewRequest(http.MethodPost, "http://example.com/sendMessage", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	_, err = c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = rc.req.ParseForm()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rc.req.PostForm.Get("message_thread_id"); got != "7" {
+		t.Errorf("message_thread_id = %q, want 7", got)
+	}
+	// params already in the form are kept
+	if got := rc.req.PostForm.Get("chat_id"); got != "42" {
+		t.Errorf("chat_id = %q, want 42", got)
+	}
+	if got := rc.req.PostForm.Get("text"); got != "hello" {
+		t.Errorf("text = %q, want hello", got)
+	}
+}
+
+func TestParamsClientAddsParamsToMultipartForms(t *testing.T) {
+	rc := &recordingClient{}
+	c := &paramsClient{client: rc, params: tgbotapi.Params{"message_thread_id": "7", "chat_id": "1"}}
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	_ = w.WriteField("chat_id", "42")
+	part, _ := w.CreateFormFile("photo", "photo.jpg")
+	_, _ = part.Write([]byte("jpeg"))
+	_ = w.Close()
+
+	req, err := http.NewRequest(http.MethodPost, "http://example.com/sendPhoto", bytes.NewReader(body.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+
+	_, err = c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sent, err := io.ReadAll(rc.req.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the copied form is sent chunked
+	if rc.req.ContentLength != -1 {
+		t.Errorf("content length = %d, want unknown", rc.req.ContentLength)
+	}
+
+	rc.req.Body = io.NopCloser(bytes.NewReader(sent))
+	err = rc.req.ParseMultipartForm(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rc.req.FormValue("message_thread_id"); got != "7" {
+		t.Errorf("message_thread_id = %q, want 7", got)
+	}
+	// params already in the form are kept, not sent twice
+	if got := rc.req.MultipartForm.Value["chat_id"]; len(got) != 1 || got[0] != "42" {
+		t.Errorf("chat_id = %q, want 42 only", got)
+	}
+	if files := rc.req.MultipartForm.File["photo"]; len(files) != 1 || files[0].Filename != "photo.jpg" {
+		t.Errorf("photo = %v, want the uploaded file", files)
+	}
+}
+
+func TestThreadAPIWithoutThreadIsTheBotAPI(t *testing.T) {
+	api := &tgbotapi.BotAPI{Client: &recordingClient{}}
+	b := &Bot{api: api}
+
+	if b.ThreadAPI(0) != api {
+		t.Error("ThreadAPI(0) is not the API of the bot")
+	}
+	if threadAPI := b.ThreadAPI(7); threadAPI == api || threadAPI.Client == api.Client {
+		t.Error("ThreadAPI(7) does not add the thread to the requests")
+	}
+}
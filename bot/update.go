@@ -0,0 +1,119 @@
+package bot
+
+import (
+	"encoding/json"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	log "github.com/sirupsen/logrus"
+)
+
+// MessageExtras are the fields of a message tgbotapi does not decode.
+type MessageExtras struct {
+	// MessageThreadID forum topic the message belongs to
+	MessageThreadID int  `json:"message_thread_id,omitempty"`
+	IsTopicMessage  bool `json:"is_topic_message,omitempty"`
+	Chat            struct {
+		IsForum bool `json:"is_forum,omitempty"`
+	} `json:"chat"`
+}
+
+// Update is a tgbotapi.Update along with the extras of its messages.
+type Update struct {
+	tgbotapi.Update
+
+	extras map[*tgbotapi.Message]*MessageExtras
+}
+
+type UpdatesChannel <-chan Update
+
+func (u *Update) UnmarshalJSON(b []byte) error {
+	err := json.Unmarshal(b, &u.Update)
+	if err != nil {
+		return err
+	}
+
+	var extras struct {
+		Message           *MessageExtras `json:"message"`
+		EditedMessage     *MessageExtras `json:"edited_message"`
+		ChannelPost       *MessageExtras `json:"channel_post"`
+		EditedChannelPost *MessageExtras `json:"edited_channel_post"`
+		CallbackQuery     *struct {
+			Message *MessageExtras `json:"message"`
+		} `json:"callback_query"`
+	}
+	err = json.Unmarshal(b, &extras)
+	if err != nil {
+		return err
+	}
+
+	u.extras = map[*tgbotapi.Message]*MessageExtras{}
+	add := func(m *tgbotapi.Message, e *MessageExtras) {
+		if m != nil && e != nil {
+			u.extras[m] = e
+		}
+	}
+	add(u.Message, extras.Message)
+	add(u.EditedMessage, extras.EditedMessage)
+	add(u.ChannelPost, extras.ChannelPost)
+	add(u.EditedChannelPost, extras.EditedChannelPost)
+	if u.CallbackQuery != nil && extras.CallbackQuery != nil {
+		add(u.CallbackQuery.Message, extras.CallbackQuery.Message)
+	}
+
+	return nil
+}
+
+// MessageExtras returns the extras of m, a message of the update.
+func (u *Update) MessageExtras(m *tgbotapi.Message) *MessageExtras {
+	if e, exist := u.extras[m]; exist {
+		return e
+	}
+	return &MessageExtras{}
+}
+
+// pollUpdates long polls getUpdates until stop is closed, it decodes the
+// updates itself so that they carry their extras.
+func (b *Bot) pollUpdates(allowedUpdates []string, stop <-chan struct{}) UpdatesChannel {
+	ch := make(chan Update, b.api.Buffer)
+
+	go func() {
+		offset := 0
+		for {
+			select {
+			case <-stop:
+				close(ch)
+				return
+			default:
+			}
+
+			params := tgbotapi.Params{}
+			params.AddNonZero("offset", offset)
+			params.AddNonZero("timeout", 30)
+			params.AddInterface("allowed_updates", allowedUpdates)
+
+			var updates []Update
+			resp, err := b.api.MakeRequest("getUpdates", params)
+			if err == nil {
+				err = json.Unmarshal(resp.Result, &updates)
+			}
+			if err != nil {
+				log.Errorf("Failed to get updates, retrying in 3 seconds: %v", err)
+				select {
+				case <-stop:
+				case <-time.After(3 * time.Second):
+				}
+				continue
+			}
+
+			for _, update := range updates {
+				if update.UpdateID >= offset {
+					offset = update.UpdateID + 1
+					ch <- update
+				}
+			}
+		}
+	}()
+
+	return ch
+}
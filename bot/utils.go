@@ -1,6 +1,9 @@
 package bot
 
 import (
+	"html"
+	"strings"
+
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
@@ -9,29 +12,75 @@ const (
 	HTMLFormat  = "HTML"
 	HTMLNick    = "htmlnick"
 	MarkdownV2  = "MarkdownV2"
+	Markdown    = "Markdown"
+)
+
+// markdownV2Replacer escapes every character MarkdownV2 reserves, the
+// backslash included.
+var markdownV2Replacer = strings.NewReplacer(
+	"\\", "\\\\", "_", "\\_", "*", "\\*", "[", "\\[", "]", "\\]", "(", "\\(",
+	")", "\\)", "~", "\\~", "`", "\\`", ">", "\\>", "#", "\\#", "+", "\\+",
+	"-", "\\-", "=", "\\=", "|", "\\|", "{", "\\{", "}", "\\}", ".", "\\.",
+	"!", "\\!",
+)
+
+// EscapeMarkdownV2 escapes text so that it shows as is in a MarkdownV2
+// message.
+func EscapeMarkdownV2(text string) string {
+	return markdownV2Replacer.Replace(text)
+}
+
+// markdownReplacer escapes the entity characters of the legacy Markdown.
+var markdownReplacer = strings.NewReplacer(
+	"_", "\\_", "*", "\\*", "`", "\\`", "[", "\\[",
 )
 
-func TGGetParseMode(bot *tgbotapi.BotAPI, username string, text string) (textout string, parsemode string) {
+// EscapeMarkdown escapes text so that it shows as is in a Markdown message.
+func EscapeMarkdown(text string) string {
+	return markdownReplacer.Replace(text)
+}
+
+// EscapeHTML escapes text so that it shows as is in an HTML message.
+func EscapeHTML(text string) string {
+	return html.EscapeString(text)
+}
+
+// IsMessageFormat reports whether format is a known message format, the
+// empty format sends plain text.
+func IsMessageFormat(format string) bool {
+	switch format {
+	case "", HTMLFormat, MarkdownV2, Markdown:
+		return true
+	}
+	return strings.ToLower(format) == HTMLNick
+}
+
+// TGGetParseMode returns the text to send and its parse mode for the message
+// format. text is escaped so that it shows as is in any format, username is
+// markup of the format, e.g. the HTML of the htmlnick format. Texts carrying
+// markup set their parse mode themselves.
+func TGGetParseMode(format string, username string, text string) (textout string, parsemode string) {
 	textout = username + text
 
-	/*
-		if bot.GetString("MessageFormat") == HTMLFormat {
-			parsemode = tgbotapi.ModeHTML
-		}
+	if format == HTMLFormat {
+		textout = username + EscapeHTML(text)
+		parsemode = tgbotapi.ModeHTML
+	}
 
-		if b.GetString("MessageFormat") == "Markdown" {
-			parsemode = tgbotapi.ModeMarkdown
-		}
+	if format == Markdown {
+		textout = username + EscapeMarkdown(text)
+		parsemode = tgbotapi.ModeMarkdown
+	}
 
-		if b.GetString("MessageFormat") == MarkdownV2 {
-			parsemode = MarkdownV2
-		}
+	if format == MarkdownV2 {
+		textout = username + EscapeMarkdownV2(text)
+		parsemode = tgbotapi.ModeMarkdownV2
+	}
 
-		if strings.ToLower(b.GetString("MessageFormat")) == HTMLNick {
-			textout = username + html.EscapeString(text)
-			parsemode = tgbotapi.ModeHTML
-		}
-	*/
+	if strings.ToLower(format) == HTMLNick {
+		textout = username + EscapeHTML(text)
+		parsemode = tgbotapi.ModeHTML
+	}
 
 	return textout, parsemode
 }
@@ -0,0 +1,37 @@
+package bot
+
+import "testing"
+
+func TestTGGetParseModeEscapesTheText(t *testing.T) {
+	text := "1.5 * 2 = 3! <b>_x_</b> [a](b)"
+
+	for _, tt := range []struct {
+		format    string
+		text      string
+		parseMode string
+	}{
+		{"", text, ""},
+		{HTMLFormat, "1.5 * 2 = 3! &lt;b&gt;_x_&lt;/b&gt; [a](b)", "HTML"},
+		{HTMLNick, "1.5 * 2 = 3! &lt;b&gt;_x_&lt;/b&gt; [a](b)", "HTML"},
+		{MarkdownV2, `1\.5 \* 2 \= 3\! <b\>\_x\_</b\> \[a\]\(b\)`, "MarkdownV2"},
+		{Markdown, `1.5 \* 2 = 3! <b>\_x\_</b> \[a](b)`, "Markdown"},
+	} {
+		got, parseMode := TGGetParseMode(tt.format, "", text)
+		if got != tt.text || parseMode != tt.parseMode {
+			t.Errorf("format %q: got %q in %q, want %q in %q", tt.format, got, parseMode, tt.text, tt.parseMode)
+		}
+	}
+}
+
+func TestTGGetParseModeKeepsTheUsernameMarkup(t *testing.T) {
+	got, parseMode := TGGetParseMode(HTMLNick, "<b>bob</b>: ", "a < b")
+	if got != "<b>bob</b>: a &lt; b" || parseMode != "HTML" {
+		t.Errorf("got %q in %q, want the username as HTML and the escaped text", got, parseMode)
+	}
+}
+
+func TestEscapeMarkdownV2EscapesTheBackslash(t *testing.T) {
+	if got := EscapeMarkdownV2(`C:\dir`); got != `C:\\dir` {
+		t.Errorf("EscapeMarkdownV2 = %q, want the backslash escaped", got)
+	}
+}
@@ -0,0 +1,149 @@
+package bot
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	log "github.com/sirupsen/logrus"
+)
+
+const secretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+type WebhookConfig struct {
+	// URL is the public HTTPS URL Telegram posts updates to, its path is
+	// also the path served by the embedded server.
+	URL string
+	// ListenAddr is the address of the embedded HTTP server.
+	ListenAddr string
+	// SecretToken is sent back by Telegram in the X-Telegram-Bot-Api-Secret-Token
+	// header of every update, requests without it are rejected.
+	//
+	// optional
+	SecretToken string
+	// MaxConnections maximum allowed number of simultaneous HTTPS connections
+	// to the webhook for update delivery, 1-100.
+	//
+	// optional
+	MaxConnections int
+	// AllowedUpdates list of update types to receive, DefaultAllowedUpdates
+	// when empty.
+	//
+	// optional
+	AllowedUpdates []string
+	// CertFile is the public key certificate uploaded to Telegram so that a
+	// self-signed certificate can be checked. With KeyFile it is also used
+	// to serve TLS from the embedded server.
+	//
+	// optional
+	CertFile string
+	// KeyFile is the private key of CertFile.
+	//
+	// optional
+	KeyFile string
+}
+
+// ListenForWebhook registers the webhook and starts an HTTP server receiving
+// the updates Telegram pushes to it.
+func (b *Bot) ListenForWebhook(cfg WebhookConfig) (UpdatesChannel, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	// listen first so that Telegram is never pointed to a server that failed to start
+	ln, err := net.Listen("tcp", cfg.ListenAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	params := tgbotapi.Params{"url": u.String()}
+	params.AddNonEmpty("secret_token", cfg.SecretToken)
+	params.AddNonZero("max_connections", cfg.MaxConnections)
+	allowedUpdates := cfg.AllowedUpdates
+	if len(allowedUpdates) == 0 {
+		allowedUpdates = DefaultAllowedUpdates
+	}
+	err = params.AddInterface("allowed_updates", allowedUpdates)
+	if err != nil {
+		ln.Close()
+		return nil, err
+	}
+
+	if len(cfg.CertFile) > 0 {
+		_, err = b.api.UploadFiles("setWebhook", params, []tgbotapi.RequestFile{{
+			Name: "certificate",
+			Data: tgbotapi.FilePath(cfg.CertFile),
+		}})
+	} else {
+		_, err = b.api.MakeRequest("setWebhook", params)
+	}
+	if err != nil {
+		ln.Close()
+		return nil, err
+	}
+
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+
+	ch := make(chan Update, b.api.Buffer)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		if len(cfg.SecretToken) > 0 {
+			token := r.Header.Get(secretTokenHeader)
+			if subtle.ConstantTimeCompare([]byte(token), []byte(cfg.SecretToken)) != 1 {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		}
+
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		var update Update
+		err := json.NewDecoder(r.Body).Decode(&update)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ch <- update
+	})
+
+	b.server = &http.Server{
+		Handler: mux,
+	}
+	b.webhookChan = ch
+
+	go func() {
+		var err error
+		if len(cfg.CertFile) > 0 && len(cfg.KeyFile) > 0 {
+			err = b.server.ServeTLS(ln, cfg.CertFile, cfg.KeyFile)
+		} else {
+			err = b.server.Serve(ln)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Errorf("webhook server error: %v", err)
+		}
+	}()
+
+	return ch, nil
+}
+
+func (b *Bot) stopWebhook() {
+	// Shutdown waits for in-flight handlers, nothing sends on the channel afterwards
+	err := b.server.Shutdown(context.Background())
+	if err != nil {
+		log.Errorf("webhook server shutdown error: %v", err)
+	}
+	close(b.webhookChan)
+}
@@ -0,0 +1,216 @@
+package bot
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeAPI is a Bot API server recording the calls it receives.
+type fakeAPI struct {
+	mu    sync.Mutex
+	calls map[string][]url.Values
+}
+
+func (f *fakeAPI) serve(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseMultipartForm(1 << 20)
+	method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+
+	f.mu.Lock()
+	f.calls[method] = append(f.calls[method], r.Form)
+	f.mu.Unlock()
+
+	var result any = true
+	switch method {
+	case "getMe":
+		result = map[string]any{"id": 1, "is_bot": true, "username": "test_bot"}
+	case "getUpdates":
+		// keep polling slow
+		time.Sleep(10 * time.Millisecond)
+		result = []any{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": result})
+}
+
+func (f *fakeAPI) Calls(method string) []url.Values {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.calls[method]
+}
+
+func newTestBot(t *testing.T) (*fakeAPI, *Bot) {
+	t.Helper()
+
+	f := &fakeAPI{calls: map[string][]url.Values{}}
+	srv := httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(srv.Close)
+
+	t.Setenv("TELEGRAM_API_ENDPOINT", srv.URL+"/bot%s/%s")
+	b, err := New("token", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return f, b
+}
+
+func allowsDefaultUpdates(allowedUpdates string) bool {
+	var types []string
+	err := json.Unmarshal([]byte(allowedUpdates), &types)
+	if err != nil || len(types) != len(DefaultAllowedUpdates) {
+		return false
+	}
+	for i := range types {
+		if types[i] != DefaultAllowedUpdates[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// freeAddr returns a local address nothing listens on.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+func postUpdate(t *testing.T, method string, url string, secretToken string) int {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, strings.NewReader(`{
+		"update_id": 1,
+		"message": {"message_id": 7, "date": 1700000000, "chat": {"id": 42, "type": "private"}, "text": "hi"}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secretToken != "" {
+		req.Header.Set(secretTokenHeader, secretToken)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestListenForWebhook(t *testing.T) {
+	f, b := newTestBot(t)
+	addr := freeAddr(t)
+
+	updates, err := b.ListenForWebhook(WebhookConfig{
+		URL:         "https://example.com/telegram/hook",
+		ListenAddr:  addr,
+		SecretToken: "secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := f.Calls("setWebhook")
+	if len(calls) != 1 {
+		t.Fatalf("setWebhook called %d times, want 1", len(calls))
+	}
+	if calls[0].Get("url") != "https://example.com/telegram/hook" || calls[0].Get("secret_token") != "secret" {
+		t.Errorf("setWebhook params = %v, want the url and the secret token", calls[0])
+	}
+	if got := calls[0].Get("allowed_updates"); !allowsDefaultUpdates(got) {
+		t.Errorf("setWebhook allowed_updates = %s, want the defaults", got)
+	}
+
+	url := "http://" + addr + "/telegram/hook"
+	if code := postUpdate(t, http.MethodPost, url, ""); code != http.StatusUnauthorized {
+		t.Errorf("update without secret token answered %d, want 401", code)
+	}
+	if code := postUpdate(t, http.MethodPost, url, "wrong"); code != http.StatusUnauthorized {
+		t.Errorf("update with a wrong secret token answered %d, want 401", code)
+	}
+	if code := postUpdate(t, http.MethodGet, url, "secret"); code != http.StatusMethodNotAllowed {
+		t.Errorf("GET answered %d, want 405", code)
+	}
+	if code := postUpdate(t, http.MethodPost, url, "secret"); code != http.StatusOK {
+		t.Fatalf("update answered %d, want 200", code)
+	}
+
+	select {
+	case update := <-updates:
+		if update.Message == nil || update.Message.Text != "hi" || update.Message.Chat.ID != 42 {
+			t.Errorf("received update %+v, want the posted message", update)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("posted update was not received")
+	}
+
+	// stopping closes the updates once the server shut down
+	b.Stop()
+	select {
+	case _, open := <-updates:
+		if open {
+			t.Error("received an update after Stop")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("updates not closed by Stop")
+	}
+}
+
+func TestListenForWebhookFailsWhenTheAddressIsTaken(t *testing.T) {
+	f, b := newTestBot(t)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	_, err = b.ListenForWebhook(WebhookConfig{
+		URL:        "https://example.com/hook",
+		ListenAddr: ln.Addr().String(),
+	})
+	if err == nil {
+		t.Fatal("ListenForWebhook succeeded on a taken address")
+	}
+	// Telegram is never pointed to a server that failed to start
+	if n := len(f.Calls("setWebhook")); n != 0 {
+		t.Errorf("setWebhook called %d times, want 0", n)
+	}
+}
+
+func TestPollingDeletesTheWebhook(t *testing.T) {
+	f, b := newTestBot(t)
+
+	b.GetUpdatesChan(nil)
+	defer b.Stop()
+
+	if n := len(f.Calls("deleteWebhook")); n != 1 {
+		t.Errorf("deleteWebhook called %d times, want 1 before polling", n)
+	}
+	deadline := time.Now().Add(time.Second)
+	for len(f.Calls("getUpdates")) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("getUpdates not called")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := f.Calls("getUpdates")[0].Get("allowed_updates"); !allowsDefaultUpdates(got) {
+		t.Errorf("getUpdates allowed_updates = %s, want the defaults", got)
+	}
+}
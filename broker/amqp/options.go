@@ -0,0 +1,61 @@
+package amqp
+
+import "time"
+
+type amqpOptions struct {
+	url            string
+	exchange       string
+	concurrency    int
+	reconnectDelay time.Duration
+	maxAttempts    int
+	retryDelay     time.Duration
+}
+
+type Option func(o *amqpOptions)
+
+func WithURL(url string) Option {
+	return func(o *amqpOptions) {
+		o.url = url
+	}
+}
+
+// WithExchange sets the durable direct exchange events are published to,
+// each channel is a durable queue bound to it with the channel name as
+// routing key.
+func WithExchange(exchange string) Option {
+	return func(o *amqpOptions) {
+		o.exchange = exchange
+	}
+}
+
+// WithConcurrency sets the prefetch count and the number of deliveries
+// processed at once by a subscription.
+func WithConcurrency(concurrency int) Option {
+	return func(o *amqpOptions) {
+		o.concurrency = concurrency
+	}
+}
+
+// WithReconnectDelay sets the first delay between reconnection attempts, it
+// doubles on each failed attempt.
+func WithReconnectDelay(delay time.Duration) Option {
+	return func(o *amqpOptions) {
+		o.reconnectDelay = delay
+	}
+}
+
+// WithMaxAttempts sets how many times a delivery is processed before it is
+// rejected to the dead letter exchange of its queue.
+func WithMaxAttempts(maxAttempts int) Option {
+	return func(o *amqpOptions) {
+		o.maxAttempts = maxAttempts
+	}
+}
+
+// WithRetryDelay sets the delay before a failed delivery is requeued, it
+// doubles on each attempt up to a minute.
+func WithRetryDelay(delay time.Duration) Option {
+	return func(o *amqpOptions) {
+		o.retryDelay = delay
+	}
+}
@@ -15,3 +15,10 @@ type Broker interface {
 	Publish(ctx context.Context, channel string, event *cloudevents.Event) error
 	Subscribe(ctx context.Context, channel string, fn Subscriber) (Unsubscriber, error)
 }
+
+// Acknowledger is implemented by brokers that acknowledge an event only when
+// the Subscriber returns nil and keep it for redelivery otherwise. Subscribers
+// of such a broker should not return before the event has been processed.
+type Acknowledger interface {
+	Acknowledges() bool
+}
@@ -0,0 +1,394 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	nethttp "net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/botaas/telegram-bot-connector/broker"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/binding"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/cloudevents/sdk-go/v2/types"
+)
+
+const (
+	signatureHeader = "X-Signature-256"
+	signaturePrefix = "sha256="
+	timestampHeader = "X-Signature-Timestamp"
+
+	// signatureTolerance is how old a signed request may be, older ones are
+	// rejected as replays
+	signatureTolerance = 5 * time.Minute
+
+	minBackoff = 500 * time.Millisecond
+	maxBackoff = 10 * time.Second
+)
+
+type unsubscriber struct {
+	h       *httpBroker
+	channel string
+}
+
+func (s *unsubscriber) Cancel() {
+	s.h.mu.Lock()
+	delete(s.h.subscribers, s.channel)
+
+	// the server stops with the last subscription
+	var server *nethttp.Server
+	if len(s.h.subscribers) == 0 {
+		server = s.h.server
+		s.h.server = nil
+	}
+	s.h.mu.Unlock()
+
+	if server != nil {
+		server.Close()
+	}
+}
+
+// httpBroker pushes published events to an HTTP endpoint and accepts the
+// events of the subscribed channels on its own HTTP server. An accepted event
+// is answered once processed, with the delivery receipt published meanwhile
+// if any.
+type httpBroker struct {
+	o      *httpOptions
+	client *nethttp.Client
+
+	mu          sync.Mutex
+	server      *nethttp.Server
+	subscribers map[string]broker.Subscriber
+	// receipts waits for the receipt correlated to an event being processed
+	receipts map[string]chan *cloudevents.Event
+}
+
+func New(opts ...Option) broker.Broker {
+	o := &httpOptions{
+		urls:        map[string]string{},
+		listenAddr:  "localhost:8090",
+		mode:        StructuredMode,
+		maxAttempts: 3,
+		timeout:     10 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if o.maxAttempts < 1 {
+		o.maxAttempts = 1
+	}
+
+	return &httpBroker{
+		o: o,
+		client: &nethttp.Client{
+			Timeout: o.timeout,
+		},
+		subscribers: map[string]broker.Subscriber{},
+		receipts:    map[string]chan *cloudevents.Event{},
+	}
+}
+
+func (h *httpBroker) Acknowledges() bool {
+	return true
+}
+
+func (h *httpBroker) withMode(ctx context.Context) context.Context {
+	if h.o.mode == BinaryMode {
+		return binding.WithForceBinary(ctx)
+	}
+	return binding.WithForceStructured(ctx)
+}
+
+// sign signs the timestamp, the CloudEvents attributes sent as headers and the
+// body, so that none of them can be altered and the request not be replayed
+// once the timestamp is too old.
+func (h *httpBroker) sign(timestamp string, header nethttp.Header, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(h.o.secret))
+	mac.Write([]byte(timestamp + "\n"))
+	for _, name := range signedHeaders(header) {
+		mac.Write([]byte(name + ":" + strings.Join(header.Values(name), ",") + "\n"))
+	}
+	mac.Write([]byte("\n"))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// signedHeaders returns the lower case names of the ce- headers, the event
+// attributes in binary mode, and of Content-Type, sorted.
+func signedHeaders(header nethttp.Header) []string {
+	var names []string
+	for name := range header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "ce-") || name == "content-type" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// verify checks the signature and the age of a request.
+func (h *httpBroker) verify(header nethttp.Header, body []byte) bool {
+	timestamp := header.Get(timestampHeader)
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	age := time.Since(time.Unix(unix, 0))
+	if age > signatureTolerance || age < -signatureTolerance {
+		return false
+	}
+
+	signature := header.Get(signatureHeader)
+	return hmac.Equal([]byte(signature), []byte(h.sign(timestamp, header, body)))
+}
+
+func (h *httpBroker) Publish(ctx context.Context, channel string, event *cloudevents.Event) error {
+	// the receipt of an event being accepted is its response
+	if correlationID, err := types.ToString(event.Extensions()["correlationid"]); err == nil {
+		h.mu.Lock()
+		ch, exist := h.receipts[correlationID]
+		h.mu.Unlock()
+		if exist {
+			select {
+			case ch <- event:
+			default:
+			}
+			return nil
+		}
+	}
+
+	// a channel without its own endpoint is refused rather than posted where
+	// its events would pass for those of another channel
+	url, exist := h.o.urls[channel]
+	if !exist {
+		return fmt.Errorf("no url to publish %s to", channel)
+	}
+
+	req, err := nethttp.NewRequestWithContext(ctx, nethttp.MethodPost, url, nil)
+	if err != nil {
+		return err
+	}
+
+	err = cehttp.WriteRequest(h.withMode(ctx), binding.ToMessage(event), req)
+	if err != nil {
+		return err
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return err
+	}
+
+	backoff := minBackoff
+	for attempts := 1; ; attempts++ {
+		err = h.post(req, body)
+		if err == nil || attempts >= h.o.maxAttempts {
+			return err
+		}
+
+		var statusErr *statusError
+		if errors.As(err, &statusErr) && !statusErr.retryable() {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+type statusError struct {
+	code int
+	body string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("http status %d: %s", e.code, e.body)
+}
+
+func (e *statusError) retryable() bool {
+	return e.code == nethttp.StatusTooManyRequests || e.code >= 500
+}
+
+func (h *httpBroker) post(req *nethttp.Request, body []byte) error {
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	if len(h.o.secret) > 0 {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(timestampHeader, timestamp)
+		req.Header.Set(signatureHeader, h.sign(timestamp, req.Header, body))
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &statusError{code: resp.StatusCode, body: string(b)}
+	}
+
+	return nil
+}
+
+func (h *httpBroker) Subscribe(ctx context.Context, channel string, fn broker.Subscriber) (broker.Unsubscriber, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.server == nil {
+		ln, err := net.Listen("tcp", h.o.listenAddr)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(h.o.secret) == 0 && !isLoopback(ln.Addr()) {
+			log.Warnf("http broker listens on %s without secret, anyone reaching it can send messages", ln.Addr())
+		}
+
+		// Cancel may clear h.server before the server starts
+		server := &nethttp.Server{
+			Handler: nethttp.HandlerFunc(h.serveHTTP),
+		}
+		h.server = server
+
+		go func() {
+			err := server.Serve(ln)
+			if err != nil && err != nethttp.ErrServerClosed {
+				log.Errorf("http broker server error: %v", err)
+			}
+		}()
+	}
+
+	h.subscribers[channel] = fn
+
+	u := &unsubscriber{
+		h:       h,
+		channel: channel,
+	}
+
+	go func() {
+		<-ctx.Done()
+		u.Cancel()
+	}()
+
+	return u, nil
+}
+
+func (h *httpBroker) serveHTTP(w nethttp.ResponseWriter, r *nethttp.Request) {
+	if r.Method != nethttp.MethodPost {
+		w.WriteHeader(nethttp.StatusMethodNotAllowed)
+		return
+	}
+
+	h.mu.Lock()
+	fn, exist := h.subscribers[r.URL.Path[1:]]
+	h.mu.Unlock()
+	if !exist {
+		nethttp.NotFound(w, r)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		nethttp.Error(w, err.Error(), nethttp.StatusBadRequest)
+		return
+	}
+
+	if len(h.o.secret) > 0 && !h.verify(r.Header, body) {
+		w.WriteHeader(nethttp.StatusUnauthorized)
+		return
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	ev, err := binding.ToEvent(r.Context(), cehttp.NewMessageFromHttpRequest(r))
+	if err != nil {
+		nethttp.Error(w, err.Error(), nethttp.StatusBadRequest)
+		return
+	}
+
+	receipts := make(chan *cloudevents.Event, 1)
+	h.mu.Lock()
+	h.receipts[ev.ID()] = receipts
+	h.mu.Unlock()
+
+	err = processOneEvent(ev, fn)
+
+	h.mu.Lock()
+	delete(h.receipts, ev.ID())
+	h.mu.Unlock()
+
+	var receipt *cloudevents.Event
+	select {
+	case receipt = <-receipts:
+	default:
+	}
+
+	status := nethttp.StatusOK
+	if err != nil {
+		// failed transiently, the sender may retry
+		status = nethttp.StatusServiceUnavailable
+	} else if receipt != nil && receipt.Type() == "message_failed" {
+		status = nethttp.StatusUnprocessableEntity
+	}
+
+	if receipt == nil {
+		if err != nil {
+			nethttp.Error(w, err.Error(), status)
+		} else {
+			w.WriteHeader(nethttp.StatusNoContent)
+		}
+		return
+	}
+
+	err = cehttp.WriteResponseWriter(h.withMode(r.Context()), binding.ToMessage(receipt), status, w)
+	if err != nil {
+		log.Printf("write delivery receipt error: %v\n", err)
+	}
+}
+
+func isLoopback(addr net.Addr) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	return ok && tcpAddr.IP.IsLoopback()
+}
+
+func processOneEvent(ev *cloudevents.Event, fn broker.Subscriber) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("event process panic: %v", r)
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	err = fn(ev)
+	if err != nil {
+		log.Printf("event process error: %v\n", err)
+	}
+
+	return err
+}
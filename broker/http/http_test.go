@@ -0,0 +1,165 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/botaas/telegram-bot-connector/broker"
+	"github.com/botaas/telegram-bot-connector/internal/brokertest"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+// recordRequests serves 204 and sends the requests it receives to the
+// returned channel.
+func recordRequests(t *testing.T) (string, <-chan *nethttp.Request) {
+	t.Helper()
+
+	requests := make(chan *nethttp.Request, 10)
+	srv := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		body, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		requests <- r
+		w.WriteHeader(nethttp.StatusNoContent)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv.URL, requests
+}
+
+// signedRequest is an outbox request signed at timestamp.
+func signedRequest(t *testing.T, h *httpBroker, ev *cloudevents.Event, timestamp time.Time) *nethttp.Request {
+	t.Helper()
+
+	url, requests := recordRequests(t)
+	h.o.urls["outbox"] = url
+	err := h.Publish(context.Background(), "outbox", ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := <-requests
+
+	// sign again at timestamp
+	body, _ := io.ReadAll(r.Body)
+	req := httptest.NewRequest(nethttp.MethodPost, "/outbox", bytes.NewReader(body))
+	for name, values := range r.Header {
+		req.Header[name] = values
+	}
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	req.Header.Set(timestampHeader, ts)
+	req.Header.Set(signatureHeader, h.sign(ts, req.Header, body))
+
+	return req
+}
+
+func serve(h *httpBroker, req *nethttp.Request) int {
+	w := httptest.NewRecorder()
+	h.serveHTTP(w, req)
+	return w.Code
+}
+
+func newTestBroker(opts ...Option) *httpBroker {
+	h := New(opts...).(*httpBroker)
+	h.subscribers["outbox"] = func(ev *cloudevents.Event) error { return nil }
+	return h
+}
+
+// newLoopBroker returns a broker posting the events of the inbox and outbox
+// to its own server.
+func newLoopBroker(t *testing.T) broker.Broker {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	return New(
+		WithListenAddr(addr),
+		WithURL("inbox", "http://"+addr+"/inbox"),
+		WithURL("outbox", "http://"+addr+"/outbox"),
+		WithSecret("secret"),
+		WithMaxAttempts(1),
+	)
+}
+
+func TestHTTPConforms(t *testing.T) {
+	brokertest.Run(t, newLoopBroker)
+}
+
+func TestPublishRoutesByChannel(t *testing.T) {
+	inboxURL, inbox := recordRequests(t)
+	deadLetterURL, deadLetter := recordRequests(t)
+	h := New(WithURL("inbox", inboxURL), WithURL("dead_letter", deadLetterURL)).(*httpBroker)
+	ctx := context.Background()
+
+	for _, channel := range []string{"inbox", "dead_letter"} {
+		err := h.Publish(ctx, channel, brokertest.NewEvent(channel))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if r := <-inbox; r.Header.Get("Content-Type") == "" {
+		t.Error("inbox event posted without content type")
+	}
+	<-deadLetter
+
+	err := h.Publish(ctx, "other", brokertest.NewEvent("1"))
+	if err == nil {
+		t.Fatal("publish to a channel without url succeeded")
+	}
+	if len(inbox) != 0 || len(deadLetter) != 0 {
+		t.Error("event of a channel without url was posted")
+	}
+}
+
+func TestSignedRequestsAreAccepted(t *testing.T) {
+	for _, mode := range []string{StructuredMode, BinaryMode} {
+		h := newTestBroker(WithSecret("secret"), WithMode(mode))
+
+		req := signedRequest(t, h, brokertest.NewEvent("1"), time.Now())
+		if code := serve(h, req); code != nethttp.StatusNoContent {
+			t.Errorf("%s request answered %d, want 204", mode, code)
+		}
+	}
+}
+
+func TestAlteredAttributesAreRejected(t *testing.T) {
+	h := newTestBroker(WithSecret("secret"), WithMode(BinaryMode))
+
+	req := signedRequest(t, h, brokertest.NewEvent("1"), time.Now())
+	req.Header.Set("Ce-Type", "delete_message")
+	if code := serve(h, req); code != nethttp.StatusUnauthorized {
+		t.Errorf("request with an altered attribute answered %d, want 401", code)
+	}
+}
+
+func TestReplayedRequestsAreRejected(t *testing.T) {
+	h := newTestBroker(WithSecret("secret"))
+
+	req := signedRequest(t, h, brokertest.NewEvent("1"), time.Now().Add(-time.Hour))
+	if code := serve(h, req); code != nethttp.StatusUnauthorized {
+		t.Errorf("request signed an hour ago answered %d, want 401", code)
+	}
+
+	req = signedRequest(t, h, brokertest.NewEvent("1"), time.Now())
+	req.Header.Del(timestampHeader)
+	if code := serve(h, req); code != nethttp.StatusUnauthorized {
+		t.Errorf("request without timestamp answered %d, want 401", code)
+	}
+}
+
+func TestListensOnLocalhostByDefault(t *testing.T) {
+	h := New().(*httpBroker)
+	if h.o.listenAddr != "localhost:8090" {
+		t.Errorf("listen address = %s, want localhost:8090", h.o.listenAddr)
+	}
+}
@@ -0,0 +1,71 @@
+package http
+
+import "time"
+
+const (
+	// StructuredMode posts the whole JSON encoded event as the request body
+	StructuredMode = "structured"
+	// BinaryMode posts the event data as the request body and its attributes
+	// in ce- prefixed headers
+	BinaryMode = "binary"
+)
+
+type httpOptions struct {
+	urls        map[string]string
+	listenAddr  string
+	secret      string
+	mode        string
+	maxAttempts int
+	timeout     time.Duration
+}
+
+type Option func(o *httpOptions)
+
+// WithURL sets the endpoint the events published to channel are POSTed to,
+// publishing to a channel without endpoint fails.
+func WithURL(channel string, url string) Option {
+	return func(o *httpOptions) {
+		o.urls[channel] = url
+	}
+}
+
+// WithListenAddr sets the address of the server accepting the events of the
+// subscribed channels, each one on the path /<channel>. It defaults to
+// localhost, listen on other interfaces only with WithSecret.
+func WithListenAddr(addr string) Option {
+	return func(o *httpOptions) {
+		o.listenAddr = addr
+	}
+}
+
+// WithSecret sets the key of the HMAC-SHA256 signature of the requests, sent
+// in the X-Signature-256 header of published events and required on the
+// accepted ones. It covers the X-Signature-Timestamp header, the ce- headers,
+// Content-Type and the body.
+func WithSecret(secret string) Option {
+	return func(o *httpOptions) {
+		o.secret = secret
+	}
+}
+
+// WithMode sets the CloudEvents HTTP binding content mode of published
+// events and responses, StructuredMode or BinaryMode. Both are accepted.
+func WithMode(mode string) Option {
+	return func(o *httpOptions) {
+		o.mode = mode
+	}
+}
+
+// WithMaxAttempts sets how many times a publish is attempted when the
+// endpoint fails with a network error, 429 or a 5xx status.
+func WithMaxAttempts(maxAttempts int) Option {
+	return func(o *httpOptions) {
+		o.maxAttempts = maxAttempts
+	}
+}
+
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *httpOptions) {
+		o.timeout = timeout
+	}
+}
@@ -0,0 +1,124 @@
+package kafka
+
+import (
+	"strings"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/types"
+	gokafka "github.com/segmentio/kafka-go"
+)
+
+const (
+	headerPrefix      = "ce_"
+	contentTypeHeader = "content-type"
+)
+
+// newMessage encodes ev to a record of topic following the CloudEvents Kafka
+// protocol binding, keyed by the event subject.
+func newMessage(topic string, ev *cloudevents.Event, mode string) (gokafka.Message, error) {
+	msg := gokafka.Message{
+		Topic: topic,
+	}
+
+	if len(ev.Subject()) > 0 {
+		msg.Key = []byte(ev.Subject())
+	}
+
+	if mode != BinaryMode {
+		b, err := ev.MarshalJSON()
+		if err != nil {
+			return msg, err
+		}
+
+		msg.Value = b
+		msg.Headers = []gokafka.Header{{Key: contentTypeHeader, Value: []byte(cloudevents.ApplicationCloudEventsJSON)}}
+		return msg, nil
+	}
+
+	addHeader := func(name string, value string) {
+		if len(value) > 0 {
+			msg.Headers = append(msg.Headers, gokafka.Header{Key: name, Value: []byte(value)})
+		}
+	}
+
+	addHeader(headerPrefix+"specversion", ev.SpecVersion())
+	addHeader(headerPrefix+"id", ev.ID())
+	addHeader(headerPrefix+"source", ev.Source())
+	addHeader(headerPrefix+"type", ev.Type())
+	addHeader(headerPrefix+"subject", ev.Subject())
+	addHeader(headerPrefix+"dataschema", ev.DataSchema())
+	if !ev.Time().IsZero() {
+		addHeader(headerPrefix+"time", types.FormatTime(ev.Time()))
+	}
+	for name, value := range ev.Extensions() {
+		s, err := types.Format(value)
+		if err != nil {
+			return msg, err
+		}
+		addHeader(headerPrefix+name, s)
+	}
+	addHeader(contentTypeHeader, ev.DataContentType())
+
+	msg.Value = ev.Data()
+	return msg, nil
+}
+
+// newEvent decodes a record in either content mode.
+func newEvent(msg *gokafka.Message) (*cloudevents.Event, error) {
+	var contentType string
+	binary := false
+	for _, h := range msg.Headers {
+		key := strings.ToLower(h.Key)
+		if key == contentTypeHeader {
+			contentType = string(h.Value)
+		}
+		if key == headerPrefix+"specversion" {
+			binary = true
+		}
+	}
+
+	ev := cloudevents.NewEvent()
+	if !binary || strings.HasPrefix(contentType, cloudevents.ApplicationCloudEventsJSON) {
+		err := ev.UnmarshalJSON(msg.Value)
+		return &ev, err
+	}
+
+	for _, h := range msg.Headers {
+		key := strings.ToLower(h.Key)
+		if !strings.HasPrefix(key, headerPrefix) {
+			continue
+		}
+
+		value := string(h.Value)
+		switch name := strings.TrimPrefix(key, headerPrefix); name {
+		case "specversion":
+			ev.SetSpecVersion(value)
+		case "id":
+			ev.SetID(value)
+		case "source":
+			ev.SetSource(value)
+		case "type":
+			ev.SetType(value)
+		case "subject":
+			ev.SetSubject(value)
+		case "dataschema":
+			ev.SetDataSchema(value)
+		case "time":
+			t, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				return nil, err
+			}
+			ev.SetTime(t)
+		default:
+			ev.SetExtension(name, value)
+		}
+	}
+
+	if len(contentType) > 0 {
+		ev.SetDataContentType(contentType)
+	}
+	ev.DataEncoded = msg.Value
+
+	return &ev, nil
+}
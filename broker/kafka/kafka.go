@@ -0,0 +1,229 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/botaas/telegram-bot-connector/broker"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	gokafka "github.com/segmentio/kafka-go"
+)
+
+const (
+	minBackoff = time.Second
+	maxBackoff = 30 * time.Second
+)
+
+type unsubscriber struct {
+	cancel  context.CancelFunc
+	readers []*gokafka.Reader
+	once    sync.Once
+}
+
+func (s *unsubscriber) Cancel() {
+	s.once.Do(func() {
+		s.cancel()
+		for _, r := range s.readers {
+			r.Close()
+		}
+	})
+}
+
+// messageReader reads the records of a consumer group, *gokafka.Reader.
+type messageReader interface {
+	FetchMessage(ctx context.Context) (gokafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...gokafka.Message) error
+}
+
+// messageWriter writes records, *gokafka.Writer.
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...gokafka.Message) error
+}
+
+// kafka is a broker on Kafka topics. Events are partitioned by their subject,
+// the chat id, so that the events of a chat keep their order, and a record is
+// only committed once the Subscriber succeeded or it was dead lettered.
+type kafka struct {
+	writer messageWriter
+	o      *kafkaOptions
+}
+
+func New(opts ...Option) broker.Broker {
+	o := &kafkaOptions{
+		brokers:     []string{"localhost:9092"},
+		groupID:     "telegram-bot-connector",
+		mode:        StructuredMode,
+		concurrency: 1,
+		maxAttempts: 3,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if o.concurrency < 1 {
+		o.concurrency = 1
+	}
+
+	if o.maxAttempts < 1 {
+		o.maxAttempts = 1
+	}
+
+	writer := &gokafka.Writer{
+		Addr:                   gokafka.TCP(o.brokers...),
+		Balancer:               &gokafka.Hash{},
+		RequiredAcks:           gokafka.RequireAll,
+		BatchTimeout:           10 * time.Millisecond,
+		AllowAutoTopicCreation: true,
+	}
+
+	return &kafka{
+		writer: writer,
+		o:      o,
+	}
+}
+
+func (k *kafka) Acknowledges() bool {
+	return true
+}
+
+func (k *kafka) Publish(ctx context.Context, channel string, event *cloudevents.Event) error {
+	msg, err := newMessage(channel, event, k.o.mode)
+	if err != nil {
+		return err
+	}
+
+	return k.writer.WriteMessages(ctx, msg)
+}
+
+func (k *kafka) Subscribe(ctx context.Context, channel string, fn broker.Subscriber) (broker.Unsubscriber, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	u := &unsubscriber{
+		cancel: cancel,
+	}
+
+	// the partitions are spread over the readers of the group
+	for i := 0; i < k.o.concurrency; i++ {
+		reader := gokafka.NewReader(gokafka.ReaderConfig{
+			Brokers: k.o.brokers,
+			GroupID: k.o.groupID,
+			Topic:   channel,
+		})
+		u.readers = append(u.readers, reader)
+
+		go k.read(ctx, reader, fn)
+	}
+
+	return u, nil
+}
+
+func (k *kafka) read(ctx context.Context, reader messageReader, fn broker.Subscriber) {
+	fetchBackoff := minBackoff
+	for {
+		msg, err := reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("fetch message error: %v\n", err)
+
+			if !sleep(ctx, fetchBackoff) {
+				return
+			}
+			fetchBackoff = nextBackoff(fetchBackoff)
+			continue
+		}
+		fetchBackoff = minBackoff
+
+		// a failed record is retried in place, committing a later one would
+		// skip it, until it is dead lettered so that it does not block its
+		// partition
+		backoff := minBackoff
+		for attempts := 1; ; attempts++ {
+			err = processOneMessage(&msg, fn)
+			if err == nil {
+				break
+			}
+
+			if attempts >= k.o.maxAttempts {
+				dlErr := k.deadLetter(ctx, &msg, err, attempts)
+				if dlErr == nil {
+					break
+				}
+				log.Printf("dead letter message error: %v\n", dlErr)
+			}
+
+			if !sleep(ctx, backoff) {
+				return
+			}
+			backoff = nextBackoff(backoff)
+		}
+
+		err = reader.CommitMessages(ctx, msg)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("commit message error: %v\n", err)
+		}
+	}
+}
+
+// deadLetter publishes the event of msg, which failed attempts times with
+// err, to the dead letter topic, or drops it without one.
+func (k *kafka) deadLetter(ctx context.Context, msg *gokafka.Message, err error, attempts int) error {
+	if k.o.deadLetter == "" {
+		log.Printf("drop message %s/%d/%d after %d attempts: %v\n", msg.Topic, msg.Partition, msg.Offset, attempts, err)
+		return nil
+	}
+
+	ev, decodeErr := newEvent(msg)
+	if decodeErr != nil {
+		return decodeErr
+	}
+	ev.SetExtension("deadletterreason", err.Error())
+	ev.SetExtension("deadletterattempts", attempts)
+
+	return k.Publish(ctx, k.o.deadLetter, ev)
+}
+
+// sleep waits for d, it returns false when ctx is done first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+func nextBackoff(backoff time.Duration) time.Duration {
+	backoff *= 2
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	return backoff
+}
+
+func processOneMessage(msg *gokafka.Message, fn broker.Subscriber) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Println("Recovered in f", r)
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	ev, err := newEvent(msg)
+	if err != nil {
+		// retrying will not make it decodable
+		log.Printf("Unmarshal event error: %v\n", err)
+		return nil
+	}
+
+	err = fn(ev)
+	if err != nil {
+		log.Printf("event process error: %v\n", err)
+	}
+
+	return err
+}
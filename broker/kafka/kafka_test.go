@@ -0,0 +1,191 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	gokafka "github.com/segmentio/kafka-go"
+)
+
+// fakeReader hands out its records, or its fetch error, and records the
+// commits.
+type fakeReader struct {
+	mu        sync.Mutex
+	msgs      []gokafka.Message
+	fetchErr  error
+	fetches   int
+	committed []gokafka.Message
+}
+
+func (r *fakeReader) FetchMessage(ctx context.Context) (gokafka.Message, error) {
+	r.mu.Lock()
+	r.fetches++
+	if r.fetchErr != nil {
+		r.mu.Unlock()
+		return gokafka.Message{}, r.fetchErr
+	}
+	if len(r.msgs) > 0 {
+		msg := r.msgs[0]
+		r.msgs = r.msgs[1:]
+		r.mu.Unlock()
+		return msg, nil
+	}
+	r.mu.Unlock()
+
+	<-ctx.Done()
+	return gokafka.Message{}, ctx.Err()
+}
+
+func (r *fakeReader) CommitMessages(ctx context.Context, msgs ...gokafka.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.committed = append(r.committed, msgs...)
+	return nil
+}
+
+func (r *fakeReader) Committed() []gokafka.Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]gokafka.Message(nil), r.committed...)
+}
+
+type fakeWriter struct {
+	mu      sync.Mutex
+	written []gokafka.Message
+}
+
+func (w *fakeWriter) WriteMessages(ctx context.Context, msgs ...gokafka.Message) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.written = append(w.written, msgs...)
+	return nil
+}
+
+func newTestKafka(opts ...Option) (*kafka, *fakeWriter) {
+	k := New(opts...).(*kafka)
+	w := &fakeWriter{}
+	k.writer = w
+	return k, w
+}
+
+func newTestMessage(t *testing.T, id string) gokafka.Message {
+	t.Helper()
+
+	ev := cloudevents.NewEvent()
+	ev.SetID(id)
+	ev.SetType("message")
+	ev.SetSource("test")
+	ev.SetSubject("42")
+	msg, err := newMessage("outbox", &ev, StructuredMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+// readUntil runs read until cond holds or the timeout expires.
+func readUntil(t *testing.T, k *kafka, r *fakeReader, fn func(ev *cloudevents.Event) error, cond func() bool) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		k.read(ctx, r, fn)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met before the timeout")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestReadCommitsProcessedRecords(t *testing.T) {
+	k, _ := newTestKafka()
+	r := &fakeReader{msgs: []gokafka.Message{newTestMessage(t, "1"), newTestMessage(t, "2")}}
+
+	var mu sync.Mutex
+	var ids []string
+	readUntil(t, k, r, func(ev *cloudevents.Event) error {
+		mu.Lock()
+		ids = append(ids, ev.ID())
+		mu.Unlock()
+		return nil
+	}, func() bool { return len(r.Committed()) == 2 })
+
+	if len(ids) != 2 || ids[0] != "1" || ids[1] != "2" {
+		t.Fatalf("processed %v, want [1 2]", ids)
+	}
+}
+
+func TestReadDeadLettersRecordsAfterMaxAttempts(t *testing.T) {
+	k, w := newTestKafka(WithMaxAttempts(2), WithDeadLetter("dead_letter"))
+	r := &fakeReader{msgs: []gokafka.Message{newTestMessage(t, "1")}}
+
+	var mu sync.Mutex
+	attempts := 0
+	readUntil(t, k, r, func(ev *cloudevents.Event) error {
+		mu.Lock()
+		attempts++
+		mu.Unlock()
+		return errors.New("failed")
+	}, func() bool { return len(r.Committed()) == 1 })
+
+	if attempts != 2 {
+		t.Errorf("processed %d times, want 2", attempts)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.written) != 1 || w.written[0].Topic != "dead_letter" {
+		t.Fatalf("wrote %v, want the record to the dead letter topic", w.written)
+	}
+
+	ev, err := newEvent(&w.written[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.ID() != "1" || ev.Extensions()["deadletterreason"] != "failed" {
+		t.Errorf("dead letter %s with extensions %v, want 1 with its reason", ev.ID(), ev.Extensions())
+	}
+}
+
+func TestReadDropsRecordsWithoutDeadLetter(t *testing.T) {
+	k, w := newTestKafka(WithMaxAttempts(1))
+	r := &fakeReader{msgs: []gokafka.Message{newTestMessage(t, "1")}}
+
+	readUntil(t, k, r, func(ev *cloudevents.Event) error {
+		return errors.New("failed")
+	}, func() bool { return len(r.Committed()) == 1 })
+
+	if len(w.written) != 0 {
+		t.Errorf("wrote %d records, want none", len(w.written))
+	}
+}
+
+func TestReadBacksOffOnFetchErrors(t *testing.T) {
+	k, _ := newTestKafka()
+	r := &fakeReader{fetchErr: errors.New("broker down")}
+
+	ctx, cancel := context.WithTimeout(context.Background(), minBackoff/2)
+	defer cancel()
+	k.read(ctx, r, func(ev *cloudevents.Event) error { return nil })
+
+	if r.fetches != 1 {
+		t.Fatalf("fetched %d times within the backoff, want 1", r.fetches)
+	}
+}
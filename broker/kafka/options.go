@@ -0,0 +1,66 @@
+package kafka
+
+const (
+	// StructuredMode puts the whole JSON encoded event in the record value
+	StructuredMode = "structured"
+	// BinaryMode puts the event data in the record value and its attributes
+	// in ce_ prefixed headers
+	BinaryMode = "binary"
+)
+
+type kafkaOptions struct {
+	brokers     []string
+	groupID     string
+	mode        string
+	concurrency int
+	maxAttempts int
+	deadLetter  string
+}
+
+type Option func(o *kafkaOptions)
+
+func WithBrokers(brokers ...string) Option {
+	return func(o *kafkaOptions) {
+		o.brokers = brokers
+	}
+}
+
+// WithGroupID sets the consumer group reading the subscribed topics.
+func WithGroupID(groupID string) Option {
+	return func(o *kafkaOptions) {
+		o.groupID = groupID
+	}
+}
+
+// WithMode sets the CloudEvents Kafka protocol binding content mode of
+// published events, StructuredMode or BinaryMode. Both are accepted on
+// subscribe.
+func WithMode(mode string) Option {
+	return func(o *kafkaOptions) {
+		o.mode = mode
+	}
+}
+
+// WithConcurrency sets how many readers of the consumer group run, each
+// processes the records of its partitions in order.
+func WithConcurrency(concurrency int) Option {
+	return func(o *kafkaOptions) {
+		o.concurrency = concurrency
+	}
+}
+
+// WithMaxAttempts sets how many times a record is processed before it is
+// dead lettered.
+func WithMaxAttempts(maxAttempts int) Option {
+	return func(o *kafkaOptions) {
+		o.maxAttempts = maxAttempts
+	}
+}
+
+// WithDeadLetter sets the topic records that failed WithMaxAttempts times are
+// published to, empty to drop them.
+func WithDeadLetter(deadLetter string) Option {
+	return func(o *kafkaOptions) {
+		o.deadLetter = deadLetter
+	}
+}
@@ -0,0 +1,136 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/botaas/telegram-bot-connector/broker"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+type subscription struct {
+	events chan *cloudevents.Event
+	done   chan struct{}
+}
+
+type unsubscriber struct {
+	m       *memory
+	channel string
+	sub     *subscription
+	once    sync.Once
+}
+
+func (s *unsubscriber) Cancel() {
+	s.once.Do(func() {
+		s.m.remove(s.channel, s.sub)
+		close(s.sub.done)
+	})
+}
+
+// memory is an in-process broker, every subscriber of a channel receives a
+// copy of the events published to it. Events published to a channel without
+// subscriber are dropped.
+type memory struct {
+	o *memoryOptions
+
+	mu   sync.RWMutex
+	subs map[string][]*subscription
+}
+
+func New(opts ...Option) broker.Broker {
+	o := &memoryOptions{
+		bufferSize: 100,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return &memory{
+		o:    o,
+		subs: map[string][]*subscription{},
+	}
+}
+
+func (m *memory) Publish(ctx context.Context, channel string, event *cloudevents.Event) error {
+	m.mu.RLock()
+	subs := m.subs[channel]
+	m.mu.RUnlock()
+
+	for _, sub := range subs {
+		ev := event.Clone()
+		select {
+		case sub.events <- &ev:
+		case <-sub.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+func (m *memory) Subscribe(ctx context.Context, channel string, fn broker.Subscriber) (broker.Unsubscriber, error) {
+	sub := &subscription{
+		events: make(chan *cloudevents.Event, m.o.bufferSize),
+		done:   make(chan struct{}),
+	}
+
+	m.mu.Lock()
+	m.subs[channel] = append(m.subs[channel], sub)
+	m.mu.Unlock()
+
+	u := &unsubscriber{
+		m:       m,
+		channel: channel,
+		sub:     sub,
+	}
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				u.Cancel()
+				return
+			case <-sub.done:
+				return
+			case ev := <-sub.events:
+				processOneEvent(ev, fn)
+			}
+		}
+	}()
+
+	return u, nil
+}
+
+func (m *memory) remove(channel string, sub *subscription) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	subs := m.subs[channel]
+	for i, s := range subs {
+		if s == sub {
+			m.subs[channel] = append(subs[:i:i], subs[i+1:]...)
+			break
+		}
+	}
+
+	if len(m.subs[channel]) == 0 {
+		delete(m.subs, channel)
+	}
+}
+
+func processOneEvent(ev *cloudevents.Event, fn broker.Subscriber) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Println("Recovered in f", r)
+		}
+	}()
+
+	err := fn(ev)
+	if err != nil {
+		log.Printf("event process error: %v\n", err)
+	}
+}
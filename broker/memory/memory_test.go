@@ -0,0 +1,140 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/botaas/telegram-bot-connector/broker"
+	"github.com/botaas/telegram-bot-connector/internal/brokertest"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+func TestMemoryConforms(t *testing.T) {
+	brokertest.Run(t, func(t *testing.T) broker.Broker {
+		return New()
+	})
+}
+
+func TestMemoryDeliversToEverySubscriber(t *testing.T) {
+	m := New().(*memory)
+	ctx := context.Background()
+
+	first, cancelFirst := brokertest.Subscribe(t, ctx, m, "inbox")
+	defer cancelFirst()
+	second, cancelSecond := brokertest.Subscribe(t, ctx, m, "inbox")
+	defer cancelSecond()
+	other, cancelOther := brokertest.Subscribe(t, ctx, m, "outbox")
+	defer cancelOther()
+
+	for _, id := range []string{"1", "2"} {
+		err := m.Publish(ctx, "inbox", brokertest.NewEvent(id))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// each subscriber gets its own copy, in order
+	ev := brokertest.ExpectEvent(t, first, "1")
+	ev.SetSubject("changed")
+	brokertest.ExpectEvent(t, first, "2")
+	if ev := brokertest.ExpectEvent(t, second, "1"); ev.Subject() != "" {
+		t.Errorf("subject = %q, the copy of another subscriber was changed", ev.Subject())
+	}
+	brokertest.ExpectEvent(t, second, "2")
+	brokertest.ExpectNoEvent(t, other)
+}
+
+func TestMemoryDropsEventsWithoutSubscriber(t *testing.T) {
+	m := New().(*memory)
+	ctx := context.Background()
+
+	err := m.Publish(ctx, "inbox", brokertest.NewEvent("1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received, cancel := brokertest.Subscribe(t, ctx, m, "inbox")
+	defer cancel()
+	brokertest.ExpectNoEvent(t, received)
+}
+
+func TestMemoryCancelStopsDelivery(t *testing.T) {
+	m := New().(*memory)
+	ctx := context.Background()
+
+	received, cancel := brokertest.Subscribe(t, ctx, m, "inbox")
+	cancel()
+	// canceling twice is fine
+	cancel()
+
+	err := m.Publish(ctx, "inbox", brokertest.NewEvent("1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	brokertest.ExpectNoEvent(t, received)
+
+	if _, exist := m.subs["inbox"]; exist {
+		t.Error("canceled subscription is still registered")
+	}
+}
+
+func TestMemorySubscriptionEndsWithContext(t *testing.T) {
+	m := New().(*memory)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	received, cancelSub := brokertest.Subscribe(t, ctx, m, "inbox")
+	defer cancelSub()
+	cancel()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		m.mu.RLock()
+		_, exist := m.subs["inbox"]
+		m.mu.RUnlock()
+		if !exist {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("subscription was not removed once its context was done")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	err := m.Publish(context.Background(), "inbox", brokertest.NewEvent("1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	brokertest.ExpectNoEvent(t, received)
+}
+
+func TestMemoryPublishWaitsForFullBuffer(t *testing.T) {
+	m := New(WithBufferSize(1)).(*memory)
+	ctx := context.Background()
+
+	block := make(chan struct{})
+	unsub, err := m.Subscribe(ctx, "inbox", func(ev *cloudevents.Event) error {
+		<-block
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unsub.Cancel()
+	defer close(block)
+
+	// one event is processed, one buffered, the next publish waits
+	for _, id := range []string{"1", "2"} {
+		err := m.Publish(ctx, "inbox", brokertest.NewEvent(id))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	err = m.Publish(timeout, "inbox", brokertest.NewEvent("3"))
+	if err != context.DeadlineExceeded {
+		t.Fatalf("err = %v, want the publish to wait for room in the buffer", err)
+	}
+}
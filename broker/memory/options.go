@@ -0,0 +1,15 @@
+package memory
+
+type memoryOptions struct {
+	bufferSize int
+}
+
+type Option func(o *memoryOptions)
+
+// WithBufferSize sets how many events a subscriber buffers before Publish
+// blocks.
+func WithBufferSize(size int) Option {
+	return func(o *memoryOptions) {
+		o.bufferSize = size
+	}
+}
@@ -0,0 +1,337 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/botaas/telegram-bot-connector/broker"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	gonats "github.com/nats-io/nats.go"
+)
+
+const fetchWait = 5 * time.Second
+
+var streamNameReplacer = strings.NewReplacer(".", "_", "*", "_", ">", "_")
+
+type unsubscriber struct {
+	sub    *gonats.Subscription
+	cancel context.CancelFunc
+}
+
+func (s *unsubscriber) Cancel() {
+	s.cancel()
+	s.sub.Unsubscribe()
+}
+
+// nats is a broker on core NATS subjects or, with JetStream, on streams read
+// by durable pull consumers.
+type nats struct {
+	nc *gonats.Conn
+	js gonats.JetStreamContext
+	o  *natsOptions
+
+	// streams caches the stream capturing each subject
+	mu      sync.Mutex
+	streams map[string]string
+}
+
+func New(opts ...Option) (broker.Broker, error) {
+	o := &natsOptions{
+		url:         gonats.DefaultURL,
+		durable:     "telegram-bot-connector",
+		ackWait:     time.Minute,
+		nakDelay:    time.Second,
+		maxNakDelay: time.Minute,
+		maxAttempts: 3,
+		maxAge:      24 * time.Hour,
+		concurrency: 1,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if o.concurrency < 1 {
+		o.concurrency = 1
+	}
+
+	if o.ackWait <= 0 {
+		o.ackWait = time.Minute
+	}
+
+	if o.maxAttempts < 1 {
+		o.maxAttempts = 1
+	}
+
+	natsOpts := []gonats.Option{
+		gonats.MaxReconnects(-1),
+	}
+	if len(o.credsFile) > 0 {
+		natsOpts = append(natsOpts, gonats.UserCredentials(o.credsFile))
+	}
+
+	nc, err := gonats.Connect(o.url, natsOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	n := &nats{
+		nc:      nc,
+		o:       o,
+		streams: map[string]string{},
+	}
+
+	if o.jetStream {
+		n.js, err = nc.JetStream()
+		if err != nil {
+			nc.Close()
+			return nil, err
+		}
+	}
+
+	return n, nil
+}
+
+func (n *nats) Acknowledges() bool {
+	return n.o.jetStream
+}
+
+func (n *nats) Publish(ctx context.Context, channel string, event *cloudevents.Event) error {
+	b, err := event.MarshalJSON()
+	if err != nil {
+		return err
+	}
+
+	if n.js == nil {
+		return n.nc.Publish(channel, b)
+	}
+
+	_, err = n.ensureStream(channel)
+	if err != nil {
+		return err
+	}
+
+	_, err = n.js.Publish(channel, b, gonats.Context(ctx))
+	return err
+}
+
+func (n *nats) Subscribe(ctx context.Context, channel string, fn broker.Subscriber) (broker.Unsubscriber, error) {
+	if n.js == nil {
+		return n.subscribeCore(ctx, channel, fn)
+	}
+
+	return n.subscribeJetStream(ctx, channel, fn)
+}
+
+func (n *nats) subscribeCore(ctx context.Context, channel string, fn broker.Subscriber) (broker.Unsubscriber, error) {
+	msgs := make(chan *gonats.Msg, gonats.DefaultSubPendingMsgsLimit)
+	sub, err := n.nc.ChanQueueSubscribe(channel, n.o.durable, msgs)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg := <-msgs:
+				processOneMessage(msg, fn)
+			}
+		}
+	}()
+
+	return &unsubscriber{
+		sub:    sub,
+		cancel: cancel,
+	}, nil
+}
+
+func (n *nats) subscribeJetStream(ctx context.Context, channel string, fn broker.Subscriber) (broker.Unsubscriber, error) {
+	stream, err := n.ensureStream(channel)
+	if err != nil {
+		return nil, err
+	}
+
+	// the consumer is created here rather than by the subscription, which
+	// would delete it on Unsubscribe
+	_, err = n.js.ConsumerInfo(stream, n.o.durable)
+	if errors.Is(err, gonats.ErrConsumerNotFound) {
+		_, err = n.js.AddConsumer(stream, &gonats.ConsumerConfig{
+			Durable:       n.o.durable,
+			AckPolicy:     gonats.AckExplicitPolicy,
+			AckWait:       n.o.ackWait,
+			MaxDeliver:    n.o.maxAttempts,
+			MaxAckPending: n.o.concurrency,
+			FilterSubject: channel,
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sub, err := n.js.PullSubscribe(channel, n.o.durable, gonats.Bind(stream, n.o.durable))
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	for i := 0; i < n.o.concurrency; i++ {
+		go func() {
+			for ctx.Err() == nil {
+				fetchCtx, fetchCancel := context.WithTimeout(ctx, fetchWait)
+				msgs, err := sub.Fetch(1, gonats.Context(fetchCtx))
+				fetchCancel()
+				if err != nil {
+					if ctx.Err() == nil && !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, gonats.ErrTimeout) {
+						log.Printf("fetch %s error: %v\n", channel, err)
+						time.Sleep(time.Second)
+					}
+					continue
+				}
+
+				for _, msg := range msgs {
+					n.processOneAck(msg, fn)
+				}
+			}
+		}()
+	}
+
+	return &unsubscriber{
+		sub:    sub,
+		cancel: cancel,
+	}, nil
+}
+
+// ensureStream returns the stream capturing subject, creating one named after
+// the subject when there is none. The stream is looked up once per subject.
+func (n *nats) ensureStream(subject string) (string, error) {
+	n.mu.Lock()
+	stream, exist := n.streams[subject]
+	n.mu.Unlock()
+	if exist {
+		return stream, nil
+	}
+
+	stream, err := n.js.StreamNameBySubject(subject)
+	if errors.Is(err, gonats.ErrNoMatchingStream) {
+		stream = streamNameReplacer.Replace(subject)
+		_, err = n.js.AddStream(&gonats.StreamConfig{
+			Name:     stream,
+			Subjects: []string{subject},
+			MaxAge:   n.o.maxAge,
+		})
+		if errors.Is(err, gonats.ErrStreamNameAlreadyInUse) {
+			err = nil
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	n.mu.Lock()
+	n.streams[subject] = stream
+	n.mu.Unlock()
+
+	return stream, nil
+}
+
+// processOneAck acks msg when the Subscriber succeeded and naks it otherwise
+// so that it is redelivered after a backoff, until its last attempt where it
+// is terminated. While the Subscriber runs, which may take longer than the
+// ack wait when the event is held back by the rate limits, msg is reported in
+// progress so that it is not redelivered.
+func (n *nats) processOneAck(msg *gonats.Msg, fn broker.Subscriber) {
+	done := make(chan struct{})
+	go func() {
+		interval := n.o.ackWait / 3
+		if interval <= 0 {
+			interval = n.o.ackWait
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				err := msg.InProgress()
+				if err != nil {
+					log.Printf("extend ack wait error: %v\n", err)
+				}
+			}
+		}
+	}()
+
+	err := processOneMessage(msg, fn)
+	close(done)
+
+	switch {
+	case err == nil:
+		err = msg.Ack()
+	case n.lastAttempt(msg):
+		// the consumer of an older connector may lack MaxDeliver
+		log.Printf("event failed %d times, terminated: %v\n", n.o.maxAttempts, err)
+		err = msg.Term()
+	default:
+		err = msg.NakWithDelay(n.nakDelay(msg))
+	}
+	if err != nil {
+		log.Printf("ack event error: %v\n", err)
+	}
+}
+
+// lastAttempt reports whether msg was delivered WithMaxAttempts times.
+func (n *nats) lastAttempt(msg *gonats.Msg) bool {
+	meta, err := msg.Metadata()
+	return err == nil && meta.NumDelivered >= uint64(n.o.maxAttempts)
+}
+
+// nakDelay doubles the redelivery delay with every delivery of msg.
+func (n *nats) nakDelay(msg *gonats.Msg) time.Duration {
+	delay := n.o.nakDelay
+	meta, err := msg.Metadata()
+	if err != nil {
+		return delay
+	}
+
+	for i := uint64(1); i < meta.NumDelivered && delay < n.o.maxNakDelay; i++ {
+		delay *= 2
+	}
+	if delay > n.o.maxNakDelay {
+		delay = n.o.maxNakDelay
+	}
+
+	return delay
+}
+
+func processOneMessage(msg *gonats.Msg, fn broker.Subscriber) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("event process panic: %v", r)
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	ev := cloudevents.NewEvent()
+	err = ev.UnmarshalJSON(msg.Data)
+	if err != nil {
+		// redelivering will not make it decodable
+		log.Printf("Unmarshal event error: %v\n", err)
+		return nil
+	}
+
+	err = fn(&ev)
+	if err != nil {
+		log.Printf("event process error: %v\n", err)
+	}
+
+	return err
+}
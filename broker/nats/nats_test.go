@@ -0,0 +1,242 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/botaas/telegram-bot-connector/broker"
+	"github.com/botaas/telegram-bot-connector/internal/brokertest"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/nats-io/nats-server/v2/server"
+	gonats "github.com/nats-io/nats.go"
+)
+
+func runServer(t *testing.T) string {
+	t.Helper()
+
+	s, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      -1,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go s.Start()
+	if !s.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats server not ready")
+	}
+	t.Cleanup(s.Shutdown)
+
+	return s.ClientURL()
+}
+
+func newTestNats(t *testing.T, opts ...Option) *nats {
+	t.Helper()
+
+	opts = append([]Option{WithURL(runServer(t))}, opts...)
+	b, err := New(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := b.(*nats)
+	t.Cleanup(n.nc.Close)
+
+	return n
+}
+
+func TestNatsCoreConforms(t *testing.T) {
+	brokertest.Run(t, func(t *testing.T) broker.Broker {
+		return newTestNats(t)
+	})
+}
+
+func TestJetStreamConforms(t *testing.T) {
+	brokertest.Run(t, func(t *testing.T) broker.Broker {
+		return newTestNats(t, WithJetStream(true))
+	})
+}
+
+func TestJetStreamDeliversEventsPublishedBeforeSubscribe(t *testing.T) {
+	n := newTestNats(t, WithJetStream(true))
+	ctx := context.Background()
+
+	err := n.Publish(ctx, "outbox", brokertest.NewEvent("1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stream := n.streams["outbox"]; stream != "outbox" {
+		t.Errorf("cached stream = %q, want outbox", stream)
+	}
+
+	received, cancel := brokertest.Subscribe(t, ctx, n, "outbox")
+	defer cancel()
+	brokertest.ExpectEvent(t, received, "1")
+}
+
+func TestJetStreamDoesNotRedeliverEventsInProgress(t *testing.T) {
+	n := newTestNats(t, WithJetStream(true), WithAckWait(300*time.Millisecond))
+	ctx := context.Background()
+
+	var deliveries int32
+	done := make(chan struct{})
+	unsub, err := n.Subscribe(ctx, "outbox", func(ev *cloudevents.Event) error {
+		// longer than the ack wait, like a parked or backing off event
+		if atomic.AddInt32(&deliveries, 1) == 1 {
+			time.Sleep(time.Second)
+			close(done)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unsub.Cancel()
+
+	err = n.Publish(ctx, "outbox", brokertest.NewEvent("1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("event was not delivered")
+	}
+	time.Sleep(500 * time.Millisecond)
+
+	if n := atomic.LoadInt32(&deliveries); n != 1 {
+		t.Fatalf("event delivered %d times, want 1", n)
+	}
+}
+
+func TestJetStreamRedeliversFailedEventsAfterDelay(t *testing.T) {
+	n := newTestNats(t, WithJetStream(true), WithNakDelay(300*time.Millisecond, time.Second))
+	ctx := context.Background()
+
+	var attempts int32
+	deliveries := make(chan time.Time, 2)
+	unsub, err := n.Subscribe(ctx, "outbox", func(ev *cloudevents.Event) error {
+		deliveries <- time.Now()
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			return errors.New("failed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unsub.Cancel()
+
+	err = n.Publish(ctx, "outbox", brokertest.NewEvent("1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var first, second time.Time
+	for _, at := range []*time.Time{&first, &second} {
+		select {
+		case *at = <-deliveries:
+		case <-time.After(5 * time.Second):
+			t.Fatal("failed event was not redelivered")
+		}
+	}
+	if delay := second.Sub(first); delay < 250*time.Millisecond {
+		t.Fatalf("failed event redelivered after %v, want the nak delay", delay)
+	}
+}
+
+func TestNakDelayDoublesWithEveryDelivery(t *testing.T) {
+	n := &nats{o: &natsOptions{nakDelay: time.Second, maxNakDelay: 5 * time.Second}}
+
+	for delivered, want := range map[int]time.Duration{
+		1: time.Second,
+		2: 2 * time.Second,
+		3: 4 * time.Second,
+		4: 5 * time.Second,
+		9: 5 * time.Second,
+	} {
+		msg := &gonats.Msg{
+			Sub:   &gonats.Subscription{},
+			Reply: fmt.Sprintf("$JS.ACK.outbox.consumer.%d.1.1.1700000000000000000.0", delivered),
+		}
+		if got := n.nakDelay(msg); got != want {
+			t.Errorf("nakDelay after %d deliveries = %v, want %v", delivered, got, want)
+		}
+	}
+}
+
+func TestJetStreamStopsRedeliveringAfterMaxAttempts(t *testing.T) {
+	n := newTestNats(t, WithJetStream(true), WithNakDelay(50*time.Millisecond, 50*time.Millisecond), WithMaxAttempts(2))
+	ctx := context.Background()
+
+	var deliveries int32
+	unsub, err := n.Subscribe(ctx, "outbox", func(ev *cloudevents.Event) error {
+		atomic.AddInt32(&deliveries, 1)
+		return errors.New("failed")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unsub.Cancel()
+
+	err = n.Publish(ctx, "outbox", brokertest.NewEvent("1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second)
+
+	if got := atomic.LoadInt32(&deliveries); got != 2 {
+		t.Fatalf("failing event delivered %d times, want 2", got)
+	}
+}
+
+func TestLastAttempt(t *testing.T) {
+	n := &nats{o: &natsOptions{maxAttempts: 3}}
+
+	for delivered, want := range map[int]bool{1: false, 2: false, 3: true, 4: true} {
+		msg := &gonats.Msg{
+			Sub:   &gonats.Subscription{},
+			Reply: fmt.Sprintf("$JS.ACK.outbox.consumer.%d.1.1.1700000000000000000.0", delivered),
+		}
+		if got := n.lastAttempt(msg); got != want {
+			t.Errorf("lastAttempt after %d deliveries = %v, want %v", delivered, got, want)
+		}
+	}
+}
+
+func TestJetStreamAcceptsATinyAckWait(t *testing.T) {
+	n := newTestNats(t, WithJetStream(true), WithAckWait(time.Nanosecond))
+	ctx := context.Background()
+
+	delivered := make(chan struct{}, 1)
+	unsub, err := n.Subscribe(ctx, "outbox", func(ev *cloudevents.Event) error {
+		select {
+		case delivered <- struct{}{}:
+		default:
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unsub.Cancel()
+
+	err = n.Publish(ctx, "outbox", brokertest.NewEvent("1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-delivered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("event was not delivered")
+	}
+}
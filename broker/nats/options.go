@@ -0,0 +1,87 @@
+package nats
+
+import "time"
+
+type natsOptions struct {
+	url       string
+	credsFile string
+
+	jetStream   bool
+	durable     string
+	ackWait     time.Duration
+	nakDelay    time.Duration
+	maxNakDelay time.Duration
+	maxAttempts int
+	maxAge      time.Duration
+	concurrency int
+}
+
+type Option func(o *natsOptions)
+
+func WithURL(url string) Option {
+	return func(o *natsOptions) {
+		o.url = url
+	}
+}
+
+// WithCredsFile authenticates with a user credentials file.
+func WithCredsFile(credsFile string) Option {
+	return func(o *natsOptions) {
+		o.credsFile = credsFile
+	}
+}
+
+// WithJetStream publishes to JetStream streams and subscribes through durable
+// consumers, events are acknowledged once the Subscriber succeeded.
+func WithJetStream(jetStream bool) Option {
+	return func(o *natsOptions) {
+		o.jetStream = jetStream
+	}
+}
+
+// WithDurable sets the queue group, and with JetStream the durable consumer
+// name, shared by the connector instances.
+func WithDurable(durable string) Option {
+	return func(o *natsOptions) {
+		o.durable = durable
+	}
+}
+
+// WithAckWait sets how long JetStream waits for an acknowledgement before
+// redelivering an event.
+func WithAckWait(ackWait time.Duration) Option {
+	return func(o *natsOptions) {
+		o.ackWait = ackWait
+	}
+}
+
+// WithNakDelay sets the redelivery delay of a failed JetStream event, doubled
+// with every delivery up to maxNakDelay.
+func WithNakDelay(nakDelay time.Duration, maxNakDelay time.Duration) Option {
+	return func(o *natsOptions) {
+		o.nakDelay = nakDelay
+		o.maxNakDelay = maxNakDelay
+	}
+}
+
+// WithMaxAttempts sets how many times a JetStream event is delivered before
+// it is terminated, a failing event is not redelivered afterwards.
+func WithMaxAttempts(maxAttempts int) Option {
+	return func(o *natsOptions) {
+		o.maxAttempts = maxAttempts
+	}
+}
+
+// WithMaxAge sets how long the streams created by the broker keep events.
+func WithMaxAge(maxAge time.Duration) Option {
+	return func(o *natsOptions) {
+		o.maxAge = maxAge
+	}
+}
+
+// WithConcurrency sets how many JetStream events are processed at once.
+func WithConcurrency(concurrency int) Option {
+	return func(o *natsOptions) {
+		o.concurrency = concurrency
+	}
+}
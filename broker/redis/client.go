@@ -0,0 +1,84 @@
+package redis
+
+import (
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+const (
+	minBackoff = 100 * time.Millisecond
+	maxBackoff = 30 * time.Second
+)
+
+// HealthHook is told about the connection of the subscription to channel,
+// err is the error that broke it when connected is false.
+type HealthHook func(channel string, connected bool, err error)
+
+func newClient(o *redisOptions) goredis.UniversalClient {
+	if len(o.sentinelMaster) > 0 {
+		return goredis.NewFailoverClient(&goredis.FailoverOptions{
+			MasterName:       o.sentinelMaster,
+			SentinelAddrs:    o.sentinelAddrs,
+			SentinelPassword: o.sentinelPassword,
+			Username:         o.username,
+			Password:         o.password,
+			DB:               o.db,
+			TLSConfig:        o.tlsConfig,
+		})
+	}
+
+	if len(o.clusterAddrs) > 0 {
+		return goredis.NewClusterClient(&goredis.ClusterOptions{
+			Addrs:     o.clusterAddrs,
+			Username:  o.username,
+			Password:  o.password,
+			TLSConfig: o.tlsConfig,
+		})
+	}
+
+	return goredis.NewClient(&goredis.Options{
+		Addr:      o.addr,
+		Username:  o.username,
+		Password:  o.password,
+		DB:        o.db,
+		TLSConfig: o.tlsConfig,
+	})
+}
+
+// health reports the connection state of a subscription to the hook, the
+// first state and then its changes only.
+type health struct {
+	channel   string
+	hook      HealthHook
+	reported  bool
+	connected bool
+}
+
+func (h *health) up() {
+	h.report(true, nil)
+}
+
+func (h *health) down(err error) {
+	h.report(false, err)
+}
+
+func (h *health) report(connected bool, err error) {
+	if h.reported && h.connected == connected {
+		return
+	}
+	h.reported = true
+	h.connected = connected
+	if h.hook != nil {
+		h.hook(h.channel, connected, err)
+	}
+}
+
+// backoff doubles d up to maxBackoff.
+func backoff(d time.Duration) time.Duration {
+	d *= 2
+	if d > maxBackoff {
+		return maxBackoff
+	}
+	return d
+}
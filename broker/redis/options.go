@@ -1,8 +1,32 @@
 package redis
 
+import (
+	"crypto/tls"
+	"time"
+)
+
 type redisOptions struct {
-	addr     string
-	password string
+	addr      string
+	username  string
+	password  string
+	db        int
+	tlsConfig *tls.Config
+
+	// sentinelMaster switches to a Sentinel failover client on sentinelAddrs
+	sentinelMaster   string
+	sentinelAddrs    []string
+	sentinelPassword string
+	// clusterAddrs switches to a Cluster client
+	clusterAddrs []string
+
+	healthHook HealthHook
+
+	// stream options
+	group       string
+	consumer    string
+	maxLen      int64
+	claimIdle   time.Duration
+	concurrency int
 }
 
 type Option func(o *redisOptions)
@@ -18,3 +42,94 @@ func WithPassword(password string) Option {
 		o.password = password
 	}
 }
+
+// WithUsername sets the ACL user, WithPassword is then its password.
+func WithUsername(username string) Option {
+	return func(o *redisOptions) {
+		o.username = username
+	}
+}
+
+// WithDB selects the database, not supported by Cluster.
+func WithDB(db int) Option {
+	return func(o *redisOptions) {
+		o.db = db
+	}
+}
+
+// WithTLSConfig connects over TLS.
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(o *redisOptions) {
+		o.tlsConfig = tlsConfig
+	}
+}
+
+// WithSentinel connects to the master named masterName, discovered through
+// the Sentinels at addrs and followed across failovers.
+func WithSentinel(masterName string, addrs ...string) Option {
+	return func(o *redisOptions) {
+		o.sentinelMaster = masterName
+		o.sentinelAddrs = addrs
+	}
+}
+
+// WithSentinelPassword sets the password of the Sentinels themselves.
+func WithSentinelPassword(password string) Option {
+	return func(o *redisOptions) {
+		o.sentinelPassword = password
+	}
+}
+
+// WithCluster connects to the Redis Cluster seeded by addrs.
+func WithCluster(addrs ...string) Option {
+	return func(o *redisOptions) {
+		o.clusterAddrs = addrs
+	}
+}
+
+// WithHealthHook sets the function called when a subscription loses or
+// regains its connection.
+func WithHealthHook(hook HealthHook) Option {
+	return func(o *redisOptions) {
+		o.healthHook = hook
+	}
+}
+
+// WithGroup sets the consumer group used to read a stream.
+func WithGroup(group string) Option {
+	return func(o *redisOptions) {
+		o.group = group
+	}
+}
+
+// WithConsumer sets the consumer name inside the group, it should be unique
+// per connector instance.
+func WithConsumer(consumer string) Option {
+	return func(o *redisOptions) {
+		o.consumer = consumer
+	}
+}
+
+// WithMaxLen approximately trims a stream to maxLen entries on publish,
+// 0 disables trimming.
+func WithMaxLen(maxLen int64) Option {
+	return func(o *redisOptions) {
+		o.maxLen = maxLen
+	}
+}
+
+// WithClaimIdle sets how long an entry stays pending before it is reclaimed
+// from a crashed or failing consumer, entries still being processed are kept
+// from going idle. Non-positive values keep the default of one minute.
+func WithClaimIdle(idle time.Duration) Option {
+	return func(o *redisOptions) {
+		o.claimIdle = idle
+	}
+}
+
+// WithConcurrency sets how many entries of a stream are processed at once.
+func WithConcurrency(concurrency int) Option {
+	return func(o *redisOptions) {
+		o.concurrency = concurrency
+	}
+}
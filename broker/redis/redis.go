@@ -3,6 +3,7 @@ package redis
 import (
 	"context"
 	"fmt"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
@@ -12,15 +13,16 @@ import (
 )
 
 type unsubscriber struct {
-	pubsub *goredis.PubSub
+	cancel context.CancelFunc
 }
 
 func (s *unsubscriber) Cancel() {
-	s.pubsub.Close()
+	s.cancel()
 }
 
 type redis struct {
-	rdb *goredis.Client
+	rdb goredis.UniversalClient
+	o   *redisOptions
 }
 
 func New(opts ...Option) broker.Broker {
@@ -29,13 +31,9 @@ func New(opts ...Option) broker.Broker {
 		opt(o)
 	}
 
-	rdb := goredis.NewClient(&goredis.Options{
-		Addr:     o.addr,
-		Password: o.password,
-	})
-
 	return &redis{
-		rdb,
+		rdb: newClient(o),
+		o:   o,
 	}
 }
 
@@ -49,23 +47,62 @@ func (r redis) Publish(ctx context.Context, channel string, event *cloudevents.E
 }
 
 func (r *redis) Subscribe(ctx context.Context, channel string, fn broker.Subscriber) (broker.Unsubscriber, error) {
-	// subscribe
-	pubsub := r.rdb.Subscribe(ctx, channel)
+	ctx, cancel := context.WithCancel(ctx)
+
 	go func() {
+		h := &health{channel: channel, hook: r.o.healthHook}
+		delay := minBackoff
 		for {
-			msg, err := pubsub.ReceiveMessage(ctx)
-			if err != nil {
-				log.Printf("receive msg error: %v\n", err)
+			err := r.receive(ctx, channel, h, fn)
+			if ctx.Err() != nil {
+				return
+			}
+
+			// the backoff starts over once a subscription succeeded
+			if h.connected {
+				delay = minBackoff
 			}
-			r.processOneMessage(ctx, msg, fn)
+
+			// subscribe again, possibly to a new master after a failover
+			h.down(err)
+			log.Printf("receive msg error, resubscribing in %v: %v\n", delay, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+			delay = backoff(delay)
 		}
 	}()
 
 	return &unsubscriber{
-		pubsub: pubsub,
+		cancel: cancel,
 	}, nil
 }
 
+// receive subscribes to channel and processes its messages until the
+// subscription breaks or ctx is done.
+func (r *redis) receive(ctx context.Context, channel string, h *health, fn broker.Subscriber) error {
+	pubsub := r.rdb.Subscribe(ctx, channel)
+	defer pubsub.Close()
+
+	// wait for the subscription confirmation
+	_, err := pubsub.Receive(ctx)
+	if err != nil {
+		return err
+	}
+	h.up()
+
+	for {
+		msg, err := pubsub.ReceiveMessage(ctx)
+		if err != nil {
+			return err
+		}
+
+		r.processOneMessage(ctx, msg, fn)
+	}
+}
+
 func (r *redis) processOneMessage(ctx context.Context, msg *goredis.Message, fn broker.Subscriber) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -77,6 +114,7 @@ func (r *redis) processOneMessage(ctx context.Context, msg *goredis.Message, fn
 	err := ev.UnmarshalJSON([]byte(msg.Payload))
 	if err != nil {
 		log.Printf("Unmarshal event error: %v\n", err)
+		return
 	}
 
 	err = fn(&ev)
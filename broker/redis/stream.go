@@ -0,0 +1,343 @@
+package redis
+
+import (
+	"context"
+	"hash/fnv"
+	"os"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/botaas/telegram-bot-connector/broker"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+const (
+	// streamField is the entry field holding the JSON encoded event
+	streamField = "event"
+	readBlock   = 5 * time.Second
+)
+
+// entry is a decoded stream entry.
+type entry struct {
+	id string
+	ev *cloudevents.Event
+}
+
+type streamUnsubscriber struct {
+	cancel context.CancelFunc
+}
+
+func (s *streamUnsubscriber) Cancel() {
+	s.cancel()
+}
+
+// stream is a broker on top of Redis Streams, entries are read through a
+// consumer group and only acknowledged once the Subscriber succeeded.
+type stream struct {
+	rdb goredis.UniversalClient
+	o   *redisOptions
+}
+
+func NewStream(opts ...Option) broker.Broker {
+	hostname, _ := os.Hostname()
+	o := &redisOptions{
+		group:       "telegram-bot-connector",
+		consumer:    hostname,
+		claimIdle:   time.Minute,
+		concurrency: 1,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if o.concurrency < 1 {
+		o.concurrency = 1
+	}
+
+	if o.claimIdle <= 0 {
+		o.claimIdle = time.Minute
+	}
+
+	return &stream{
+		rdb: newClient(o),
+		o:   o,
+	}
+}
+
+func (s *stream) Acknowledges() bool {
+	return true
+}
+
+func (s *stream) Publish(ctx context.Context, channel string, event *cloudevents.Event) error {
+	b, err := event.MarshalJSON()
+	if err != nil {
+		return err
+	}
+
+	args := &goredis.XAddArgs{
+		Stream: channel,
+		Values: map[string]interface{}{streamField: string(b)},
+	}
+	if s.o.maxLen > 0 {
+		args.MaxLen = s.o.maxLen
+		args.Approx = true
+	}
+
+	return s.rdb.XAdd(ctx, args).Err()
+}
+
+func (s *stream) Subscribe(ctx context.Context, channel string, fn broker.Subscriber) (broker.Unsubscriber, error) {
+	err := s.createGroup(ctx, channel)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	entries := make(chan goredis.XMessage)
+	go s.read(ctx, channel, entries)
+	go s.claim(ctx, channel, entries)
+
+	workers := make([]chan entry, s.o.concurrency)
+	for i := range workers {
+		workers[i] = make(chan entry)
+		go func(work <-chan entry) {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case e := <-work:
+					s.processOneEntry(ctx, channel, e, fn)
+				}
+			}
+		}(workers[i])
+	}
+	go s.dispatch(ctx, channel, entries, workers)
+
+	return &streamUnsubscriber{
+		cancel: cancel,
+	}, nil
+}
+
+func (s *stream) createGroup(ctx context.Context, channel string) error {
+	// start from the beginning so entries published before the first
+	// connector ran are processed too
+	err := s.rdb.XGroupCreateMkStream(ctx, channel, s.o.group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+	return nil
+}
+
+// read delivers entries never delivered to any consumer of the group.
+func (s *stream) read(ctx context.Context, channel string, entries chan<- goredis.XMessage) {
+	h := &health{channel: channel, hook: s.o.healthHook}
+	h.up()
+
+	delay := minBackoff
+	for {
+		streams, err := s.rdb.XReadGroup(ctx, &goredis.XReadGroupArgs{
+			Group:    s.o.group,
+			Consumer: s.o.consumer,
+			Streams:  []string{channel, ">"},
+			Count:    int64(s.o.concurrency),
+			Block:    readBlock,
+		}).Result()
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil && err != goredis.Nil {
+			h.down(err)
+			log.Printf("read stream %s error, retrying in %v: %v\n", channel, delay, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+			delay = backoff(delay)
+
+			// the group is missing after a failover to a replica that never saw it
+			if strings.HasPrefix(err.Error(), "NOGROUP") {
+				err = s.createGroup(ctx, channel)
+				if err != nil {
+					log.Printf("create group of stream %s error: %v\n", channel, err)
+				}
+			}
+			continue
+		}
+
+		h.up()
+		delay = minBackoff
+
+		for _, st := range streams {
+			for _, msg := range st.Messages {
+				select {
+				case <-ctx.Done():
+					return
+				case entries <- msg:
+				}
+			}
+		}
+	}
+}
+
+// claim periodically takes over entries pending longer than claimIdle, they
+// belong to a consumer that crashed or to a Subscriber that failed.
+func (s *stream) claim(ctx context.Context, channel string, entries chan<- goredis.XMessage) {
+	ticker := time.NewTicker(s.o.claimIdle)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		start := "0-0"
+		for {
+			msgs, next, err := s.rdb.XAutoClaim(ctx, &goredis.XAutoClaimArgs{
+				Stream:   channel,
+				Group:    s.o.group,
+				MinIdle:  s.o.claimIdle,
+				Start:    start,
+				Count:    int64(s.o.concurrency),
+				Consumer: s.o.consumer,
+			}).Result()
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("claim stream %s error: %v\n", channel, err)
+				}
+				break
+			}
+
+			for _, msg := range msgs {
+				select {
+				case <-ctx.Done():
+					return
+				case entries <- msg:
+				}
+			}
+
+			if next == "0-0" {
+				break
+			}
+			start = next
+		}
+	}
+}
+
+// dispatch decodes the entries and hands the ones of a subject, the chat id,
+// to the same worker so that the events of a chat are processed one at a time
+// and in order. Entries without subject are spread over the workers.
+func (s *stream) dispatch(ctx context.Context, channel string, entries <-chan goredis.XMessage, workers []chan entry) {
+	next := 0
+	for {
+		var msg goredis.XMessage
+		select {
+		case <-ctx.Done():
+			return
+		case msg = <-entries:
+		}
+
+		ev := cloudevents.NewEvent()
+		payload, _ := msg.Values[streamField].(string)
+		err := ev.UnmarshalJSON([]byte(payload))
+		if err != nil {
+			// redelivering will not make it decodable, drop it
+			log.Printf("Unmarshal event %s error: %v\n", msg.ID, err)
+			s.ack(ctx, channel, msg.ID)
+			continue
+		}
+
+		var i int
+		if subject := ev.Subject(); len(subject) > 0 {
+			h := fnv.New32a()
+			_, _ = h.Write([]byte(subject))
+			i = int(h.Sum32() % uint32(len(workers)))
+		} else {
+			i = next
+			next = (next + 1) % len(workers)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case workers[i] <- entry{id: msg.ID, ev: &ev}:
+		}
+	}
+}
+
+func (s *stream) processOneEntry(ctx context.Context, channel string, e entry, fn broker.Subscriber) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("event %s process panic, left pending: %v", e.id, r)
+		}
+	}()
+
+	// a parked or backing off event may take longer than claimIdle, keep it
+	// from being reclaimed while it is still processed
+	stop := s.keepAlive(ctx, channel, e.id)
+	defer stop()
+
+	err := fn(e.ev)
+	if err != nil {
+		log.Printf("event %s process error, left pending: %v\n", e.id, err)
+		return
+	}
+
+	s.ack(ctx, channel, e.id)
+}
+
+// keepAlive resets the idle time of the pending entry id until the returned
+// function is called.
+func (s *stream) keepAlive(ctx context.Context, channel string, id string) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		interval := s.o.claimIdle / 3
+		if interval <= 0 {
+			interval = s.o.claimIdle
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			// claiming its own entry with JUSTID only resets the idle time
+			err := s.rdb.XClaimJustID(ctx, &goredis.XClaimArgs{
+				Stream:   channel,
+				Group:    s.o.group,
+				Consumer: s.o.consumer,
+				MinIdle:  0,
+				Messages: []string{id},
+			}).Err()
+			if err != nil && ctx.Err() == nil {
+				log.Printf("keep event %s pending error: %v\n", id, err)
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func (s *stream) ack(ctx context.Context, channel string, id string) {
+	err := s.rdb.XAck(ctx, channel, s.o.group, id).Err()
+	if err != nil {
+		log.Printf("ack event %s error: %v\n", id, err)
+	}
+}
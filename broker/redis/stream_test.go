@@ -0,0 +1,170 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/botaas/telegram-bot-connector/broker"
+	"github.com/botaas/telegram-bot-connector/internal/brokertest"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+func newTestStream(t *testing.T, opts ...Option) *stream {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	opts = append([]Option{WithAddr(mr.Addr()), WithConsumer("test")}, opts...)
+	return NewStream(opts...).(*stream)
+}
+
+func TestStreamConforms(t *testing.T) {
+	brokertest.Run(t, func(t *testing.T) broker.Broker {
+		return newTestStream(t)
+	})
+}
+
+func TestStreamDeliversEntriesPublishedBeforeSubscribe(t *testing.T) {
+	s := newTestStream(t)
+	ctx := context.Background()
+
+	err := s.Publish(ctx, "outbox", brokertest.NewEvent("1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received, cancel := brokertest.Subscribe(t, ctx, s, "outbox")
+	defer cancel()
+	brokertest.ExpectEvent(t, received, "1")
+}
+
+func TestStreamDoesNotReclaimEntriesInProgress(t *testing.T) {
+	s := newTestStream(t, WithClaimIdle(200*time.Millisecond))
+	ctx := context.Background()
+
+	var deliveries int32
+	done := make(chan struct{})
+	unsub, err := s.Subscribe(ctx, "outbox", func(ev *cloudevents.Event) error {
+		// longer than claimIdle, like a parked or backing off event
+		if atomic.AddInt32(&deliveries, 1) == 1 {
+			time.Sleep(time.Second)
+			close(done)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unsub.Cancel()
+
+	err = s.Publish(ctx, "outbox", brokertest.NewEvent("1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("event was not delivered")
+	}
+	time.Sleep(500 * time.Millisecond)
+
+	if n := atomic.LoadInt32(&deliveries); n != 1 {
+		t.Fatalf("event delivered %d times, want 1", n)
+	}
+}
+
+func TestStreamRedeliversFailedEntries(t *testing.T) {
+	s := newTestStream(t, WithClaimIdle(200*time.Millisecond))
+	ctx := context.Background()
+
+	var deliveries int32
+	done := make(chan struct{})
+	unsub, err := s.Subscribe(ctx, "outbox", func(ev *cloudevents.Event) error {
+		if atomic.AddInt32(&deliveries, 1) == 1 {
+			return errors.New("failed")
+		}
+		close(done)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unsub.Cancel()
+
+	err = s.Publish(ctx, "outbox", brokertest.NewEvent("1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("failed event was not redelivered")
+	}
+}
+
+func TestStreamKeepsTheOrderOfAChat(t *testing.T) {
+	s := newTestStream(t, WithConcurrency(4))
+	ctx := context.Background()
+
+	const n = 20
+	for i := 0; i < n; i++ {
+		ev := brokertest.NewEvent(strconv.Itoa(i))
+		ev.SetSubject("42")
+		err := s.Publish(ctx, "outbox", ev)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var mu sync.Mutex
+	var ids []string
+	var inFlight int32
+	done := make(chan struct{})
+	unsub, err := s.Subscribe(ctx, "outbox", func(ev *cloudevents.Event) error {
+		if atomic.AddInt32(&inFlight, 1) > 1 {
+			t.Error("events of a chat processed concurrently")
+		}
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+
+		mu.Lock()
+		defer mu.Unlock()
+		ids = append(ids, ev.ID())
+		if len(ids) == n {
+			close(done)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unsub.Cancel()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("events were not delivered")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for i, id := range ids {
+		if id != strconv.Itoa(i) {
+			t.Fatalf("processed %v, want the publish order", ids)
+		}
+	}
+}
+
+func TestNewStreamDefaultsNonPositiveClaimIdle(t *testing.T) {
+	s := newTestStream(t, WithClaimIdle(0))
+	if s.o.claimIdle != time.Minute {
+		t.Fatalf("claimIdle = %v, want 1m", s.o.claimIdle)
+	}
+}
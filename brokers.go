@@ -0,0 +1,394 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"expvar"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/botaas/telegram-bot-connector/broker"
+	"github.com/botaas/telegram-bot-connector/broker/amqp"
+	"github.com/botaas/telegram-bot-connector/broker/http"
+	"github.com/botaas/telegram-bot-connector/broker/kafka"
+	"github.com/botaas/telegram-bot-connector/broker/memory"
+	"github.com/botaas/telegram-bot-connector/broker/nats"
+	"github.com/botaas/telegram-bot-connector/broker/redis"
+)
+
+// newBroker connects to the broker selected by BROKER, redis by default.
+func newBroker(concurrency int) broker.Broker {
+	name, _ := os.LookupEnv("BROKER")
+	switch name {
+	case "", "redis":
+		return newRedisBroker(concurrency)
+	case "nats":
+		return newNatsBroker(concurrency)
+	case "kafka":
+		return newKafkaBroker(concurrency)
+	case "amqp":
+		return newAmqpBroker(concurrency)
+	case "http":
+		return newHttpBroker()
+	case "memory":
+		// only useful when the backend runs in the same process
+		log.Warn("memory broker, events are not shared with other processes")
+		return memory.New()
+	}
+
+	log.Fatalf("unknown broker: %s", name)
+	return nil
+}
+
+// redisConnected reports, per channel, whether its Redis subscription is
+// connected.
+var redisConnected = expvar.NewMap("redis_connected")
+
+func newRedisBroker(concurrency int) broker.Broker {
+	opts := redisOptionsFromEnv()
+
+	redisMode, _ := os.LookupEnv("REDIS_MODE")
+	switch redisMode {
+	case "", "pubsub":
+		return redis.New(opts...)
+	case "stream":
+		opts = append(opts, redis.WithConcurrency(concurrency))
+
+		if group, exist := os.LookupEnv("REDIS_STREAM_GROUP"); exist && group != "" {
+			opts = append(opts, redis.WithGroup(group))
+		}
+
+		if consumer, exist := os.LookupEnv("REDIS_STREAM_CONSUMER"); exist && consumer != "" {
+			opts = append(opts, redis.WithConsumer(consumer))
+		}
+
+		if maxLenStr, exist := os.LookupEnv("REDIS_STREAM_MAXLEN"); exist {
+			maxLen, err := strconv.ParseInt(maxLenStr, 10, 64)
+			if err != nil {
+				log.Fatalf("invalid REDIS_STREAM_MAXLEN: %v", err)
+			}
+			opts = append(opts, redis.WithMaxLen(maxLen))
+		}
+
+		if claimIdleStr, exist := os.LookupEnv("REDIS_STREAM_CLAIM_IDLE"); exist {
+			claimIdle, err := time.ParseDuration(claimIdleStr)
+			if err != nil {
+				log.Fatalf("invalid REDIS_STREAM_CLAIM_IDLE: %v", err)
+			}
+			if claimIdle <= 0 {
+				log.Fatalf("invalid REDIS_STREAM_CLAIM_IDLE: %v, must be greater than 0", claimIdle)
+			}
+			opts = append(opts, redis.WithClaimIdle(claimIdle))
+		}
+
+		return redis.NewStream(opts...)
+	}
+
+	log.Fatalf("unknown redis mode: %s", redisMode)
+	return nil
+}
+
+// redisOptionsFromEnv reads the connection options shared by both redis modes.
+func redisOptionsFromEnv() []redis.Option {
+	opts := []redis.Option{
+		redis.WithHealthHook(func(channel string, connected bool, err error) {
+			if connected {
+				log.Infof("redis subscription %s connected", channel)
+				redisConnected.Set(channel, expvarBool(true))
+			} else {
+				log.Warnf("redis subscription %s disconnected: %v", channel, err)
+				redisConnected.Set(channel, expvarBool(false))
+			}
+		}),
+	}
+
+	sentinelMaster, _ := os.LookupEnv("REDIS_SENTINEL_MASTER")
+	sentinelAddrs, _ := os.LookupEnv("REDIS_SENTINEL_ADDRS")
+	clusterAddrs, _ := os.LookupEnv("REDIS_CLUSTER_ADDRS")
+	switch {
+	case sentinelMaster != "":
+		if sentinelAddrs == "" {
+			log.Fatal("redis sentinel addrs not provide")
+		}
+		opts = append(opts, redis.WithSentinel(sentinelMaster, strings.Split(sentinelAddrs, ",")...))
+
+		if sentinelPassword, exist := os.LookupEnv("REDIS_SENTINEL_PASSWORD"); exist && sentinelPassword != "" {
+			opts = append(opts, redis.WithSentinelPassword(sentinelPassword))
+		}
+	case clusterAddrs != "":
+		opts = append(opts, redis.WithCluster(strings.Split(clusterAddrs, ",")...))
+	default:
+		addr, exist := os.LookupEnv("REDIS_ADDR")
+		if !exist || addr == "" {
+			log.Fatal("redis addr not provide")
+		}
+		opts = append(opts, redis.WithAddr(addr))
+	}
+
+	if username, exist := os.LookupEnv("REDIS_USERNAME"); exist && username != "" {
+		opts = append(opts, redis.WithUsername(username))
+	}
+
+	redisPassword, _ := os.LookupEnv("REDIS_PASSWORD")
+	opts = append(opts, redis.WithPassword(redisPassword))
+
+	if dbStr, exist := os.LookupEnv("REDIS_DB"); exist {
+		db, err := strconv.Atoi(dbStr)
+		if err != nil {
+			log.Fatalf("invalid REDIS_DB: %v", err)
+		}
+		opts = append(opts, redis.WithDB(db))
+	}
+
+	if tlsStr, exist := os.LookupEnv("REDIS_TLS"); exist {
+		useTLS, err := strconv.ParseBool(tlsStr)
+		if err != nil {
+			log.Fatalf("invalid REDIS_TLS: %v", err)
+		}
+		if useTLS {
+			tlsConfig := &tls.Config{
+				MinVersion: tls.VersionTLS12,
+			}
+
+			if caFile, exist := os.LookupEnv("REDIS_TLS_CA_FILE"); exist && caFile != "" {
+				ca, err := os.ReadFile(caFile)
+				if err != nil {
+					log.Fatalf("invalid REDIS_TLS_CA_FILE: %v", err)
+				}
+				tlsConfig.RootCAs = x509.NewCertPool()
+				if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+					log.Fatalf("invalid REDIS_TLS_CA_FILE: no certificate found")
+				}
+			}
+
+			opts = append(opts, redis.WithTLSConfig(tlsConfig))
+		}
+	}
+
+	return opts
+}
+
+type expvarBool bool
+
+func (b expvarBool) String() string {
+	return strconv.FormatBool(bool(b))
+}
+
+func newNatsBroker(concurrency int) broker.Broker {
+	opts := []nats.Option{
+		nats.WithConcurrency(concurrency),
+	}
+
+	if url, exist := os.LookupEnv("NATS_URL"); exist && url != "" {
+		opts = append(opts, nats.WithURL(url))
+	}
+
+	if credsFile, exist := os.LookupEnv("NATS_CREDS_FILE"); exist && credsFile != "" {
+		opts = append(opts, nats.WithCredsFile(credsFile))
+	}
+
+	if durable, exist := os.LookupEnv("NATS_DURABLE"); exist && durable != "" {
+		opts = append(opts, nats.WithDurable(durable))
+	}
+
+	if jetStreamStr, exist := os.LookupEnv("NATS_JETSTREAM"); exist {
+		jetStream, err := strconv.ParseBool(jetStreamStr)
+		if err != nil {
+			log.Fatalf("invalid NATS_JETSTREAM: %v", err)
+		}
+		opts = append(opts, nats.WithJetStream(jetStream))
+	}
+
+	if ackWaitStr, exist := os.LookupEnv("NATS_ACK_WAIT"); exist {
+		ackWait, err := time.ParseDuration(ackWaitStr)
+		if err != nil {
+			log.Fatalf("invalid NATS_ACK_WAIT: %v", err)
+		}
+		if ackWait <= 0 {
+			log.Fatalf("invalid NATS_ACK_WAIT: %v, must be greater than 0", ackWait)
+		}
+		opts = append(opts, nats.WithAckWait(ackWait))
+	}
+
+	nakDelay, maxNakDelay := time.Second, time.Minute
+	if nakDelayStr, exist := os.LookupEnv("NATS_NAK_DELAY"); exist {
+		var err error
+		nakDelay, err = time.ParseDuration(nakDelayStr)
+		if err != nil {
+			log.Fatalf("invalid NATS_NAK_DELAY: %v", err)
+		}
+	}
+
+	if maxNakDelayStr, exist := os.LookupEnv("NATS_MAX_NAK_DELAY"); exist {
+		var err error
+		maxNakDelay, err = time.ParseDuration(maxNakDelayStr)
+		if err != nil {
+			log.Fatalf("invalid NATS_MAX_NAK_DELAY: %v", err)
+		}
+	}
+	opts = append(opts, nats.WithNakDelay(nakDelay, maxNakDelay))
+
+	if maxAttemptsStr, exist := os.LookupEnv("NATS_MAX_ATTEMPTS"); exist {
+		maxAttempts, err := strconv.Atoi(maxAttemptsStr)
+		if err != nil {
+			log.Fatalf("invalid NATS_MAX_ATTEMPTS: %v", err)
+		}
+		opts = append(opts, nats.WithMaxAttempts(maxAttempts))
+	}
+
+	if maxAgeStr, exist := os.LookupEnv("NATS_MAX_AGE"); exist {
+		maxAge, err := time.ParseDuration(maxAgeStr)
+		if err != nil {
+			log.Fatalf("invalid NATS_MAX_AGE: %v", err)
+		}
+		opts = append(opts, nats.WithMaxAge(maxAge))
+	}
+
+	b, err := nats.New(opts...)
+	if err != nil {
+		log.Fatalf("Couldn't connect to nats: %v", err)
+	}
+
+	return b
+}
+
+func newKafkaBroker(concurrency int) broker.Broker {
+	opts := []kafka.Option{
+		kafka.WithConcurrency(concurrency),
+	}
+
+	if brokers, exist := os.LookupEnv("KAFKA_BROKERS"); exist && brokers != "" {
+		opts = append(opts, kafka.WithBrokers(strings.Split(brokers, ",")...))
+	}
+
+	if groupID, exist := os.LookupEnv("KAFKA_GROUP_ID"); exist && groupID != "" {
+		opts = append(opts, kafka.WithGroupID(groupID))
+	}
+
+	if mode, exist := os.LookupEnv("KAFKA_MODE"); exist && mode != "" {
+		if mode != kafka.StructuredMode && mode != kafka.BinaryMode {
+			log.Fatalf("invalid KAFKA_MODE: %s", mode)
+		}
+		opts = append(opts, kafka.WithMode(mode))
+	}
+
+	if maxAttemptsStr, exist := os.LookupEnv("KAFKA_MAX_ATTEMPTS"); exist {
+		maxAttempts, err := strconv.Atoi(maxAttemptsStr)
+		if err != nil {
+			log.Fatalf("invalid KAFKA_MAX_ATTEMPTS: %v", err)
+		}
+		opts = append(opts, kafka.WithMaxAttempts(maxAttempts))
+	}
+
+	// records that keep failing go where the connector dead letters events
+	deadLetter, exist := os.LookupEnv("KAFKA_DEAD_LETTER")
+	if !exist {
+		deadLetter = os.Getenv("DEAD_LETTER")
+	}
+	opts = append(opts, kafka.WithDeadLetter(deadLetter))
+
+	return kafka.New(opts...)
+}
+
+func newAmqpBroker(concurrency int) broker.Broker {
+	opts := []amqp.Option{
+		amqp.WithConcurrency(concurrency),
+	}
+
+	if url, exist := os.LookupEnv("AMQP_URL"); exist && url != "" {
+		opts = append(opts, amqp.WithURL(url))
+	}
+
+	if exchange, exist := os.LookupEnv("AMQP_EXCHANGE"); exist && exchange != "" {
+		opts = append(opts, amqp.WithExchange(exchange))
+	}
+
+	if delayStr, exist := os.LookupEnv("AMQP_RECONNECT_DELAY"); exist {
+		delay, err := time.ParseDuration(delayStr)
+		if err != nil {
+			log.Fatalf("invalid AMQP_RECONNECT_DELAY: %v", err)
+		}
+		if delay <= 0 {
+			log.Fatalf("invalid AMQP_RECONNECT_DELAY: %v, must be greater than 0", delay)
+		}
+		opts = append(opts, amqp.WithReconnectDelay(delay))
+	}
+
+	if maxAttemptsStr, exist := os.LookupEnv("AMQP_MAX_ATTEMPTS"); exist {
+		maxAttempts, err := strconv.Atoi(maxAttemptsStr)
+		if err != nil {
+			log.Fatalf("invalid AMQP_MAX_ATTEMPTS: %v", err)
+		}
+		opts = append(opts, amqp.WithMaxAttempts(maxAttempts))
+	}
+
+	if delayStr, exist := os.LookupEnv("AMQP_RETRY_DELAY"); exist {
+		delay, err := time.ParseDuration(delayStr)
+		if err != nil {
+			log.Fatalf("invalid AMQP_RETRY_DELAY: %v", err)
+		}
+		opts = append(opts, amqp.WithRetryDelay(delay))
+	}
+
+	b, err := amqp.New(opts...)
+	if err != nil {
+		log.Fatalf("Couldn't connect to amqp: %v", err)
+	}
+
+	return b
+}
+
+func newHttpBroker() broker.Broker {
+	var opts []http.Option
+
+	// inbox and dead lettered events go to their own endpoints, so that a
+	// dead lettered event cannot pass for a new inbound one
+	if url, exist := os.LookupEnv("HTTP_BROKER_URL"); exist && url != "" {
+		opts = append(opts, http.WithURL(os.Getenv("INBOX"), url))
+	}
+
+	if url, exist := os.LookupEnv("HTTP_BROKER_DEAD_LETTER_URL"); exist && url != "" {
+		deadLetter, _ := os.LookupEnv("DEAD_LETTER")
+		if deadLetter == "" {
+			log.Fatal("HTTP_BROKER_DEAD_LETTER_URL requires DEAD_LETTER")
+		}
+		opts = append(opts, http.WithURL(deadLetter, url))
+	}
+
+	if addr, exist := os.LookupEnv("HTTP_BROKER_LISTEN_ADDR"); exist && addr != "" {
+		opts = append(opts, http.WithListenAddr(addr))
+	}
+
+	if secret, exist := os.LookupEnv("HTTP_BROKER_SECRET"); exist && secret != "" {
+		opts = append(opts, http.WithSecret(secret))
+	}
+
+	if mode, exist := os.LookupEnv("HTTP_BROKER_MODE"); exist && mode != "" {
+		if mode != http.StructuredMode && mode != http.BinaryMode {
+			log.Fatalf("invalid HTTP_BROKER_MODE: %s", mode)
+		}
+		opts = append(opts, http.WithMode(mode))
+	}
+
+	if maxAttemptsStr, exist := os.LookupEnv("HTTP_BROKER_MAX_ATTEMPTS"); exist {
+		maxAttempts, err := strconv.Atoi(maxAttemptsStr)
+		if err != nil {
+			log.Fatalf("invalid HTTP_BROKER_MAX_ATTEMPTS: %v", err)
+		}
+		opts = append(opts, http.WithMaxAttempts(maxAttempts))
+	}
+
+	if timeoutStr, exist := os.LookupEnv("HTTP_BROKER_TIMEOUT"); exist {
+		timeout, err := time.ParseDuration(timeoutStr)
+		if err != nil {
+			log.Fatalf("invalid HTTP_BROKER_TIMEOUT: %v", err)
+		}
+		opts = append(opts, http.WithTimeout(timeout))
+	}
+
+	return http.New(opts...)
+}
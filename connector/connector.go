@@ -0,0 +1,731 @@
+// Package connector relays the updates of a Telegram bot to the inbox channel
+// of a broker and sends the events of its outbox channel to Telegram.
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/botaas/telegram-bot-connector/bot"
+	"github.com/botaas/telegram-bot-connector/broker"
+	"github.com/botaas/telegram-bot-connector/converter"
+	"github.com/botaas/telegram-bot-connector/event"
+	"github.com/botaas/telegram-bot-connector/models"
+	"github.com/botaas/telegram-bot-connector/retry"
+	"github.com/botaas/telegram-bot-connector/scheduler"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	log "github.com/sirupsen/logrus"
+)
+
+// Config configures a Connector, start from DefaultConfig.
+type Config struct {
+	// Inbox is the channel updates are published to.
+	Inbox string
+	// Outbox is the channel of the events to send.
+	Outbox string
+	// DeadLetter is the channel events that failed for good are published
+	// to, empty to drop them.
+	DeadLetter string
+
+	// Concurrency is the number of outbox and update workers.
+	Concurrency int
+	// OutboxQueueSize is the number of outbox events queued per worker.
+	OutboxQueueSize int
+
+	// messages per minute, overall and per chat
+	RateLimit            int
+	PrivateChatRateLimit int
+	GroupChatRateLimit   int
+
+	RetryPolicy retry.Policy
+
+	// MessageFormat is the format of texts and captions sent without parse
+	// mode or entities, empty for plain text.
+	MessageFormat string
+
+	// CallbackQueryAutoAck answers callback queries left unanswered empty
+	// after the duration, 0 to never.
+	CallbackQueryAutoAck time.Duration
+
+	// PreCheckoutBackend waits for answer_pre_checkout_query events instead
+	// of accepting every pre-checkout query.
+	PreCheckoutBackend      bool
+	PreCheckoutTimeout      time.Duration
+	PreCheckoutDefault      bool
+	PreCheckoutErrorMessage string
+
+	// DeliveryReceipts publishes message_sent or message_failed to the inbox
+	// for every outbox event.
+	DeliveryReceipts bool
+
+	// TranscriptionTimeout bounds the download and transcription of a voice
+	// message, the following updates of its worker wait meanwhile.
+	TranscriptionTimeout time.Duration
+}
+
+// DefaultConfig returns the defaults, Telegram allows about 30 messages per
+// second overall, 1 per second in a private chat and 20 per minute in a group.
+func DefaultConfig() Config {
+	return Config{
+		Concurrency:          8,
+		OutboxQueueSize:      100,
+		RateLimit:            1800,
+		PrivateChatRateLimit: 60,
+		GroupChatRateLimit:   20,
+		RetryPolicy: retry.Policy{
+			MaxAttempts: 3,
+			BaseDelay:   time.Second,
+			MaxDelay:    time.Minute,
+		},
+		// Telegram cancels the checkout after 10 seconds
+		PreCheckoutTimeout:      8 * time.Second,
+		PreCheckoutErrorMessage: "The order could not be confirmed, please try again later.",
+		DeliveryReceipts:        true,
+		TranscriptionTimeout:    30 * time.Second,
+	}
+}
+
+// outboxJob is an outbox event queued for a worker, done receives the
+// processing result when the broker waits for it.
+type outboxJob struct {
+	ev       *cloudevents.Event
+	chatID   int64
+	attempts int
+	done     chan error
+}
+
+type Connector struct {
+	bot    *bot.Bot
+	broker broker.Broker
+	cfg    Config
+
+	eventManager                  *event.EventManager
+	answerCallbackQueryHandler    *event.AnswerCallbackQueryHandler
+	answerPreCheckoutQueryHandler *event.AnswerPreCheckoutQueryHandler
+	scheduler                     *scheduler.Scheduler
+
+	outboxChans  []chan *outboxJob
+	updateChans  []chan *bot.Update
+	unsubscriber broker.Unsubscriber
+}
+
+func New(b *bot.Bot, br broker.Broker, cfg Config) *Connector {
+	c := &Connector{
+		bot:    b,
+		broker: br,
+		cfg:    cfg,
+		scheduler: scheduler.New(
+			rate.Every(time.Minute/time.Duration(cfg.RateLimit)),
+			rate.Every(time.Minute/time.Duration(cfg.PrivateChatRateLimit)),
+			rate.Every(time.Minute/time.Duration(cfg.GroupChatRateLimit)),
+		),
+	}
+
+	c.eventManager = event.New()
+	c.eventManager.RegisterHandler("message", &event.MessageHandler{
+		Bot:    b,
+		Format: cfg.MessageFormat,
+	})
+	c.eventManager.RegisterHandler("chat_action", &event.ChatActionHandler{
+		Bot: b,
+	})
+	c.eventManager.RegisterHandler("edit_message_text", &event.EditMessageTextHandler{
+		Bot:    b,
+		Format: cfg.MessageFormat,
+	})
+	c.eventManager.RegisterHandler("edit_message_caption", &event.EditMessageCaptionHandler{
+		Bot:    b,
+		Format: cfg.MessageFormat,
+	})
+	c.eventManager.RegisterHandler("edit_message_reply_markup", &event.EditMessageReplyMarkupHandler{
+		Bot: b,
+	})
+	c.eventManager.RegisterHandler("edit_message_media", &event.EditMessageMediaHandler{
+		Bot:    b,
+		Format: cfg.MessageFormat,
+	})
+	c.eventManager.RegisterHandler("edit_message_live_location", &event.EditMessageLiveLocationHandler{
+		Bot: b,
+	})
+	c.eventManager.RegisterHandler("stop_message_live_location", &event.StopMessageLiveLocationHandler{
+		Bot: b,
+	})
+	c.eventManager.RegisterHandler("stop_poll", &event.StopPollHandler{
+		Bot: b,
+	})
+	c.eventManager.RegisterHandler("delete_message", &event.DeleteMessageHandler{
+		Bot: b,
+	})
+	c.eventManager.RegisterHandler("answer_inline_query", &event.AnswerInlineQueryHandler{
+		Bot: b,
+	})
+
+	// callback queries left unanswered are answered empty after the deadline
+	c.answerCallbackQueryHandler = &event.AnswerCallbackQueryHandler{
+		Bot:     b,
+		AutoAck: cfg.CallbackQueryAutoAck,
+	}
+	c.eventManager.RegisterHandler("answer_callback_query", c.answerCallbackQueryHandler)
+
+	// in backend mode pre-checkout queries wait for answer_pre_checkout_query
+	c.answerPreCheckoutQueryHandler = &event.AnswerPreCheckoutQueryHandler{
+		Bot: b,
+	}
+	c.eventManager.RegisterHandler("answer_pre_checkout_query", c.answerPreCheckoutQueryHandler)
+	c.eventManager.RegisterHandler("answer_shipping_query", &event.AnswerShippingQueryHandler{
+		Bot: b,
+	})
+	c.eventManager.RegisterHandler("approve_chat_join_request", &event.ChatJoinRequestHandler{
+		Bot: b,
+	})
+	c.eventManager.RegisterHandler("decline_chat_join_request", &event.ChatJoinRequestHandler{
+		Bot:     b,
+		Decline: true,
+	})
+	c.eventManager.RegisterHandler("message_chunk", &event.StreamHandler{
+		Bot:       b,
+		Scheduler: c.scheduler,
+	})
+
+	c.outboxChans = make([]chan *outboxJob, cfg.Concurrency)
+	c.updateChans = make([]chan *bot.Update, cfg.Concurrency)
+	for i := 0; i < cfg.Concurrency; i++ {
+		c.outboxChans[i] = make(chan *outboxJob, cfg.OutboxQueueSize)
+		c.updateChans[i] = make(chan *bot.Update, 1)
+	}
+
+	return c
+}
+
+func acknowledges(b broker.Broker) bool {
+	a, ok := b.(broker.Acknowledger)
+	return ok && a.Acknowledges()
+}
+
+// shard maps a chat id, negative for groups and channels, to a worker.
+func shard(chatID int64, concurrency int) int {
+	i := chatID % int64(concurrency)
+	if i < 0 {
+		i = -i
+	}
+
+	return int(i)
+}
+
+// Start subscribes to the outbox and starts the workers sending its events
+// and publishing the updates given to Run.
+func (c *Connector) Start(ctx context.Context) error {
+	for i := 0; i < c.cfg.Concurrency; i++ {
+		go c.sendOutbox(ctx, i)
+		go c.publishUpdates(ctx, i)
+	}
+
+	// brokers with acknowledgements only settle an event once it was processed
+	waitProcessed := acknowledges(c.broker)
+
+	unsubscriber, err := c.broker.Subscribe(ctx, c.cfg.Outbox, func(ev *cloudevents.Event) error {
+		log.Printf("outbox event: %v\n", string(ev.Data()))
+
+		job := &outboxJob{ev: ev}
+		if waitProcessed {
+			job.done = make(chan error, 1)
+		}
+
+		// events of a chat always go to the same worker so that they are sent in order
+		var i int
+		chatID, exist := event.ChatID(ev)
+		if exist {
+			job.chatID = chatID
+			i = shard(chatID, c.cfg.Concurrency)
+		} else {
+			// answers to queries have no chat, they keep scheduler.NoChat
+			i = rand.Intn(c.cfg.Concurrency)
+		}
+		c.outboxChans[i] <- job
+
+		if job.done == nil {
+			return nil
+		}
+
+		return <-job.done
+	})
+	if err != nil {
+		return err
+	}
+
+	c.unsubscriber = unsubscriber
+	return nil
+}
+
+// Stop unsubscribes from the outbox.
+func (c *Connector) Stop() {
+	if c.unsubscriber != nil {
+		c.unsubscriber.Cancel()
+	}
+}
+
+// QueueDepths returns the number of outbox events queued per worker.
+func (c *Connector) QueueDepths() []int {
+	depths := make([]int, len(c.outboxChans))
+	for i, ch := range c.outboxChans {
+		depths[i] = len(ch)
+	}
+	return depths
+}
+
+// Run hands the updates to the workers until updates is closed, the updates
+// of a chat or user always go to the same worker.
+func (c *Connector) Run(updates bot.UpdatesChannel) {
+	for update := range updates {
+		// the loop variable is reused, each worker gets its own copy
+		update := update
+		i := 0
+		if update.PreCheckoutQuery != nil {
+			i = shard(update.PreCheckoutQuery.From.ID, c.cfg.Concurrency)
+		}
+
+		if update.ShippingQuery != nil {
+			i = shard(update.ShippingQuery.From.ID, c.cfg.Concurrency)
+		}
+
+		if update.CallbackQuery != nil {
+			i = shard(update.CallbackQuery.From.ID, c.cfg.Concurrency)
+		}
+
+		if update.InlineQuery != nil {
+			i = shard(update.InlineQuery.From.ID, c.cfg.Concurrency)
+		}
+
+		if update.ChosenInlineResult != nil {
+			i = shard(update.ChosenInlineResult.From.ID, c.cfg.Concurrency)
+		}
+
+		if update.Message != nil {
+			i = shard(update.Message.Chat.ID, c.cfg.Concurrency)
+		}
+
+		if update.EditedMessage != nil {
+			i = shard(update.EditedMessage.Chat.ID, c.cfg.Concurrency)
+		}
+
+		if update.ChannelPost != nil {
+			i = shard(update.ChannelPost.Chat.ID, c.cfg.Concurrency)
+		}
+
+		if update.EditedChannelPost != nil {
+			i = shard(update.EditedChannelPost.Chat.ID, c.cfg.Concurrency)
+		}
+
+		if update.MyChatMember != nil {
+			i = shard(update.MyChatMember.Chat.ID, c.cfg.Concurrency)
+		}
+
+		if update.ChatMember != nil {
+			i = shard(update.ChatMember.Chat.ID, c.cfg.Concurrency)
+		}
+
+		if update.ChatJoinRequest != nil {
+			i = shard(update.ChatJoinRequest.Chat.ID, c.cfg.Concurrency)
+		}
+
+		if update.PollAnswer != nil {
+			i = shard(update.PollAnswer.User.ID, c.cfg.Concurrency)
+		}
+
+		c.updateChans[i] <- &update
+	}
+}
+
+func (c *Connector) sendOutbox(ctx context.Context, index int) {
+	for job := range c.outboxChans[index] {
+		job := job
+		// a chat over its rate or paused is parked, the worker goes on with the next chats
+		c.scheduler.Schedule(job.chatID, func() {
+			c.processJob(ctx, job, index)
+		})
+	}
+}
+
+func (c *Connector) processJob(ctx context.Context, job *outboxJob, index int) {
+	err := c.scheduler.Wait(ctx)
+	if err != nil {
+		// stopping, the broker redelivers the event to the next connector
+		if job.done != nil {
+			job.done <- err
+		}
+		return
+	}
+
+	sent, err := c.eventManager.Process(ctx, job.ev)
+
+	// Too Many Requests, hold the chat back and retry, unless part of
+	// the event was already sent
+	var tgErr *tgbotapi.Error
+	if errors.As(err, &tgErr) && tgErr.RetryAfter > 0 && !retry.IsPermanent(err) {
+		log.WithFields(
+			log.Fields{
+				"chain":       index,
+				"chat_id":     job.chatID,
+				"retry_after": tgErr.RetryAfter,
+			},
+		).Warn("Outbox chat rate limited")
+
+		c.scheduler.Pause(job.chatID, time.Duration(tgErr.RetryAfter)*time.Second, func() {
+			c.processJob(ctx, job, index)
+		})
+		return
+	}
+
+	if err != nil {
+		job.attempts++
+		log.WithFields(
+			log.Fields{
+				"chain":    index,
+				"attempts": job.attempts,
+				"data":     string(job.ev.Data()),
+			},
+		).Error("Process outbox message error", err)
+
+		// transient failure, hold the chat back for the backoff so its order is kept
+		if c.cfg.RetryPolicy.Retry(job.attempts, err) {
+			c.scheduler.Pause(job.chatID, c.cfg.RetryPolicy.Backoff(job.attempts), func() {
+				c.processJob(ctx, job, index)
+			})
+			return
+		}
+
+		// replaying a partially sent event would send its first messages
+		// again, the receipt reports what was sent instead
+		var partialErr *event.PartialSendError
+		deadLettered := false
+		if c.cfg.DeadLetter != "" && !errors.As(err, &partialErr) {
+			deadLetterEvent := job.ev.Clone()
+			deadLetterEvent.SetExtension("deadletterreason", err.Error())
+			deadLetterEvent.SetExtension("deadletterattempts", job.attempts)
+			if errors.As(err, &tgErr) {
+				deadLetterEvent.SetExtension("deadlettercode", tgErr.Code)
+			}
+
+			err := c.broker.Publish(ctx, c.cfg.DeadLetter, &deadLetterEvent)
+			if err != nil {
+				log.Printf("publish to dead letter error: %v", err)
+			} else {
+				deadLettered = true
+			}
+		}
+
+		if !deadLettered {
+			log.WithFields(
+				log.Fields{
+					"chain":    index,
+					"attempts": job.attempts,
+					"id":       job.ev.ID(),
+				},
+			).Warn("Outbox message dropped")
+		}
+	}
+
+	if c.cfg.DeliveryReceipts {
+		receipt := converter.NormalizeDeliveryReceipt(c.bot, job.ev.ID(), job.ev.Type(), sent, err)
+
+		ev := cloudevents.NewEvent()
+		if err != nil {
+			ev.SetType("message_failed")
+		} else {
+			ev.SetType("message_sent")
+		}
+		ev.SetExtension("correlationid", job.ev.ID())
+		if job.chatID != 0 {
+			ev.SetSubject(strconv.FormatInt(job.chatID, 10))
+		}
+		ev.SetData(cloudevents.ApplicationJSON, receipt)
+		err := c.broker.Publish(ctx, c.cfg.Inbox, &ev)
+		if err != nil {
+			log.Printf("publish delivery receipt error: %v", err)
+		}
+	}
+
+	// the retries are over, a redelivery would start them over and publish
+	// another receipt, the event is settled whatever its outcome
+	if job.done != nil {
+		job.done <- nil
+	}
+}
+
+func (c *Connector) publishUpdates(ctx context.Context, index int) {
+	for update := range c.updateChans[index] {
+		b, _ := json.Marshal(update)
+		log.Debugf("inbox, chan: %v: %v\n", index, string(b))
+
+		err := c.publishUpdate(ctx, update)
+		if err != nil {
+			log.WithFields(
+				log.Fields{
+					"chain":  index,
+					"update": string(b),
+				},
+			).Error("Process update error", err)
+		}
+	}
+}
+
+func (c *Connector) publishUpdate(ctx context.Context, update *bot.Update) error {
+	if update.ShippingQuery != nil {
+		shippingQuery := converter.NormalizeTelegramShippingQuery(update.ShippingQuery)
+
+		ev := cloudevents.NewEvent()
+		ev.SetType("shipping_query")
+		if update.ShippingQuery.From != nil {
+			ev.SetSubject(strconv.FormatInt(update.ShippingQuery.From.ID, 10))
+		}
+		ev.SetData(cloudevents.ApplicationJSON, shippingQuery)
+		err := c.broker.Publish(ctx, c.cfg.Inbox, &ev)
+		if err != nil {
+			return fmt.Errorf("publish error: %w", err)
+		}
+	}
+
+	if update.PreCheckoutQuery != nil {
+		c.publishPreCheckoutQuery(ctx, update.PreCheckoutQuery)
+	}
+
+	if update.CallbackQuery != nil {
+		c.answerCallbackQueryHandler.Track(update.CallbackQuery.ID)
+
+		// Message is empty for buttons of messages sent in inline mode
+		var m *models.Message
+		var err error
+		if update.CallbackQuery.Message != nil {
+			m, err = converter.NormalizeTelegramMessage(c.bot.API(), update.CallbackQuery.Message)
+			if err != nil {
+				return fmt.Errorf("normalize Telegram message error: %w", err)
+			}
+			converter.NormalizeMessageExtras(m, update.MessageExtras(update.CallbackQuery.Message))
+		}
+
+		callbackQuery := &models.CallbackQuery{
+			ID:              update.CallbackQuery.ID,
+			From:            converter.NormalizeTelegramUser(update.CallbackQuery.From),
+			Message:         m,
+			InlineMessageID: update.CallbackQuery.InlineMessageID,
+			ChatInstance:    update.CallbackQuery.ChatInstance,
+			Data:            update.CallbackQuery.Data,
+			GameShortName:   update.CallbackQuery.GameShortName,
+		}
+
+		ev := cloudevents.NewEvent()
+		ev.SetType("callback_query")
+		if update.CallbackQuery.Message != nil {
+			ev.SetSubject(strconv.FormatInt(update.CallbackQuery.Message.Chat.ID, 10))
+		} else if update.CallbackQuery.From != nil {
+			ev.SetSubject(strconv.FormatInt(update.CallbackQuery.From.ID, 10))
+		}
+		ev.SetData(cloudevents.ApplicationJSON, callbackQuery)
+		err = c.broker.Publish(ctx, c.cfg.Inbox, &ev)
+		if err != nil {
+			return fmt.Errorf("publish error: %w", err)
+		}
+	}
+
+	if update.InlineQuery != nil {
+		inlineQuery := &models.InlineQuery{
+			ID:       update.InlineQuery.ID,
+			From:     converter.NormalizeTelegramUser(update.InlineQuery.From),
+			Query:    update.InlineQuery.Query,
+			Offset:   update.InlineQuery.Offset,
+			ChatType: update.InlineQuery.ChatType,
+		}
+
+		ev := cloudevents.NewEvent()
+		ev.SetType("inline_query")
+		ev.SetSubject(strconv.FormatInt(update.InlineQuery.From.ID, 10))
+		ev.SetData(cloudevents.ApplicationJSON, inlineQuery)
+		err := c.broker.Publish(ctx, c.cfg.Inbox, &ev)
+		if err != nil {
+			return fmt.Errorf("publish error: %w", err)
+		}
+	}
+
+	if update.ChosenInlineResult != nil {
+		chosenInlineResult := &models.ChosenInlineResult{
+			ResultID:        update.ChosenInlineResult.ResultID,
+			From:            converter.NormalizeTelegramUser(update.ChosenInlineResult.From),
+			InlineMessageID: update.ChosenInlineResult.InlineMessageID,
+			Query:           update.ChosenInlineResult.Query,
+		}
+
+		ev := cloudevents.NewEvent()
+		ev.SetType("chosen_inline_result")
+		ev.SetSubject(strconv.FormatInt(update.ChosenInlineResult.From.ID, 10))
+		ev.SetData(cloudevents.ApplicationJSON, chosenInlineResult)
+		err := c.broker.Publish(ctx, c.cfg.Inbox, &ev)
+		if err != nil {
+			return fmt.Errorf("publish error: %w", err)
+		}
+	}
+
+	if update.Poll != nil {
+		// polls are not tied to a chat, the event has no subject
+		ev := cloudevents.NewEvent()
+		ev.SetType("poll")
+		ev.SetData(cloudevents.ApplicationJSON, converter.NormalizeTelegramPoll(update.Poll))
+		err := c.broker.Publish(ctx, c.cfg.Inbox, &ev)
+		if err != nil {
+			return fmt.Errorf("publish error: %w", err)
+		}
+	}
+
+	if update.PollAnswer != nil {
+		ev := cloudevents.NewEvent()
+		ev.SetType("poll_answer")
+		ev.SetSubject(strconv.FormatInt(update.PollAnswer.User.ID, 10))
+		ev.SetData(cloudevents.ApplicationJSON, converter.NormalizeTelegramPollAnswer(update.PollAnswer))
+		err := c.broker.Publish(ctx, c.cfg.Inbox, &ev)
+		if err != nil {
+			return fmt.Errorf("publish error: %w", err)
+		}
+	}
+
+	chatMemberUpdates := []struct {
+		eventType string
+		update    *tgbotapi.ChatMemberUpdated
+	}{
+		{"my_chat_member", update.MyChatMember},
+		{"chat_member", update.ChatMember},
+	}
+	for _, u := range chatMemberUpdates {
+		if u.update == nil {
+			continue
+		}
+
+		ev := cloudevents.NewEvent()
+		ev.SetType(u.eventType)
+		ev.SetSubject(strconv.FormatInt(u.update.Chat.ID, 10))
+		ev.SetData(cloudevents.ApplicationJSON, converter.NormalizeTelegramChatMemberUpdated(u.update))
+		err := c.broker.Publish(ctx, c.cfg.Inbox, &ev)
+		if err != nil {
+			return fmt.Errorf("publish error: %w", err)
+		}
+	}
+
+	if update.ChatJoinRequest != nil {
+		ev := cloudevents.NewEvent()
+		ev.SetType("chat_join_request")
+		ev.SetSubject(strconv.FormatInt(update.ChatJoinRequest.Chat.ID, 10))
+		ev.SetData(cloudevents.ApplicationJSON, converter.NormalizeTelegramChatJoinRequest(update.ChatJoinRequest))
+		err := c.broker.Publish(ctx, c.cfg.Inbox, &ev)
+		if err != nil {
+			return fmt.Errorf("publish error: %w", err)
+		}
+	}
+
+	messages := []struct {
+		eventType string
+		message   *tgbotapi.Message
+	}{
+		{"message", update.Message},
+		{"edited_message", update.EditedMessage},
+		{"channel_post", update.ChannelPost},
+		{"edited_channel_post", update.EditedChannelPost},
+	}
+	for _, msg := range messages {
+		if msg.message == nil {
+			continue
+		}
+
+		m, err := converter.NormalizeTelegramMessage(c.bot.API(), msg.message)
+		if err != nil {
+			return fmt.Errorf("normalize Telegram message error: %w", err)
+		}
+		converter.NormalizeMessageExtras(m, update.MessageExtras(msg.message))
+
+		ev := cloudevents.NewEvent()
+		ev.SetType(msg.eventType)
+		ev.SetSubject(strconv.FormatInt(msg.message.Chat.ID, 10))
+
+		// transcribed in the worker so that the updates of the chat keep their
+		// order, a failed transcription is published without text
+		if converter.NeedsTranscription(c.bot.API(), m) {
+			transcriptionCtx, cancel := context.WithTimeout(ctx, c.cfg.TranscriptionTimeout)
+			err := converter.TranscribeVoice(transcriptionCtx, m)
+			cancel()
+			if err != nil {
+				log.Printf("transcribe voice %s error: %v", m.Voice.FileID, err)
+				ev.SetExtension("transcriptionerror", err.Error())
+			}
+		}
+
+		ev.SetData(cloudevents.ApplicationJSON, m)
+		err = c.broker.Publish(ctx, c.cfg.Inbox, &ev)
+		if err != nil {
+			return fmt.Errorf("publish error: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// publishPreCheckoutQuery publishes the query and answers it, in backend mode
+// with the answer of the backend.
+func (c *Connector) publishPreCheckoutQuery(ctx context.Context, q *tgbotapi.PreCheckoutQuery) {
+	preCheckoutQuery := converter.NormalizeTelegramPreCheckoutQuery(q)
+	if c.cfg.PreCheckoutBackend {
+		c.answerPreCheckoutQueryHandler.Expect(preCheckoutQuery.ID)
+	}
+
+	ev := cloudevents.NewEvent()
+	ev.SetType("pre_checkout_query")
+	if q.From != nil {
+		ev.SetSubject(strconv.FormatInt(q.From.ID, 10))
+	}
+	ev.SetData(cloudevents.ApplicationJSON, preCheckoutQuery)
+	err := c.broker.Publish(ctx, c.cfg.Inbox, &ev)
+	if err != nil {
+		log.Printf("publish error: %v", err)
+	}
+
+	answer := &models.AnswerPreCheckoutQuery{
+		PreCheckoutQueryID: preCheckoutQuery.ID,
+		OK:                 true,
+	}
+	if !c.cfg.PreCheckoutBackend {
+		err := c.answerPreCheckoutQueryHandler.Answer(answer)
+		if err != nil {
+			log.Printf("send pre_checkout error: %v", err)
+		}
+		return
+	}
+
+	// wait aside so that the other updates of the worker are not held back
+	go func() {
+		timeout := c.cfg.PreCheckoutTimeout
+		if err != nil {
+			timeout = 0
+		}
+
+		backendAnswer, answered := c.answerPreCheckoutQueryHandler.Wait(preCheckoutQuery.ID, timeout)
+		if answered {
+			answer = backendAnswer
+		} else {
+			log.Printf("pre_checkout %s not answered, default ok: %v", preCheckoutQuery.ID, c.cfg.PreCheckoutDefault)
+			answer.OK = c.cfg.PreCheckoutDefault
+			// Telegram only shows the error message of a rejected query
+			if !answer.OK {
+				answer.ErrorMessage = c.cfg.PreCheckoutErrorMessage
+			}
+		}
+
+		err := c.answerPreCheckoutQueryHandler.Answer(answer)
+		if err != nil {
+			log.Printf("send pre_checkout error: %v", err)
+		}
+	}()
+}
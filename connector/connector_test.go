@@ -0,0 +1,908 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/botaas/telegram-bot-connector/bot"
+	"github.com/botaas/telegram-bot-connector/broker"
+	"github.com/botaas/telegram-bot-connector/broker/memory"
+	"github.com/botaas/telegram-bot-connector/converter"
+	"github.com/botaas/telegram-bot-connector/internal/telegramtest"
+	"github.com/botaas/telegram-bot-connector/models"
+	"github.com/botaas/telegram-bot-connector/transcriber"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+// startConnector runs a connector on a memory broker and returns the events
+// it publishes to the inbox and the dead letter channel, configure changes the
+// test configuration.
+func startConnector(t *testing.T, configure ...func(cfg *Config)) (*telegramtest.Server, broker.Broker, *Connector, <-chan *cloudevents.Event, <-chan *cloudevents.Event) {
+	t.Helper()
+
+	ft, b := telegramtest.New(t)
+	br := memory.New()
+
+	cfg := DefaultConfig()
+	cfg.Inbox = "inbox"
+	cfg.Outbox = "outbox"
+	cfg.DeadLetter = "dead_letter"
+	cfg.Concurrency = 2
+	cfg.RetryPolicy.BaseDelay = 10 * time.Millisecond
+	for _, fn := range configure {
+		fn(&cfg)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	inbox := subscribe(t, ctx, br, cfg.Inbox)
+	deadLetter := subscribe(t, ctx, br, cfg.DeadLetter)
+
+	c := New(b, br, cfg)
+	err := c.Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Stop)
+
+	return ft, br, c, inbox, deadLetter
+}
+
+func subscribe(t *testing.T, ctx context.Context, br broker.Broker, channel string) <-chan *cloudevents.Event {
+	t.Helper()
+
+	received := make(chan *cloudevents.Event, 10)
+	_, err := br.Subscribe(ctx, channel, func(ev *cloudevents.Event) error {
+		received <- ev
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return received
+}
+
+func publishOutbox(t *testing.T, br broker.Broker, id string, data any) {
+	t.Helper()
+
+	ev := cloudevents.NewEvent()
+	ev.SetID(id)
+	ev.SetType("message")
+	ev.SetSource("test")
+	err := ev.SetData(cloudevents.ApplicationJSON, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = br.Publish(context.Background(), "outbox", &ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func receive(t *testing.T, events <-chan *cloudevents.Event, timeout time.Duration) *cloudevents.Event {
+	t.Helper()
+
+	select {
+	case ev := <-events:
+		return ev
+	case <-time.After(timeout):
+		t.Fatal("no event published")
+		return nil
+	}
+}
+
+func receipt(t *testing.T, ev *cloudevents.Event) *models.DeliveryReceipt {
+	t.Helper()
+
+	r := &models.DeliveryReceipt{}
+	err := ev.DataAs(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestConnectorSendsOutboxMessages(t *testing.T) {
+	ft, br, _, inbox, _ := startConnector(t)
+
+	publishOutbox(t, br, "m1", &models.Message{Chat: &models.Chat{ID: 42}, Text: "hello"})
+
+	ev := receive(t, inbox, 2*time.Second)
+	if ev.Type() != "message_sent" || ev.Subject() != "42" {
+		t.Fatalf("receipt %s with subject %q, want message_sent with subject 42", ev.Type(), ev.Subject())
+	}
+	if correlationID := ev.Extensions()["correlationid"]; correlationID != "m1" {
+		t.Errorf("correlationid = %v, want m1", correlationID)
+	}
+	if r := receipt(t, ev); len(r.Messages) != 1 || r.Messages[0].Text != "hello" {
+		t.Errorf("receipt reports %d messages, want the message sent", len(r.Messages))
+	}
+
+	calls := ft.Calls("sendMessage")
+	if len(calls) != 1 || calls[0].Params.Get("chat_id") != "42" || calls[0].Params.Get("text") != "hello" {
+		t.Fatalf("calls = %v, want one sendMessage to chat 42", calls)
+	}
+}
+
+func TestConnectorDeadLettersFailedMessages(t *testing.T) {
+	ft, br, _, inbox, deadLetter := startConnector(t)
+
+	ft.Fail(func(call telegramtest.Call) *telegramtest.Failure {
+		return &telegramtest.Failure{Code: 400, Description: "Bad Request: chat not found"}
+	})
+	publishOutbox(t, br, "m1", &models.Message{Chat: &models.Chat{ID: 42}, Text: "hello"})
+
+	ev := receive(t, inbox, 2*time.Second)
+	if ev.Type() != "message_failed" {
+		t.Fatalf("receipt %s, want message_failed", ev.Type())
+	}
+	if r := receipt(t, ev); r.Error == nil || r.Error.Code != 400 {
+		t.Errorf("receipt error = %+v, want code 400", r.Error)
+	}
+
+	ev = receive(t, deadLetter, time.Second)
+	if ev.ID() != "m1" || ev.Extensions()["deadletterreason"] == nil {
+		t.Errorf("dead letter %s with extensions %v, want m1 with its reason", ev.ID(), ev.Extensions())
+	}
+
+	// a bad request is not retried
+	if n := len(ft.Calls("sendMessage")); n != 1 {
+		t.Errorf("sent %d times, want 1", n)
+	}
+}
+
+func TestConnectorRetriesRateLimitedMessages(t *testing.T) {
+	ft, br, _, inbox, _ := startConnector(t)
+
+	ft.Fail(telegramtest.FailOnce("sendMessage", &telegramtest.Failure{Code: 429, Description: "Too Many Requests", RetryAfter: 1}))
+	publishOutbox(t, br, "m1", &models.Message{Chat: &models.Chat{ID: 42}, Text: "hello"})
+
+	ev := receive(t, inbox, 3*time.Second)
+	if ev.Type() != "message_sent" {
+		t.Fatalf("receipt %s, want message_sent", ev.Type())
+	}
+	if n := len(ft.Calls("sendMessage")); n != 2 {
+		t.Errorf("sent %d times, want the rate limited send and its retry", n)
+	}
+}
+
+// ackingBroker is a memory broker acknowledging events, it reports the
+// results of the outbox subscriber.
+type ackingBroker struct {
+	broker.Broker
+	results chan error
+}
+
+func (a *ackingBroker) Acknowledges() bool {
+	return true
+}
+
+func (a *ackingBroker) Subscribe(ctx context.Context, channel string, fn broker.Subscriber) (broker.Unsubscriber, error) {
+	return a.Broker.Subscribe(ctx, channel, func(ev *cloudevents.Event) error {
+		err := fn(ev)
+		if channel == "outbox" {
+			a.results <- err
+		}
+		return err
+	})
+}
+
+func TestConnectorSettlesEventsOnceRetriesAreExhausted(t *testing.T) {
+	ft, b := telegramtest.New(t)
+	br := &ackingBroker{Broker: memory.New(), results: make(chan error, 1)}
+
+	cfg := DefaultConfig()
+	cfg.Inbox = "inbox"
+	cfg.Outbox = "outbox"
+	cfg.Concurrency = 1
+	cfg.RetryPolicy.MaxAttempts = 2
+	cfg.RetryPolicy.BaseDelay = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	inbox := subscribe(t, ctx, br, cfg.Inbox)
+
+	c := New(b, br, cfg)
+	err := c.Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+
+	ft.Fail(func(call telegramtest.Call) *telegramtest.Failure {
+		return &telegramtest.Failure{Code: 500, Description: "Internal Server Error"}
+	})
+	publishOutbox(t, br, "m1", &models.Message{Chat: &models.Chat{ID: 42}, Text: "hello"})
+
+	select {
+	case err := <-br.results:
+		// a redelivery would start the retries and the receipts over
+		if err != nil {
+			t.Errorf("event settled with %v, want it acknowledged", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("event not settled")
+	}
+
+	if ev := receive(t, inbox, time.Second); ev.Type() != "message_failed" {
+		t.Errorf("receipt %s, want message_failed", ev.Type())
+	}
+	if n := len(ft.Calls("sendMessage")); n != 2 {
+		t.Errorf("sent %d times, want the 2 attempts", n)
+	}
+}
+
+func TestConnectorThrottledChatDoesNotDelayOtherChats(t *testing.T) {
+	ft, br, _, inbox, _ := startConnector(t, func(cfg *Config) {
+		cfg.GroupChatRateLimit = 60
+	})
+
+	// both chats are sent by the same worker
+	if shard(-2, 2) != shard(4, 2) {
+		t.Fatal("chats -2 and 4 are not on the same worker")
+	}
+
+	start := time.Now()
+	publishOutbox(t, br, "group-1", &models.Message{Chat: &models.Chat{ID: -2}, Text: "first"})
+	publishOutbox(t, br, "group-2", &models.Message{Chat: &models.Chat{ID: -2}, Text: "second"})
+	publishOutbox(t, br, "private", &models.Message{Chat: &models.Chat{ID: 4}, Text: "hello"})
+
+	var order []string
+	for i := 0; i < 3; i++ {
+		ev := receive(t, inbox, 3*time.Second)
+		order = append(order, ev.Extensions()["correlationid"].(string))
+		if order[i] == "private" {
+			if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+				t.Errorf("message to another chat sent after %v, held back by the throttled chat", elapsed)
+			}
+		}
+	}
+
+	if order[2] != "group-2" {
+		t.Errorf("receipts in order %v, want the second group message last", order)
+	}
+	// the group chat keeps its own order
+	calls := ft.Calls("sendMessage")
+	var texts []string
+	for _, call := range calls {
+		if call.Params.Get("chat_id") == "-2" {
+			texts = append(texts, call.Params.Get("text"))
+		}
+	}
+	if len(texts) != 2 || texts[0] != "first" || texts[1] != "second" {
+		t.Errorf("group chat sent %v, want [first second]", texts)
+	}
+}
+
+func TestConnectorPublishesUpdatesToInbox(t *testing.T) {
+	_, _, c, inbox, _ := startConnector(t)
+
+	var update bot.Update
+	err := json.Unmarshal([]byte(`{
+		"update_id": 1,
+		"message": {
+			"message_id": 7,
+			"date": 1700000000,
+			"chat": {"id": -100, "type": "group", "title": "group"},
+			"from": {"id": 5, "is_bot": false, "first_name": "user"},
+			"text": "hi"
+		}
+	}`), &update)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updates := make(chan bot.Update, 1)
+	updates <- update
+	close(updates)
+	c.Run(updates)
+
+	ev := receive(t, inbox, 2*time.Second)
+	if ev.Type() != "message" || ev.Subject() != "-100" {
+		t.Fatalf("event %s with subject %q, want message with subject -100", ev.Type(), ev.Subject())
+	}
+
+	m := &models.Message{}
+	err = ev.DataAs(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Text != "hi" {
+		t.Errorf("text = %q, want hi", m.Text)
+	}
+}
+
+// fileTransport serves the files of the Bot API, their content is their path.
+type fileTransport struct{}
+
+func (fileTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != "api.telegram.org" || !strings.HasPrefix(req.URL.Path, "/file/") {
+		return http.DefaultTransport.RoundTrip(req)
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(req.URL.Path)),
+		Request:    req,
+	}, nil
+}
+
+// slowTranscriber transcribes after a delay, or fails with err.
+type slowTranscriber struct {
+	delay time.Duration
+	err   error
+}
+
+func (s *slowTranscriber) Transcribe(ctx context.Context, audio io.Reader, name string) (string, error) {
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case <-time.After(s.delay):
+	}
+	if s.err != nil {
+		return "", s.err
+	}
+	return "transcribed " + name, nil
+}
+
+func setTranscriber(t *testing.T, tr transcriber.Transcriber) {
+	t.Helper()
+
+	converter.SetTranscriber(tr)
+	t.Cleanup(func() { converter.SetTranscriber(transcriber.NewNoop()) })
+
+	transport := http.DefaultClient.Transport
+	http.DefaultClient.Transport = fileTransport{}
+	t.Cleanup(func() { http.DefaultClient.Transport = transport })
+}
+
+func runUpdates(t *testing.T, c *Connector, raw ...string) {
+	t.Helper()
+
+	updates := make(chan bot.Update, len(raw))
+	for _, r := range raw {
+		var update bot.Update
+		err := json.Unmarshal([]byte(r), &update)
+		if err != nil {
+			t.Fatal(err)
+		}
+		updates <- update
+	}
+	close(updates)
+	c.Run(updates)
+}
+
+const voiceUpdate = `{
+	"update_id": 1,
+	"message": {
+		"message_id": 1,
+		"date": 1700000000,
+		"chat": {"id": 5, "type": "private"},
+		"from": {"id": 5, "is_bot": false, "first_name": "user"},
+		"voice": {"file_id": "v1", "file_unique_id": "u1", "duration": 1}
+	}
+}`
+
+const textUpdate = `{
+	"update_id": 2,
+	"message": {
+		"message_id": 2,
+		"date": 1700000000,
+		"chat": {"id": 5, "type": "private"},
+		"from": {"id": 5, "is_bot": false, "first_name": "user"},
+		"text": "after the voice"
+	}
+}`
+
+func TestConnectorPublishesTranscribedVoiceInOrder(t *testing.T) {
+	setTranscriber(t, &slowTranscriber{delay: 100 * time.Millisecond})
+	_, _, c, inbox, _ := startConnector(t)
+
+	runUpdates(t, c, voiceUpdate, textUpdate)
+
+	for _, want := range []string{"transcribed v1", "after the voice"} {
+		m := &models.Message{}
+		err := receive(t, inbox, 2*time.Second).DataAs(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Text != want {
+			t.Fatalf("published %q, want %q", m.Text, want)
+		}
+	}
+}
+
+func TestConnectorPublishesUntranscribedVoiceWithTheError(t *testing.T) {
+	setTranscriber(t, &slowTranscriber{err: errors.New("engine down")})
+	_, _, c, inbox, _ := startConnector(t)
+
+	runUpdates(t, c, voiceUpdate)
+
+	ev := receive(t, inbox, 2*time.Second)
+	if reason := ev.Extensions()["transcriptionerror"]; reason != "engine down" {
+		t.Errorf("transcriptionerror = %v, want the error of the transcriber", reason)
+	}
+	m := &models.Message{}
+	err := ev.DataAs(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Voice == nil || m.Text != "" {
+		t.Errorf("published %+v, want the voice without text", m)
+	}
+}
+
+func TestConnectorBoundsTheTranscription(t *testing.T) {
+	setTranscriber(t, &slowTranscriber{delay: time.Minute})
+	_, _, c, inbox, _ := startConnector(t, func(cfg *Config) {
+		cfg.TranscriptionTimeout = 100 * time.Millisecond
+	})
+
+	runUpdates(t, c, voiceUpdate, textUpdate)
+
+	ev := receive(t, inbox, 2*time.Second)
+	if ev.Extensions()["transcriptionerror"] == nil {
+		t.Error("voice published without the transcription timeout")
+	}
+	receive(t, inbox, time.Second)
+}
+
+func TestShardKeepsChatsOnOneWorker(t *testing.T) {
+	for _, chatID := range []int64{0, 1, 7, -7, -1001234567890} {
+		i := shard(chatID, 4)
+		if i < 0 || i >= 4 {
+			t.Errorf("shard(%d) = %d, out of the workers", chatID, i)
+		}
+		if shard(chatID, 4) != i {
+			t.Errorf("shard(%d) is not stable", chatID)
+		}
+	}
+}
+
+const preCheckoutQueryUpdate = `{
+	"update_id": 3,
+	"pre_checkout_query": {
+		"id": "q1",
+		"from": {"id": 5, "is_bot": false, "first_name": "user"},
+		"currency": "USD",
+		"total_amount": 100,
+		"invoice_payload": "order"
+	}
+}`
+
+func TestConnectorAnswersUnansweredPreCheckoutQueriesWithTheDefault(t *testing.T) {
+	for _, ok := range []bool{true, false} {
+		ft, _, c, _, _ := startConnector(t, func(cfg *Config) {
+			cfg.PreCheckoutBackend = true
+			cfg.PreCheckoutTimeout = 10 * time.Millisecond
+			cfg.PreCheckoutDefault = ok
+		})
+		runUpdates(t, c, preCheckoutQueryUpdate)
+
+		deadline := time.Now().Add(time.Second)
+		for len(ft.Calls("answerPreCheckoutQuery")) == 0 {
+			if time.Now().After(deadline) {
+				t.Fatal("pre_checkout_query not answered")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		params := ft.Calls("answerPreCheckoutQuery")[0].Params
+		if params.Get("ok") != strconv.FormatBool(ok) {
+			t.Errorf("answered ok %s, want the default %v", params.Get("ok"), ok)
+		}
+		// the error message is only for the rejected queries
+		if hasMessage := params.Has("error_message"); hasMessage == ok {
+			t.Errorf("answered ok %v with error_message %q", ok, params.Get("error_message"))
+		}
+	}
+}
+
+func TestConnectorReportsEditsInTheirReceipt(t *testing.T) {
+	_, br, _, inbox, _ := startConnector(t)
+
+	ev := cloudevents.NewEvent()
+	ev.SetID("e1")
+	ev.SetType("edit_message_text")
+	ev.SetSource("test")
+	_ = ev.SetData(cloudevents.ApplicationJSON, &models.EditMessageText{
+		EditMessage: models.EditMessage{ChatID: 42, MessageID: 7},
+		Text:        "edited",
+	})
+	err := br.Publish(context.Background(), "outbox", &ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := receive(t, inbox, 2*time.Second)
+	if received.Type() != "message_sent" {
+		t.Fatalf("receipt %s, want message_sent", received.Type())
+	}
+	r := receipt(t, received)
+	if r.CorrelationID != "e1" || r.Type != "edit_message_text" {
+		t.Errorf("receipt of %s %s, want edit_message_text e1", r.Type, r.CorrelationID)
+	}
+	if len(r.Messages) != 1 || r.Messages[0].ID != 7 {
+		t.Errorf("receipt reports %v, want the edited message", r.Messages)
+	}
+}
+
+func TestConnectorWithoutDeliveryReceipts(t *testing.T) {
+	ft, br, _, inbox, _ := startConnector(t, func(cfg *Config) {
+		cfg.DeliveryReceipts = false
+	})
+
+	publishOutbox(t, br, "m1", &models.Message{Chat: &models.Chat{ID: 42}, Text: "hello"})
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(ft.Calls("sendMessage")) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("message not sent")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	select {
+	case ev := <-inbox:
+		t.Errorf("published %s, want no receipt", ev.Type())
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestConnectorPublishesEditedMessagesAndChannelPosts(t *testing.T) {
+	_, _, c, inbox, _ := startConnector(t)
+
+	runUpdates(t, c, `{
+		"update_id": 1,
+		"edited_message": {
+			"message_id": 7,
+			"date": 1700000000,
+			"edit_date": 1700000060,
+			"chat": {"id": 5, "type": "private"},
+			"from": {"id": 5, "is_bot": false, "first_name": "user"},
+			"text": "fixed"
+		}
+	}`, `{
+		"update_id": 2,
+		"channel_post": {
+			"message_id": 8,
+			"date": 1700000000,
+			"chat": {"id": -1005, "type": "channel", "title": "news"},
+			"text": "post"
+		}
+	}`, `{
+		"update_id": 3,
+		"edited_channel_post": {
+			"message_id": 8,
+			"date": 1700000000,
+			"edit_date": 1700000120,
+			"chat": {"id": -1005, "type": "channel", "title": "news"},
+			"text": "fixed post"
+		}
+	}`)
+
+	// the updates are spread over the workers, their events may interleave
+	events := map[string]*models.Message{}
+	for i := 0; i < 3; i++ {
+		ev := receive(t, inbox, 2*time.Second)
+		m := &models.Message{}
+		err := ev.DataAs(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		events[ev.Type()] = m
+	}
+
+	if m := events["edited_message"]; m == nil || m.Text != "fixed" || m.EditDate != 1700000060 || m.IsChannelPost {
+		t.Errorf("edited_message = %+v, want the edit of a private message", m)
+	}
+	if m := events["channel_post"]; m == nil || m.Text != "post" || m.EditDate != 0 || !m.IsChannelPost {
+		t.Errorf("channel_post = %+v, want the unedited channel post", m)
+	}
+	if m := events["edited_channel_post"]; m == nil || m.Text != "fixed post" || m.EditDate != 1700000120 || !m.IsChannelPost {
+		t.Errorf("edited_channel_post = %+v, want the edit of the channel post", m)
+	}
+}
+
+func TestConnectorPublishesInlineQueriesAndChosenResults(t *testing.T) {
+	_, _, c, inbox, _ := startConnector(t)
+
+	runUpdates(t, c, `{
+		"update_id": 1,
+		"inline_query": {
+			"id": "q1",
+			"from": {"id": 5, "is_bot": false, "first_name": "user"},
+			"query": "cats",
+			"offset": "3",
+			"chat_type": "sender"
+		}
+	}`)
+	ev := receive(t, inbox, 2*time.Second)
+	if ev.Type() != "inline_query" || ev.Subject() != "5" {
+		t.Fatalf("event %s with subject %q, want inline_query with subject 5", ev.Type(), ev.Subject())
+	}
+	q := &models.InlineQuery{}
+	err := ev.DataAs(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.ID != "q1" || q.Query != "cats" || q.Offset != "3" || q.ChatType != "sender" || q.From.ID != 5 {
+		t.Errorf("inline_query = %+v, want the query of user 5", q)
+	}
+
+	runUpdates(t, c, `{
+		"update_id": 2,
+		"chosen_inline_result": {
+			"result_id": "a",
+			"from": {"id": 5, "is_bot": false, "first_name": "user"},
+			"inline_message_id": "inline",
+			"query": "cats"
+		}
+	}`)
+	ev = receive(t, inbox, 2*time.Second)
+	if ev.Type() != "chosen_inline_result" || ev.Subject() != "5" {
+		t.Fatalf("event %s with subject %q, want chosen_inline_result with subject 5", ev.Type(), ev.Subject())
+	}
+	r := &models.ChosenInlineResult{}
+	err = ev.DataAs(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.ResultID != "a" || r.InlineMessageID != "inline" || r.Query != "cats" {
+		t.Errorf("chosen_inline_result = %+v, want result a of the inline message", r)
+	}
+}
+
+func TestConnectorAutoAcksPublishedCallbackQueries(t *testing.T) {
+	ft, _, c, inbox, _ := startConnector(t, func(cfg *Config) {
+		cfg.CallbackQueryAutoAck = 50 * time.Millisecond
+	})
+
+	runUpdates(t, c, `{
+		"update_id": 1,
+		"callback_query": {
+			"id": "c1",
+			"from": {"id": 5, "is_bot": false, "first_name": "user"},
+			"message": {"message_id": 7, "date": 1700000000, "chat": {"id": -100, "type": "group", "title": "group"}, "text": "pick"},
+			"chat_instance": "i",
+			"data": "yes"
+		}
+	}`, `{
+		"update_id": 2,
+		"callback_query": {
+			"id": "c2",
+			"from": {"id": 5, "is_bot": false, "first_name": "user"},
+			"inline_message_id": "inline",
+			"chat_instance": "i",
+			"data": "no"
+		}
+	}`)
+
+	// the callback query of a message is about its chat, an inline one about
+	// the user
+	subjects := map[string]string{}
+	for i := 0; i < 2; i++ {
+		ev := receive(t, inbox, 2*time.Second)
+		q := &models.CallbackQuery{}
+		err := ev.DataAs(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		subjects[q.Data] = ev.Subject()
+		if q.Data == "yes" && (q.Message == nil || q.Message.ID != 7) {
+			t.Errorf("callback_query message = %+v, want the message of the button", q.Message)
+		}
+	}
+	if subjects["yes"] != "-100" || subjects["no"] != "5" {
+		t.Errorf("subjects = %v, want the chat -100 and the user 5", subjects)
+	}
+
+	if calls := ft.WaitCalls(t, 2, "answerCallbackQuery"); len(calls) != 2 {
+		t.Errorf("answerCallbackQuery called %d times, want both queries auto-acked", len(calls))
+	}
+}
+
+func TestConnectorAnswersPreCheckoutQueriesWithTheBackendAnswer(t *testing.T) {
+	ft, br, c, inbox, _ := startConnector(t, func(cfg *Config) {
+		cfg.PreCheckoutBackend = true
+		cfg.PreCheckoutTimeout = 2 * time.Second
+	})
+
+	runUpdates(t, c, preCheckoutQueryUpdate)
+
+	ev := receive(t, inbox, 2*time.Second)
+	if ev.Type() != "pre_checkout_query" || ev.Subject() != "5" {
+		t.Fatalf("event %s with subject %q, want pre_checkout_query with subject 5", ev.Type(), ev.Subject())
+	}
+	q := &models.PreCheckoutQuery{}
+	err := ev.DataAs(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.ID != "q1" || q.Currency != "USD" || q.TotalAmount != 100 || q.InvoicePayload != "order" {
+		t.Errorf("pre_checkout_query = %+v, want the normalized query", q)
+	}
+
+	answer := cloudevents.NewEvent()
+	answer.SetID("a1")
+	answer.SetType("answer_pre_checkout_query")
+	answer.SetSource("test")
+	_ = answer.SetData(cloudevents.ApplicationJSON, &models.AnswerPreCheckoutQuery{PreCheckoutQueryID: "q1", ErrorMessage: "Out of stock"})
+	err = br.Publish(context.Background(), "outbox", &answer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params := ft.WaitCalls(t, 1, "answerPreCheckoutQuery")[0].Params
+	if params.Get("ok") != "false" || params.Get("error_message") != "Out of stock" {
+		t.Errorf("answerPreCheckoutQuery params = %v, want the rejection of the backend", params)
+	}
+}
+
+func TestConnectorPublishesShippingQueries(t *testing.T) {
+	_, _, c, inbox, _ := startConnector(t)
+
+	runUpdates(t, c, `{
+		"update_id": 4,
+		"shipping_query": {
+			"id": "s1",
+			"from": {"id": 5, "is_bot": false, "first_name": "user"},
+			"invoice_payload": "order",
+			"shipping_address": {
+				"country_code": "FR",
+				"state": "",
+				"city": "Paris",
+				"street_line1": "1 rue de Rivoli",
+				"street_line2": "",
+				"post_code": "75001"
+			}
+		}
+	}`)
+
+	ev := receive(t, inbox, 2*time.Second)
+	if ev.Type() != "shipping_query" || ev.Subject() != "5" {
+		t.Fatalf("event %s with subject %q, want shipping_query with subject 5", ev.Type(), ev.Subject())
+	}
+	q := &models.ShippingQuery{}
+	err := ev.DataAs(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.ID != "s1" || q.InvoicePayload != "order" || q.From == nil || q.From.ID != 5 {
+		t.Errorf("shipping_query = %+v, want the normalized query", q)
+	}
+	if a := q.ShippingAddress; a == nil || a.CountryCode != "FR" || a.City != "Paris" || a.StreetLine1 != "1 rue de Rivoli" || a.PostCode != "75001" {
+		t.Errorf("shipping_address = %+v, want the address of the user", a)
+	}
+}
+
+func TestConnectorPublishesChatMemberUpdates(t *testing.T) {
+	_, _, c, inbox, _ := startConnector(t)
+
+	for _, typ := range []string{"my_chat_member", "chat_member"} {
+		runUpdates(t, c, `{
+			"update_id": 5,
+			"`+typ+`": {
+				"chat": {"id": -100, "type": "supergroup", "title": "group"},
+				"from": {"id": 5, "is_bot": false, "first_name": "admin"},
+				"date": 1700000000,
+				"old_chat_member": {"user": {"id": 6, "is_bot": false, "first_name": "user"}, "status": "member"},
+				"new_chat_member": {"user": {"id": 6, "is_bot": false, "first_name": "user"}, "status": "kicked", "until_date": 1700000600}
+			}
+		}`)
+
+		ev := receive(t, inbox, 2*time.Second)
+		if ev.Type() != typ || ev.Subject() != "-100" {
+			t.Fatalf("event %s with subject %q, want %s with subject -100", ev.Type(), ev.Subject(), typ)
+		}
+		u := &models.ChatMemberUpdated{}
+		err := ev.DataAs(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.Chat == nil || u.Chat.ID != -100 || u.From == nil || u.From.ID != 5 || u.Date != 1700000000 {
+			t.Errorf("%s = %+v, want the chat, the performer and the date", typ, u)
+		}
+		if u.OldChatMember == nil || u.OldChatMember.Status != "member" ||
+			u.NewChatMember == nil || u.NewChatMember.Status != "kicked" || u.NewChatMember.UntilDate != 1700000600 ||
+			u.NewChatMember.User == nil || u.NewChatMember.User.ID != 6 {
+			t.Errorf("%s members = %+v -> %+v, want the ban of user 6", typ, u.OldChatMember, u.NewChatMember)
+		}
+	}
+}
+
+func TestConnectorPublishesChatJoinRequests(t *testing.T) {
+	_, _, c, inbox, _ := startConnector(t)
+
+	runUpdates(t, c, `{
+		"update_id": 6,
+		"chat_join_request": {
+			"chat": {"id": -100, "type": "supergroup", "title": "group"},
+			"from": {"id": 6, "is_bot": false, "first_name": "user"},
+			"date": 1700000000,
+			"bio": "hello",
+			"invite_link": {
+				"invite_link": "https://t.me/+abc",
+				"creator": {"id": 5, "is_bot": false, "first_name": "admin"},
+				"creates_join_request": true,
+				"is_primary": false,
+				"is_revoked": false
+			}
+		}
+	}`)
+
+	ev := receive(t, inbox, 2*time.Second)
+	if ev.Type() != "chat_join_request" || ev.Subject() != "-100" {
+		t.Fatalf("event %s with subject %q, want chat_join_request with subject -100", ev.Type(), ev.Subject())
+	}
+	r := &models.ChatJoinRequest{}
+	err := ev.DataAs(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Chat == nil || r.Chat.ID != -100 || r.From == nil || r.From.ID != 6 || r.Bio != "hello" {
+		t.Errorf("chat_join_request = %+v, want the chat, the user and their bio", r)
+	}
+	if l := r.InviteLink; l == nil || l.InviteLink != "https://t.me/+abc" || !l.CreatesJoinRequest || l.Creator == nil || l.Creator.ID != 5 {
+		t.Errorf("invite_link = %+v, want the link the user followed", l)
+	}
+}
+
+func TestConnectorPublishesPollsAndPollAnswers(t *testing.T) {
+	_, _, c, inbox, _ := startConnector(t)
+
+	runUpdates(t, c, `{
+		"update_id": 7,
+		"poll": {
+			"id": "p1",
+			"question": "Where?",
+			"options": [{"text": "Paris", "voter_count": 1}, {"text": "Lyon", "voter_count": 0}],
+			"total_voter_count": 1,
+			"is_closed": true,
+			"is_anonymous": false,
+			"type": "regular",
+			"allows_multiple_answers": false
+		}
+	}`, `{
+		"update_id": 8,
+		"poll_answer": {
+			"poll_id": "p1",
+			"user": {"id": 6, "is_bot": false, "first_name": "user"},
+			"option_ids": [0]
+		}
+	}`)
+
+	ev := receive(t, inbox, 2*time.Second)
+	if ev.Type() != "poll" || ev.Subject() != "" {
+		t.Fatalf("event %s with subject %q, want poll without subject", ev.Type(), ev.Subject())
+	}
+	p := &models.Poll{}
+	err := ev.DataAs(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ID != "p1" || !p.IsClosed || p.TotalVoterCount != 1 || len(p.Options) != 2 || p.Options[0].VoterCount != 1 {
+		t.Errorf("poll = %+v, want the closed poll and its votes", p)
+	}
+
+	ev = receive(t, inbox, 2*time.Second)
+	if ev.Type() != "poll_answer" || ev.Subject() != "6" {
+		t.Fatalf("event %s with subject %q, want poll_answer with subject 6", ev.Type(), ev.Subject())
+	}
+	a := &models.PollAnswer{}
+	err = ev.DataAs(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.PollID != "p1" || a.User == nil || a.User.ID != 6 || len(a.OptionIDs) != 1 || a.OptionIDs[0] != 0 {
+		t.Errorf("poll_answer = %+v, want the vote of user 6 for the first option", a)
+	}
+}
@@ -1,125 +1,78 @@
 package converter
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"errors"
-	"io"
-	"mime/multipart"
+	"fmt"
 	"net/http"
-	"os"
-	"os/exec"
 
 	log "github.com/sirupsen/logrus"
 
+	"github.com/botaas/telegram-bot-connector/bot"
 	"github.com/botaas/telegram-bot-connector/models"
+	"github.com/botaas/telegram-bot-connector/transcriber"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
-type TranscriptionResponse struct {
-	Text string `json:"text"`
-}
+var voiceTranscriber = transcriber.NewNoop()
 
-type ErrorResponse struct {
-	Error struct {
-		Message string `json:"message"`
-		Type    string `json:"type"`
-		Param   string `json:"param"`
-		Code    string `json:"code"`
-	} `json:"error"`
+// SetTranscriber sets the Transcriber filling the text of voice messages.
+func SetTranscriber(t transcriber.Transcriber) {
+	voiceTranscriber = t
 }
 
-func voiceToText(bot *tgbotapi.BotAPI, fileID string) (string, error) {
-	url, err := bot.GetFileDirectURL(fileID)
-
-	resp, err := http.Get(url)
+func voiceToText(ctx context.Context, url string, fileID string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
 
-	cmd := exec.Command("ffmpeg", "-i", "pipe:0", "-f", "mp3", "pipe:1")
-	cmd.Stdin = resp.Body
-
-	reader, writer := io.Pipe()
-	cmd.Stdout = writer
-
-	go func() {
-		defer writer.Close()
-		if err := cmd.Run(); err != nil {
-			log.Print(err)
-		}
-	}()
-
-	var reqeustBody bytes.Buffer
-	multipartWriter := multipart.NewWriter(&reqeustBody)
-	fieldWriter, err := multipartWriter.CreateFormField("model")
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return "", err
 	}
+	defer resp.Body.Close()
 
-	fieldWriter.Write([]byte("whisper-1"))
-	fileWrite, err := multipartWriter.CreateFormFile("file", fileID+".mp3")
-	if err != nil {
-		log.Println(err)
-		return "", err
+	// an error page is no voice note
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("download voice %s: %s", fileID, resp.Status)
 	}
-	if _, err = io.Copy(fileWrite, reader); err != nil {
-		log.Println(err)
-		return "", err
-	}
-	if err := multipartWriter.Close(); err != nil {
-		log.Fatal(err)
-	}
-	req, _ := http.NewRequest("POST", "https://api.openai.com/v1/audio/transcriptions", &reqeustBody)
-	req.Header.Set("Authorization", "Bearer "+os.Getenv("OPENAI_API_KEY"))
-	req.Header.Set("Content-Type", multipartWriter.FormDataContentType())
-	client := &http.Client{}
-	resp, err = client.Do(req)
-	if resp.StatusCode != 200 {
-		var errorResponse ErrorResponse
-		json.NewDecoder(resp.Body).Decode(&errorResponse)
-		log.Println(errorResponse.Error.Message)
-		return "", errors.New(errorResponse.Error.Message)
-	}
-	if err != nil {
-		log.Println(err)
-		return "", err
-	}
-	defer resp.Body.Close()
-	response, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Println(err)
-		return "", err
+
+	return voiceTranscriber.Transcribe(ctx, resp.Body, fileID)
+}
+
+// NeedsTranscription reports whether the text of m is to be filled by
+// TranscribeVoice, the bot's own voice messages are not transcribed.
+func NeedsTranscription(bot *tgbotapi.BotAPI, m *models.Message) bool {
+	if m.Voice == nil || len(m.Voice.Url) == 0 || transcriber.IsNoop(voiceTranscriber) {
+		return false
 	}
-	var transcriptionResponse TranscriptionResponse
-	err = json.Unmarshal(response, &transcriptionResponse)
+	return m.From == nil || m.From.ID != bot.Self.ID
+}
+
+// TranscribeVoice fills the text of the voice message m, ctx bounds the
+// download and the transcription.
+func TranscribeVoice(ctx context.Context, m *models.Message) error {
+	text, err := voiceToText(ctx, m.Voice.Url, m.Voice.FileID)
 	if err != nil {
-		log.Println(err)
-		return "", nil
+		return err
 	}
-	log.Println(transcriptionResponse.Text)
-	return transcriptionResponse.Text, nil
+
+	m.Text = text
+	return nil
 }
 
 func NormalizeTelegramMessage(bot *tgbotapi.BotAPI, m *tgbotapi.Message) (*models.Message, error) {
+	return normalizeTelegramMessage(bot, m, bot.GetFileDirectURL)
+}
+
+// normalizeTelegramMessage normalizes m, fileURL resolves the download url of
+// its files.
+func normalizeTelegramMessage(bot *tgbotapi.BotAPI, m *tgbotapi.Message, fileURL func(fileID string) (string, error)) (*models.Message, error) {
 	o := &models.Message{
-		ID: m.MessageID,
-		Chat: &models.Chat{
-			ID: m.Chat.ID,
-		},
+		ID:   m.MessageID,
+		Chat: NormalizeTelegramChat(m.Chat),
 		Text: m.Text,
-		From: &models.User{
-			ID:                      m.From.ID,
-			UserName:                m.From.UserName,
-			LanguageCode:            m.From.LanguageCode,
-			IsBot:                   m.From.IsBot,
-			FirstName:               m.From.FirstName,
-			LastName:                m.From.LastName,
-			CanJoinGroups:           m.From.CanJoinGroups,
-			CanReadAllGroupMessages: m.From.CanReadAllGroupMessages,
-			SupportsInlineQueries:   m.From.SupportsInlineQueries,
-		},
 		To: &models.User{
 			ID:                      bot.Self.ID,
 			UserName:                bot.Self.UserName,
@@ -133,10 +86,19 @@ func NormalizeTelegramMessage(bot *tgbotapi.BotAPI, m *tgbotapi.Message) (*model
 		},
 	}
 
+	o.Entities = NormalizeTelegramMessageEntities(m.Entities)
+	o.EditDate = m.EditDate
+	o.IsChannelPost = m.Chat != nil && m.Chat.IsChannel()
+
+	// From is empty for messages sent to channels
+	if m.From != nil {
+		o.From = NormalizeTelegramUser(m.From)
+	}
+
 	if len(m.Photo) > 0 {
 		var photos []*models.Photo
 		for _, photo := range m.Photo {
-			file, err := bot.GetFileDirectURL(photo.FileID)
+			file, err := fileURL(photo.FileID)
 			if err != nil {
 				continue
 			}
@@ -156,7 +118,7 @@ func NormalizeTelegramMessage(bot *tgbotapi.BotAPI, m *tgbotapi.Message) (*model
 	}
 
 	if m.Audio != nil {
-		file, err := bot.GetFileDirectURL(m.Audio.FileID)
+		file, err := fileURL(m.Audio.FileID)
 		if err != nil {
 			return nil, err
 		}
@@ -172,16 +134,11 @@ func NormalizeTelegramMessage(bot *tgbotapi.BotAPI, m *tgbotapi.Message) (*model
 	}
 
 	if m.Voice != nil {
-		url, err := bot.GetFileDirectURL(m.Voice.FileID)
+		url, err := fileURL(m.Voice.FileID)
 		if err != nil {
 			return nil, err
 		}
 
-		text, err := voiceToText(bot, m.Voice.FileID)
-		if err == nil {
-			o.Text = text
-		}
-
 		voice := &models.Voice{
 			Url:      url,
 			FileID:   m.Voice.FileID,
@@ -193,7 +150,7 @@ func NormalizeTelegramMessage(bot *tgbotapi.BotAPI, m *tgbotapi.Message) (*model
 	}
 
 	if m.Video != nil {
-		url, err := bot.GetFileDirectURL(m.Video.FileID)
+		url, err := fileURL(m.Video.FileID)
 		if err != nil {
 			return nil, err
 		}
@@ -208,35 +165,126 @@ func NormalizeTelegramMessage(bot *tgbotapi.BotAPI, m *tgbotapi.Message) (*model
 		o.Video = video
 	}
 
-	if m.SuccessfulPayment != nil {
-		var orderInfo *models.OrderInfo
-		if m.SuccessfulPayment.OrderInfo != nil {
-			var shippingAddress *models.ShippingAddress
-			if m.SuccessfulPayment.OrderInfo.ShippingAddress != nil {
-				shippingAddress = &models.ShippingAddress{
-					CountryCode: m.SuccessfulPayment.OrderInfo.ShippingAddress.CountryCode,
-					State:       m.SuccessfulPayment.OrderInfo.ShippingAddress.State,
-					City:        m.SuccessfulPayment.OrderInfo.ShippingAddress.City,
-					StreetLine1: m.SuccessfulPayment.OrderInfo.ShippingAddress.StreetLine1,
-					StreetLine2: m.SuccessfulPayment.OrderInfo.ShippingAddress.StreetLine2,
-					PostCode:    m.SuccessfulPayment.OrderInfo.ShippingAddress.PostCode,
-				}
-			}
+	if m.Animation != nil {
+		url, err := fileURL(m.Animation.FileID)
+		if err != nil {
+			return nil, err
+		}
 
-			orderInfo = &models.OrderInfo{
-				Name:            m.SuccessfulPayment.OrderInfo.Name,
-				PhoneNumber:     m.SuccessfulPayment.OrderInfo.PhoneNumber,
-				Email:           m.SuccessfulPayment.OrderInfo.Email,
-				ShippingAddress: shippingAddress,
-			}
+		animation := &models.Animation{
+			Url:      url,
+			FileID:   m.Animation.FileID,
+			Width:    m.Animation.Width,
+			Height:   m.Animation.Height,
+			Duration: m.Animation.Duration,
+			FileName: m.Animation.FileName,
+			MimeType: m.Animation.MimeType,
+			FileSize: m.Animation.FileSize,
+		}
+		o.Animation = animation
+	}
+
+	if m.Document != nil {
+		// files over 20 MB can't be downloaded by bots, they are still
+		// forwarded with their file id
+		url, err := fileURL(m.Document.FileID)
+		if err != nil {
+			log.Printf("get document %s url error: %v", m.Document.FileID, err)
 		}
 
+		document := &models.Document{
+			Url:      url,
+			FileID:   m.Document.FileID,
+			FileName: m.Document.FileName,
+			MimeType: m.Document.MimeType,
+			FileSize: m.Document.FileSize,
+		}
+		o.Document = document
+	}
+
+	if m.Sticker != nil {
+		url, err := fileURL(m.Sticker.FileID)
+		if err != nil {
+			return nil, err
+		}
+
+		sticker := &models.Sticker{
+			Url:        url,
+			FileID:     m.Sticker.FileID,
+			Width:      m.Sticker.Width,
+			Height:     m.Sticker.Height,
+			IsAnimated: m.Sticker.IsAnimated,
+			IsVideo:    m.Sticker.IsVideo,
+			Emoji:      m.Sticker.Emoji,
+			SetName:    m.Sticker.SetName,
+			FileSize:   m.Sticker.FileSize,
+		}
+		o.Sticker = sticker
+	}
+
+	if m.VideoNote != nil {
+		url, err := fileURL(m.VideoNote.FileID)
+		if err != nil {
+			return nil, err
+		}
+
+		videoNote := &models.VideoNote{
+			Url:      url,
+			FileID:   m.VideoNote.FileID,
+			Length:   m.VideoNote.Length,
+			Duration: m.VideoNote.Duration,
+			FileSize: m.VideoNote.FileSize,
+		}
+		o.VideoNote = videoNote
+	}
+
+	if m.Location != nil {
+		o.Location = NormalizeTelegramLocation(m.Location)
+	}
+
+	if m.Venue != nil {
+		o.Venue = &models.Venue{
+			Location:        *NormalizeTelegramLocation(&m.Venue.Location),
+			Title:           m.Venue.Title,
+			Address:         m.Venue.Address,
+			FoursquareID:    m.Venue.FoursquareID,
+			FoursquareType:  m.Venue.FoursquareType,
+			GooglePlaceID:   m.Venue.GooglePlaceID,
+			GooglePlaceType: m.Venue.GooglePlaceType,
+		}
+	}
+
+	if m.Contact != nil {
+		o.Contact = &models.Contact{
+			PhoneNumber: m.Contact.PhoneNumber,
+			FirstName:   m.Contact.FirstName,
+			LastName:    m.Contact.LastName,
+			UserID:      m.Contact.UserID,
+			VCard:       m.Contact.VCard,
+		}
+	}
+
+	if m.Dice != nil {
+		o.Dice = &models.Dice{
+			Emoji: m.Dice.Emoji,
+			Value: m.Dice.Value,
+		}
+	}
+
+	if m.Poll != nil {
+		o.Poll = NormalizeTelegramPoll(m.Poll)
+	}
+
+	o.Caption = m.Caption
+	o.CaptionEntities = NormalizeTelegramMessageEntities(m.CaptionEntities)
+
+	if m.SuccessfulPayment != nil {
 		successfulPayment := &models.SuccessfulPayment{
 			Currency:                m.SuccessfulPayment.Currency,
 			TotalAmount:             m.SuccessfulPayment.TotalAmount,
 			InvoicePayload:          m.SuccessfulPayment.InvoicePayload,
 			ShippingOptionID:        m.SuccessfulPayment.ShippingOptionID,
-			OrderInfo:               orderInfo,
+			OrderInfo:               NormalizeTelegramOrderInfo(m.SuccessfulPayment.OrderInfo),
 			TelegramPaymentChargeID: m.SuccessfulPayment.TelegramPaymentChargeID,
 			ProviderPaymentChargeID: m.SuccessfulPayment.ProviderPaymentChargeID,
 		}
@@ -246,6 +294,228 @@ func NormalizeTelegramMessage(bot *tgbotapi.BotAPI, m *tgbotapi.Message) (*model
 	return o, nil
 }
 
+func NormalizeTelegramLocation(l *tgbotapi.Location) *models.Location {
+	return &models.Location{
+		Latitude:             l.Latitude,
+		Longitude:            l.Longitude,
+		HorizontalAccuracy:   l.HorizontalAccuracy,
+		LivePeriod:           l.LivePeriod,
+		Heading:              l.Heading,
+		ProximityAlertRadius: l.ProximityAlertRadius,
+	}
+}
+
+func NormalizeTelegramPoll(p *tgbotapi.Poll) *models.Poll {
+	isAnonymous := p.IsAnonymous
+	o := &models.Poll{
+		ID:                    p.ID,
+		Question:              p.Question,
+		TotalVoterCount:       p.TotalVoterCount,
+		IsClosed:              p.IsClosed,
+		IsAnonymous:           &isAnonymous,
+		Type:                  p.Type,
+		AllowsMultipleAnswers: p.AllowsMultipleAnswers,
+		Explanation:           p.Explanation,
+		ExplanationEntities:   NormalizeTelegramMessageEntities(p.ExplanationEntities),
+		OpenPeriod:            p.OpenPeriod,
+		CloseDate:             p.CloseDate,
+	}
+
+	// only quizzes have a correct option
+	if p.Type == "quiz" {
+		correctOptionID := p.CorrectOptionID
+		o.CorrectOptionID = &correctOptionID
+	}
+
+	for _, option := range p.Options {
+		o.Options = append(o.Options, models.PollOption{
+			Text:       option.Text,
+			VoterCount: option.VoterCount,
+		})
+	}
+
+	return o
+}
+
+func NormalizeTelegramPollAnswer(a *tgbotapi.PollAnswer) *models.PollAnswer {
+	return &models.PollAnswer{
+		PollID:    a.PollID,
+		User:      NormalizeTelegramUser(&a.User),
+		OptionIDs: a.OptionIDs,
+	}
+}
+
+func NormalizeTelegramMessageEntities(entities []tgbotapi.MessageEntity) []models.MessageEntity {
+	if len(entities) == 0 {
+		return nil
+	}
+
+	o := make([]models.MessageEntity, 0, len(entities))
+	for _, e := range entities {
+		entity := models.MessageEntity{
+			Type:     e.Type,
+			Offset:   e.Offset,
+			Length:   e.Length,
+			URL:      e.URL,
+			Language: e.Language,
+		}
+		if e.User != nil {
+			entity.User = NormalizeTelegramUser(e.User)
+		}
+		o = append(o, entity)
+	}
+	return o
+}
+
+func NormalizeTelegramPreCheckoutQuery(q *tgbotapi.PreCheckoutQuery) *models.PreCheckoutQuery {
+	o := &models.PreCheckoutQuery{
+		ID:               q.ID,
+		Currency:         q.Currency,
+		TotalAmount:      q.TotalAmount,
+		InvoicePayload:   q.InvoicePayload,
+		ShippingOptionID: q.ShippingOptionID,
+		OrderInfo:        NormalizeTelegramOrderInfo(q.OrderInfo),
+	}
+
+	if q.From != nil {
+		o.From = NormalizeTelegramUser(q.From)
+	}
+
+	return o
+}
+
+func NormalizeTelegramShippingQuery(q *tgbotapi.ShippingQuery) *models.ShippingQuery {
+	o := &models.ShippingQuery{
+		ID:              q.ID,
+		InvoicePayload:  q.InvoicePayload,
+		ShippingAddress: NormalizeTelegramShippingAddress(q.ShippingAddress),
+	}
+
+	if q.From != nil {
+		o.From = NormalizeTelegramUser(q.From)
+	}
+
+	return o
+}
+
+func NormalizeTelegramOrderInfo(orderInfo *tgbotapi.OrderInfo) *models.OrderInfo {
+	if orderInfo == nil {
+		return nil
+	}
+
+	return &models.OrderInfo{
+		Name:            orderInfo.Name,
+		PhoneNumber:     orderInfo.PhoneNumber,
+		Email:           orderInfo.Email,
+		ShippingAddress: NormalizeTelegramShippingAddress(orderInfo.ShippingAddress),
+	}
+}
+
+func NormalizeTelegramShippingAddress(address *tgbotapi.ShippingAddress) *models.ShippingAddress {
+	if address == nil {
+		return nil
+	}
+
+	return &models.ShippingAddress{
+		CountryCode: address.CountryCode,
+		State:       address.State,
+		City:        address.City,
+		StreetLine1: address.StreetLine1,
+		StreetLine2: address.StreetLine2,
+		PostCode:    address.PostCode,
+	}
+}
+
+func NormalizeTelegramChat(chat *tgbotapi.Chat) *models.Chat {
+	return &models.Chat{
+		ID:        chat.ID,
+		Type:      chat.Type,
+		Title:     chat.Title,
+		UserName:  chat.UserName,
+		FirstName: chat.FirstName,
+		LastName:  chat.LastName,
+	}
+}
+
+// NormalizeMessageExtras sets the fields of m tgbotapi does not decode.
+func NormalizeMessageExtras(m *models.Message, extras *bot.MessageExtras) {
+	m.MessageThreadID = extras.MessageThreadID
+	if m.Chat != nil {
+		m.Chat.IsForum = extras.Chat.IsForum
+	}
+}
+
+func NormalizeTelegramChatMemberUpdated(u *tgbotapi.ChatMemberUpdated) *models.ChatMemberUpdated {
+	return &models.ChatMemberUpdated{
+		Chat:          NormalizeTelegramChat(&u.Chat),
+		From:          NormalizeTelegramUser(&u.From),
+		Date:          u.Date,
+		OldChatMember: NormalizeTelegramChatMember(&u.OldChatMember),
+		NewChatMember: NormalizeTelegramChatMember(&u.NewChatMember),
+		InviteLink:    NormalizeTelegramChatInviteLink(u.InviteLink),
+	}
+}
+
+func NormalizeTelegramChatMember(m *tgbotapi.ChatMember) *models.ChatMember {
+	o := &models.ChatMember{
+		Status:                m.Status,
+		CustomTitle:           m.CustomTitle,
+		IsAnonymous:           m.IsAnonymous,
+		UntilDate:             m.UntilDate,
+		IsMember:              m.IsMember,
+		CanBeEdited:           m.CanBeEdited,
+		CanManageChat:         m.CanManageChat,
+		CanPostMessages:       m.CanPostMessages,
+		CanEditMessages:       m.CanEditMessages,
+		CanDeleteMessages:     m.CanDeleteMessages,
+		CanManageVideoChats:   m.CanManageVideoChats,
+		CanRestrictMembers:    m.CanRestrictMembers,
+		CanPromoteMembers:     m.CanPromoteMembers,
+		CanChangeInfo:         m.CanChangeInfo,
+		CanInviteUsers:        m.CanInviteUsers,
+		CanPinMessages:        m.CanPinMessages,
+		CanSendMessages:       m.CanSendMessages,
+		CanSendMediaMessages:  m.CanSendMediaMessages,
+		CanSendPolls:          m.CanSendPolls,
+		CanSendOtherMessages:  m.CanSendOtherMessages,
+		CanAddWebPagePreviews: m.CanAddWebPagePreviews,
+	}
+
+	if m.User != nil {
+		o.User = NormalizeTelegramUser(m.User)
+	}
+
+	return o
+}
+
+func NormalizeTelegramChatJoinRequest(r *tgbotapi.ChatJoinRequest) *models.ChatJoinRequest {
+	return &models.ChatJoinRequest{
+		Chat:       NormalizeTelegramChat(&r.Chat),
+		From:       NormalizeTelegramUser(&r.From),
+		Date:       r.Date,
+		Bio:        r.Bio,
+		InviteLink: NormalizeTelegramChatInviteLink(r.InviteLink),
+	}
+}
+
+func NormalizeTelegramChatInviteLink(l *tgbotapi.ChatInviteLink) *models.ChatInviteLink {
+	if l == nil {
+		return nil
+	}
+
+	return &models.ChatInviteLink{
+		InviteLink:              l.InviteLink,
+		Creator:                 NormalizeTelegramUser(&l.Creator),
+		CreatesJoinRequest:      l.CreatesJoinRequest,
+		IsPrimary:               l.IsPrimary,
+		IsRevoked:               l.IsRevoked,
+		Name:                    l.Name,
+		ExpireDate:              l.ExpireDate,
+		MemberLimit:             l.MemberLimit,
+		PendingJoinRequestCount: l.PendingJoinRequestCount,
+	}
+}
+
 func NormalizeTelegramUser(user *tgbotapi.User) *models.User {
 	return &models.User{
 		ID:                      user.ID,
@@ -258,3 +528,51 @@ func NormalizeTelegramUser(user *tgbotapi.User) *models.User {
 		CanReadAllGroupMessages: user.CanReadAllGroupMessages,
 		SupportsInlineQueries:   user.SupportsInlineQueries}
 }
+
+// NormalizeDeliveryReceipt reports the outcome of the outbox event with the
+// given id and type, sent are the messages it sent or edited along with
+// their extras. Their files carry no url, only their file id.
+func NormalizeDeliveryReceipt(b *bot.Bot, correlationID string, eventType string, sent []tgbotapi.Message, err error) *models.DeliveryReceipt {
+	receipt := &models.DeliveryReceipt{
+		CorrelationID: correlationID,
+		Type:          eventType,
+	}
+
+	// the files of sent messages are known to the backend, resolving their
+	// urls would cost a getFile call each
+	for i := range sent {
+		m, err := normalizeTelegramMessage(b.API(), &sent[i], noFileURL)
+		if err != nil {
+			log.Printf("normalize sent message error: %v", err)
+			continue
+		}
+		NormalizeMessageExtras(m, b.SentMessageExtras(&sent[i]))
+		receipt.Messages = append(receipt.Messages, m)
+	}
+
+	if err != nil {
+		receipt.Error = NormalizeTelegramError(err)
+	}
+
+	return receipt
+}
+
+func noFileURL(fileID string) (string, error) {
+	return "", nil
+}
+
+func NormalizeTelegramError(err error) *models.DeliveryError {
+	var tgErr *tgbotapi.Error
+	if !errors.As(err, &tgErr) {
+		return &models.DeliveryError{
+			Description: err.Error(),
+		}
+	}
+
+	return &models.DeliveryError{
+		Code:            tgErr.Code,
+		Description:     tgErr.Message,
+		RetryAfter:      tgErr.RetryAfter,
+		MigrateToChatID: tgErr.MigrateToChatID,
+	}
+}
@@ -0,0 +1,299 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/botaas/telegram-bot-connector/internal/telegramtest"
+	"github.com/botaas/telegram-bot-connector/models"
+	"github.com/botaas/telegram-bot-connector/transcriber"
+)
+
+type fakeTranscriber struct {
+	audio string
+}
+
+func (f *fakeTranscriber) Transcribe(ctx context.Context, audio io.Reader, name string) (string, error) {
+	b, err := io.ReadAll(audio)
+	if err != nil {
+		return "", err
+	}
+	f.audio = string(b)
+	return "hello", nil
+}
+
+func setTranscriber(t *testing.T, tr transcriber.Transcriber) {
+	t.Helper()
+
+	previous := voiceTranscriber
+	SetTranscriber(tr)
+	t.Cleanup(func() { SetTranscriber(previous) })
+}
+
+func TestNeedsTranscription(t *testing.T) {
+	api := &tgbotapi.BotAPI{Self: tgbotapi.User{ID: 1}}
+	voice := &models.Voice{Url: "http://example.com/voice.ogg", FileID: "v"}
+
+	setTranscriber(t, transcriber.NewNoop())
+	if NeedsTranscription(api, &models.Message{Voice: voice, From: &models.User{ID: 2}}) {
+		t.Error("voice needs transcription with the noop transcriber")
+	}
+
+	setTranscriber(t, &fakeTranscriber{})
+	tests := []struct {
+		name string
+		m    *models.Message
+		want bool
+	}{
+		{"user voice", &models.Message{Voice: voice, From: &models.User{ID: 2}}, true},
+		{"channel voice", &models.Message{Voice: voice}, true},
+		{"own voice", &models.Message{Voice: voice, From: &models.User{ID: 1}}, false},
+		{"text", &models.Message{Text: "hi", From: &models.User{ID: 2}}, false},
+	}
+	for _, tt := range tests {
+		if got := NeedsTranscription(api, tt.m); got != tt.want {
+			t.Errorf("%s: NeedsTranscription = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTranscribeVoice(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ogg")
+	}))
+	defer srv.Close()
+
+	tr := &fakeTranscriber{}
+	setTranscriber(t, tr)
+
+	m := &models.Message{Voice: &models.Voice{Url: srv.URL, FileID: "v"}}
+	err := TranscribeVoice(context.Background(), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Text != "hello" {
+		t.Errorf("text = %q, want %q", m.Text, "hello")
+	}
+	if tr.audio != "ogg" {
+		t.Errorf("transcribed audio = %q, want the downloaded voice", tr.audio)
+	}
+}
+
+func TestTranscribeVoiceFailsOnDownloadErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "file not found", http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	tr := &fakeTranscriber{}
+	setTranscriber(t, tr)
+
+	m := &models.Message{Voice: &models.Voice{Url: srv.URL, FileID: "v"}}
+	err := TranscribeVoice(context.Background(), m)
+	if err == nil {
+		t.Fatal("voice transcribed from an error page")
+	}
+	if m.Text != "" || tr.audio != "" {
+		t.Errorf("text = %q from audio %q, want nothing transcribed", m.Text, tr.audio)
+	}
+}
+
+func TestNormalizeDeliveryReceiptDoesNotResolveFiles(t *testing.T) {
+	ft, b := telegramtest.New(t)
+
+	sent := []tgbotapi.Message{{
+		MessageID: 1,
+		Chat:      &tgbotapi.Chat{ID: 42},
+		Photo: []tgbotapi.PhotoSize{
+			{FileID: "small", Width: 90},
+			{FileID: "medium", Width: 320},
+			{FileID: "large", Width: 800},
+		},
+		Document: &tgbotapi.Document{FileID: "doc"},
+	}}
+	receipt := NormalizeDeliveryReceipt(b, "1", "message", sent, nil)
+
+	if calls := ft.Calls("getFile"); len(calls) != 0 {
+		t.Errorf("made %d getFile calls, want none", len(calls))
+	}
+	if len(receipt.Messages) != 1 {
+		t.Fatalf("receipt reports %d messages, want 1", len(receipt.Messages))
+	}
+
+	m := receipt.Messages[0]
+	if len(m.Photo) != 3 || m.Photo[2].FileID != "large" {
+		t.Errorf("photos = %v, want the 3 sizes with their file id", m.Photo)
+	}
+	if m.Document == nil || m.Document.FileID != "doc" {
+		t.Errorf("document = %v, want its file id", m.Document)
+	}
+}
+
+func TestNormalizeDeliveryReceiptCarriesTheMessageExtras(t *testing.T) {
+	_, b := telegramtest.New(t)
+
+	sent, err := b.ThreadAPI(5).Send(tgbotapi.NewMessage(-100, "hi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	receipt := NormalizeDeliveryReceipt(b, "1", "message", []tgbotapi.Message{sent}, nil)
+
+	if len(receipt.Messages) != 1 {
+		t.Fatalf("receipt reports %d messages, want 1", len(receipt.Messages))
+	}
+	m := receipt.Messages[0]
+	if m.MessageThreadID != 5 || !m.Chat.IsForum {
+		t.Errorf("message thread %d in forum %v, want the topic 5 of the forum", m.MessageThreadID, m.Chat.IsForum)
+	}
+}
+
+func TestNormalizeTelegramError(t *testing.T) {
+	err := fmt.Errorf("send: %w", &tgbotapi.Error{
+		Code:               429,
+		Message:            "Too Many Requests: retry after 3",
+		ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 3, MigrateToChatID: -100},
+	})
+	got := NormalizeTelegramError(err)
+	if got.Code != 429 || got.Description != "Too Many Requests: retry after 3" || got.RetryAfter != 3 || got.MigrateToChatID != -100 {
+		t.Errorf("error = %+v, want the code, description and parameters of the Telegram error", got)
+	}
+
+	// errors that did not come from Telegram have no code
+	got = NormalizeTelegramError(errors.New("connection refused"))
+	if got.Code != 0 || got.Description != "connection refused" {
+		t.Errorf("error = %+v, want the description only", got)
+	}
+}
+
+func TestNormalizeTelegramMessageFiles(t *testing.T) {
+	ft, b := telegramtest.New(t)
+
+	m := &tgbotapi.Message{
+		MessageID: 1,
+		Chat:      &tgbotapi.Chat{ID: 42},
+		Animation: &tgbotapi.Animation{FileID: "gif", Width: 320, Height: 240, Duration: 3, FileName: "cat.mp4", MimeType: "video/mp4"},
+		Document:  &tgbotapi.Document{FileID: "doc", FileName: "report.pdf", MimeType: "application/pdf", FileSize: 1024},
+		Sticker:   &tgbotapi.Sticker{FileID: "sticker", Width: 512, Height: 512, IsVideo: true, Emoji: "👍", SetName: "set"},
+		VideoNote: &tgbotapi.VideoNote{FileID: "note", Length: 240, Duration: 5},
+		Caption:   "see #report",
+		CaptionEntities: []tgbotapi.MessageEntity{
+			{Type: "hashtag", Offset: 4, Length: 7},
+		},
+	}
+	o, err := NormalizeTelegramMessage(b.API(), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(ft.Calls("getFile")); n != 4 {
+		t.Errorf("made %d getFile calls, want one per file", n)
+	}
+	if a := o.Animation; a == nil || a.FileID != "gif" || !strings.HasSuffix(a.Url, "files/gif") ||
+		a.Width != 320 || a.Height != 240 || a.Duration != 3 || a.FileName != "cat.mp4" || a.MimeType != "video/mp4" {
+		t.Errorf("animation = %+v, want the animation with its url", a)
+	}
+	if d := o.Document; d == nil || d.FileID != "doc" || !strings.HasSuffix(d.Url, "files/doc") ||
+		d.FileName != "report.pdf" || d.MimeType != "application/pdf" || d.FileSize != 1024 {
+		t.Errorf("document = %+v, want the document with its url", d)
+	}
+	if s := o.Sticker; s == nil || s.FileID != "sticker" || !strings.HasSuffix(s.Url, "files/sticker") ||
+		s.Width != 512 || !s.IsVideo || s.Emoji != "👍" || s.SetName != "set" {
+		t.Errorf("sticker = %+v, want the sticker with its url", s)
+	}
+	if v := o.VideoNote; v == nil || v.FileID != "note" || !strings.HasSuffix(v.Url, "files/note") || v.Length != 240 || v.Duration != 5 {
+		t.Errorf("video_note = %+v, want the video note with its url", v)
+	}
+	if o.Caption != "see #report" || len(o.CaptionEntities) != 1 ||
+		o.CaptionEntities[0].Type != "hashtag" || o.CaptionEntities[0].Offset != 4 || o.CaptionEntities[0].Length != 7 {
+		t.Errorf("caption = %q with entities %+v, want the caption and its hashtag", o.Caption, o.CaptionEntities)
+	}
+}
+
+func TestNormalizeTelegramMessageKeepsDocumentsTooBigToDownload(t *testing.T) {
+	ft, b := telegramtest.New(t)
+	ft.Fail(telegramtest.FailOnce("getFile", &telegramtest.Failure{Code: 400, Description: "Bad Request: file is too big"}))
+
+	m := &tgbotapi.Message{
+		MessageID: 1,
+		Chat:      &tgbotapi.Chat{ID: 42},
+		Document:  &tgbotapi.Document{FileID: "doc", FileName: "backup.tar"},
+	}
+	o, err := NormalizeTelegramMessage(b.API(), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := o.Document; d == nil || d.FileID != "doc" || d.FileName != "backup.tar" || d.Url != "" {
+		t.Errorf("document = %+v, want its file id without url", d)
+	}
+}
+
+func TestNormalizeTelegramMessageLocationsVenuesContactsDicesAndPolls(t *testing.T) {
+	_, b := telegramtest.New(t)
+
+	m := &tgbotapi.Message{
+		MessageID: 1,
+		Chat:      &tgbotapi.Chat{ID: 42},
+		Location:  &tgbotapi.Location{Latitude: 48.8584, Longitude: 2.2945, LivePeriod: 3600, Heading: 90},
+		Venue: &tgbotapi.Venue{
+			Location: tgbotapi.Location{Latitude: 48.8584, Longitude: 2.2945},
+			Title:    "Eiffel Tower",
+			Address:  "Champ de Mars",
+		},
+		Contact: &tgbotapi.Contact{PhoneNumber: "+33123456789", FirstName: "Gustave", UserID: 6},
+		Dice:    &tgbotapi.Dice{Emoji: "🎲", Value: 4},
+		Poll: &tgbotapi.Poll{
+			ID:              "p1",
+			Question:        "Capital?",
+			Options:         []tgbotapi.PollOption{{Text: "Paris", VoterCount: 2}, {Text: "Lyon"}},
+			TotalVoterCount: 2,
+			Type:            "quiz",
+			CorrectOptionID: 0,
+		},
+	}
+	o, err := NormalizeTelegramMessage(b.API(), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l := o.Location; l == nil || l.Latitude != 48.8584 || l.Longitude != 2.2945 || l.LivePeriod != 3600 || l.Heading != 90 {
+		t.Errorf("location = %+v, want the live location", l)
+	}
+	if v := o.Venue; v == nil || v.Title != "Eiffel Tower" || v.Address != "Champ de Mars" || v.Location.Latitude != 48.8584 {
+		t.Errorf("venue = %+v, want the venue and its location", v)
+	}
+	if c := o.Contact; c == nil || c.PhoneNumber != "+33123456789" || c.FirstName != "Gustave" || c.UserID != 6 {
+		t.Errorf("contact = %+v, want the contact", c)
+	}
+	if d := o.Dice; d == nil || d.Emoji != "🎲" || d.Value != 4 {
+		t.Errorf("dice = %+v, want the roll of 4", d)
+	}
+
+	p := o.Poll
+	if p == nil || p.ID != "p1" || p.Question != "Capital?" || p.TotalVoterCount != 2 || len(p.Options) != 2 ||
+		p.Options[0].Text != "Paris" || p.Options[0].VoterCount != 2 {
+		t.Fatalf("poll = %+v, want the poll and its votes", p)
+	}
+	// the first option of a quiz is a correct option, not an unset one
+	if p.CorrectOptionID == nil || *p.CorrectOptionID != 0 || p.IsAnonymous == nil || *p.IsAnonymous {
+		t.Errorf("correct option %v of anonymous %v quiz, want the first option of a named quiz", p.CorrectOptionID, p.IsAnonymous)
+	}
+}
+
+func TestNormalizeTelegramPollHasNoCorrectOptionOutsideQuizzes(t *testing.T) {
+	p := NormalizeTelegramPoll(&tgbotapi.Poll{ID: "p1", Question: "Where?", Type: "regular", IsAnonymous: true})
+	if p.CorrectOptionID != nil {
+		t.Errorf("correct option = %d, want none for regular polls", *p.CorrectOptionID)
+	}
+	if p.IsAnonymous == nil || !*p.IsAnonymous {
+		t.Errorf("anonymous = %v, want true", p.IsAnonymous)
+	}
+}
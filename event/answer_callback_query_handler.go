@@ -0,0 +1,84 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/botaas/telegram-bot-connector/bot"
+	"github.com/botaas/telegram-bot-connector/models"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+// AnswerCallbackQueryHandler answers callback queries. With AutoAck set, a
+// tracked callback query not answered by the backend within AutoAck is
+// answered with an empty response.
+type AnswerCallbackQueryHandler struct {
+	Bot     *bot.Bot
+	AutoAck time.Duration
+
+	mu      sync.Mutex
+	pending map[string]*time.Timer
+}
+
+// Track starts the auto-ack deadline of a callback query published to the
+// inbox.
+func (h *AnswerCallbackQueryHandler) Track(callbackQueryID string) {
+	if h.AutoAck <= 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.pending == nil {
+		h.pending = map[string]*time.Timer{}
+	}
+
+	h.pending[callbackQueryID] = time.AfterFunc(h.AutoAck, func() {
+		h.mu.Lock()
+		delete(h.pending, callbackQueryID)
+		h.mu.Unlock()
+
+		_, err := h.Bot.API().Request(tgbotapi.NewCallback(callbackQueryID, ""))
+		if err != nil {
+			log.Printf("auto-ack callback query %s error: %v", callbackQueryID, err)
+		}
+	})
+}
+
+func (h *AnswerCallbackQueryHandler) Handle(ctx context.Context, ev *cloudevents.Event) error {
+	var payload models.AnswerCallbackQuery
+	err := json.Unmarshal(ev.Data(), &payload)
+	if err != nil {
+		return err
+	}
+
+	if payload.CallbackQueryID == "" {
+		return fmt.Errorf("%w: callback_query_id is required", ErrInvalidPayload)
+	}
+
+	h.mu.Lock()
+	timer, exist := h.pending[payload.CallbackQueryID]
+	if exist {
+		timer.Stop()
+		delete(h.pending, payload.CallbackQueryID)
+	}
+	h.mu.Unlock()
+
+	config := tgbotapi.CallbackConfig{
+		CallbackQueryID: payload.CallbackQueryID,
+		Text:            payload.Text,
+		ShowAlert:       payload.ShowAlert,
+		URL:             payload.URL,
+		CacheTime:       payload.CacheTime,
+	}
+	_, err = h.Bot.API().Request(config)
+
+	return err
+}
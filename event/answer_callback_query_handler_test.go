@@ -0,0 +1,79 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/botaas/telegram-bot-connector/internal/telegramtest"
+	"github.com/botaas/telegram-bot-connector/models"
+)
+
+func TestAnswerCallbackQueryHandler(t *testing.T) {
+	ft, b := telegramtest.New(t)
+	h := &AnswerCallbackQueryHandler{Bot: b}
+
+	payload := &models.AnswerCallbackQuery{
+		CallbackQueryID: "c1",
+		Text:            "Saved",
+		ShowAlert:       true,
+		URL:             "https://t.me/test_bot?start=saved",
+		CacheTime:       5,
+	}
+	err := h.Handle(context.Background(), newTestEvent(t, "1", "answer_callback_query", payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := ft.Calls("answerCallbackQuery")
+	if len(calls) != 1 {
+		t.Fatalf("answerCallbackQuery called %d times, want 1", len(calls))
+	}
+	for name, want := range map[string]string{
+		"callback_query_id": "c1",
+		"text":              "Saved",
+		"show_alert":        "true",
+		"url":               "https://t.me/test_bot?start=saved",
+		"cache_time":        "5",
+	} {
+		if got := calls[0].Params.Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+
+	err = h.Handle(context.Background(), newTestEvent(t, "2", "answer_callback_query", &models.AnswerCallbackQuery{}))
+	if !errors.Is(err, ErrInvalidPayload) {
+		t.Errorf("err = %v, want ErrInvalidPayload without callback_query_id", err)
+	}
+}
+
+func TestAnswerCallbackQueryHandlerAutoAcksUnansweredQueries(t *testing.T) {
+	ft, b := telegramtest.New(t)
+	h := &AnswerCallbackQueryHandler{Bot: b, AutoAck: 50 * time.Millisecond}
+
+	h.Track("c1")
+
+	calls := ft.WaitCalls(t, 1, "answerCallbackQuery")
+	if calls[0].Params.Get("callback_query_id") != "c1" || calls[0].Params.Get("text") != "" {
+		t.Errorf("auto-ack params = %v, want an empty answer to c1", calls[0].Params)
+	}
+}
+
+func TestAnswerCallbackQueryHandlerAnswerStopsTheAutoAck(t *testing.T) {
+	ft, b := telegramtest.New(t)
+	h := &AnswerCallbackQueryHandler{Bot: b, AutoAck: 50 * time.Millisecond}
+
+	h.Track("c1")
+	err := h.Handle(context.Background(), newTestEvent(t, "1", "answer_callback_query", &models.AnswerCallbackQuery{CallbackQueryID: "c1", Text: "Saved"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	// a callback query can only be answered once
+	calls := ft.Calls("answerCallbackQuery")
+	if len(calls) != 1 || calls[0].Params.Get("text") != "Saved" {
+		t.Errorf("answerCallbackQuery calls = %v, want the answer of the backend only", calls)
+	}
+}
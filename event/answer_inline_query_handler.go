@@ -0,0 +1,218 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/botaas/telegram-bot-connector/bot"
+	"github.com/botaas/telegram-bot-connector/models"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+type AnswerInlineQueryHandler struct {
+	Bot *bot.Bot
+}
+
+func (h *AnswerInlineQueryHandler) Handle(ctx context.Context, ev *cloudevents.Event) error {
+	var payload models.AnswerInlineQuery
+	err := json.Unmarshal(ev.Data(), &payload)
+	if err != nil {
+		return err
+	}
+
+	if payload.InlineQueryID == "" {
+		return fmt.Errorf("%w: inline_query_id is required", ErrInvalidPayload)
+	}
+
+	results := make([]interface{}, 0, len(payload.Results))
+	for _, r := range payload.Results {
+		result, err := newInlineQueryResult(r)
+		if err != nil {
+			return err
+		}
+		results = append(results, result)
+	}
+
+	config := tgbotapi.InlineConfig{
+		InlineQueryID:     payload.InlineQueryID,
+		Results:           results,
+		CacheTime:         payload.CacheTime,
+		IsPersonal:        payload.IsPersonal,
+		NextOffset:        payload.NextOffset,
+		SwitchPMText:      payload.SwitchPMText,
+		SwitchPMParameter: payload.SwitchPMParameter,
+	}
+	_, err = h.Bot.API().Request(config)
+
+	return err
+}
+
+// newInlineQueryResult converts r to the matching tgbotapi.InlineQueryResult
+// type, cached when r has a FileID.
+func newInlineQueryResult(r *models.InlineQueryResult) (interface{}, error) {
+	if r == nil || r.ID == "" {
+		return nil, fmt.Errorf("%w: inline query result id is required", ErrInvalidPayload)
+	}
+
+	var content interface{}
+	if r.MessageText != "" {
+		content = tgbotapi.InputTextMessageContent{
+			Text:                  r.MessageText,
+			DisableWebPagePreview: r.DisableWebPagePreview,
+		}
+	}
+
+	var markup *tgbotapi.InlineKeyboardMarkup
+	if r.InlineKeyboardMarkup != nil {
+		m, err := marshalInlineKeyboardMarkup(r.InlineKeyboardMarkup)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+		}
+		markup = &m
+	}
+
+	cached := r.FileID != ""
+	if !cached && r.URL == "" && r.Type != "article" {
+		return nil, fmt.Errorf("%w: %s result %s needs an url or a file_id", ErrInvalidPayload, r.Type, r.ID)
+	}
+
+	switch r.Type {
+	case "article":
+		if r.Title == "" || content == nil {
+			return nil, fmt.Errorf("%w: article result %s needs a title and a message_text", ErrInvalidPayload, r.ID)
+		}
+		return tgbotapi.InlineQueryResultArticle{
+			Type:                "article",
+			ID:                  r.ID,
+			Title:               r.Title,
+			InputMessageContent: content,
+			ReplyMarkup:         markup,
+			URL:                 r.URL,
+			Description:         r.Description,
+			ThumbURL:            r.ThumbURL,
+		}, nil
+	case "photo":
+		if cached {
+			return tgbotapi.InlineQueryResultCachedPhoto{
+				Type:                "photo",
+				ID:                  r.ID,
+				PhotoID:             r.FileID,
+				Title:               r.Title,
+				Description:         r.Description,
+				Caption:             r.Caption,
+				ReplyMarkup:         markup,
+				InputMessageContent: content,
+			}, nil
+		}
+		thumbURL := r.ThumbURL
+		if thumbURL == "" {
+			thumbURL = r.URL
+		}
+		return tgbotapi.InlineQueryResultPhoto{
+			Type:                "photo",
+			ID:                  r.ID,
+			URL:                 r.URL,
+			Width:               r.Width,
+			Height:              r.Height,
+			ThumbURL:            thumbURL,
+			Title:               r.Title,
+			Description:         r.Description,
+			Caption:             r.Caption,
+			ReplyMarkup:         markup,
+			InputMessageContent: content,
+		}, nil
+	case "gif":
+		if cached {
+			return tgbotapi.InlineQueryResultCachedGIF{
+				Type:                "gif",
+				ID:                  r.ID,
+				GIFID:               r.FileID,
+				Title:               r.Title,
+				Caption:             r.Caption,
+				ReplyMarkup:         markup,
+				InputMessageContent: content,
+			}, nil
+		}
+		thumbURL := r.ThumbURL
+		if thumbURL == "" {
+			thumbURL = r.URL
+		}
+		return tgbotapi.InlineQueryResultGIF{
+			Type:                "gif",
+			ID:                  r.ID,
+			URL:                 r.URL,
+			ThumbURL:            thumbURL,
+			Width:               r.Width,
+			Height:              r.Height,
+			Duration:            r.Duration,
+			Title:               r.Title,
+			Caption:             r.Caption,
+			ReplyMarkup:         markup,
+			InputMessageContent: content,
+		}, nil
+	case "video":
+		if r.Title == "" {
+			return nil, fmt.Errorf("%w: video result %s needs a title", ErrInvalidPayload, r.ID)
+		}
+		if cached {
+			return tgbotapi.InlineQueryResultCachedVideo{
+				Type:                "video",
+				ID:                  r.ID,
+				VideoID:             r.FileID,
+				Title:               r.Title,
+				Description:         r.Description,
+				Caption:             r.Caption,
+				ReplyMarkup:         markup,
+				InputMessageContent: content,
+			}, nil
+		}
+		return tgbotapi.InlineQueryResultVideo{
+			Type:                "video",
+			ID:                  r.ID,
+			URL:                 r.URL,
+			MimeType:            r.MimeType,
+			ThumbURL:            r.ThumbURL,
+			Title:               r.Title,
+			Caption:             r.Caption,
+			Width:               r.Width,
+			Height:              r.Height,
+			Duration:            r.Duration,
+			Description:         r.Description,
+			ReplyMarkup:         markup,
+			InputMessageContent: content,
+		}, nil
+	case "document":
+		if r.Title == "" {
+			return nil, fmt.Errorf("%w: document result %s needs a title", ErrInvalidPayload, r.ID)
+		}
+		if cached {
+			return tgbotapi.InlineQueryResultCachedDocument{
+				Type:                "document",
+				ID:                  r.ID,
+				DocumentID:          r.FileID,
+				Title:               r.Title,
+				Description:         r.Description,
+				Caption:             r.Caption,
+				ReplyMarkup:         markup,
+				InputMessageContent: content,
+			}, nil
+		}
+		return tgbotapi.InlineQueryResultDocument{
+			Type:                "document",
+			ID:                  r.ID,
+			Title:               r.Title,
+			Caption:             r.Caption,
+			URL:                 r.URL,
+			MimeType:            r.MimeType,
+			Description:         r.Description,
+			ReplyMarkup:         markup,
+			InputMessageContent: content,
+			ThumbURL:            r.ThumbURL,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("%w: unknown inline query result type %q", ErrInvalidPayload, r.Type)
+}
@@ -0,0 +1,95 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/botaas/telegram-bot-connector/internal/telegramtest"
+	"github.com/botaas/telegram-bot-connector/models"
+)
+
+func TestAnswerInlineQueryHandler(t *testing.T) {
+	ft, b := telegramtest.New(t)
+	h := &AnswerInlineQueryHandler{Bot: b}
+
+	payload := &models.AnswerInlineQuery{
+		InlineQueryID: "q1",
+		Results: []*models.InlineQueryResult{
+			{Type: "article", ID: "a", Title: "Article", MessageText: "text"},
+			{Type: "photo", ID: "p", URL: "https://example.com/p.jpg"},
+			{Type: "document", ID: "d", Title: "Document", FileID: "doc"},
+		},
+		CacheTime:         10,
+		IsPersonal:        true,
+		NextOffset:        "3",
+		SwitchPMText:      "Sign in",
+		SwitchPMParameter: "login",
+	}
+	err := h.Handle(context.Background(), newTestEvent(t, "1", "answer_inline_query", payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := ft.Calls("answerInlineQuery")
+	if len(calls) != 1 {
+		t.Fatalf("answerInlineQuery called %d times, want 1", len(calls))
+	}
+	params := calls[0].Params
+	for name, want := range map[string]string{
+		"inline_query_id":     "q1",
+		"cache_time":          "10",
+		"is_personal":         "true",
+		"next_offset":         "3",
+		"switch_pm_text":      "Sign in",
+		"switch_pm_parameter": "login",
+	} {
+		if got := params.Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+
+	var results []map[string]any
+	err = json.Unmarshal([]byte(params.Get("results")), &results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("sent %d results, want 3", len(results))
+	}
+	if content, _ := results[0]["input_message_content"].(map[string]any); content["message_text"] != "text" {
+		t.Errorf("article = %v, want its message text", results[0])
+	}
+	// the photo is its own thumbnail
+	if results[1]["photo_url"] != "https://example.com/p.jpg" || results[1]["thumb_url"] != "https://example.com/p.jpg" {
+		t.Errorf("photo = %v, want its url as thumbnail", results[1])
+	}
+	// a file id sends the cached document
+	if results[2]["document_file_id"] != "doc" {
+		t.Errorf("document = %v, want the cached document", results[2])
+	}
+}
+
+func TestAnswerInlineQueryHandlerRejectsInvalidResults(t *testing.T) {
+	ft, b := telegramtest.New(t)
+	h := &AnswerInlineQueryHandler{Bot: b}
+
+	for name, payload := range map[string]*models.AnswerInlineQuery{
+		"without query id":       {Results: []*models.InlineQueryResult{{Type: "article", ID: "a", Title: "A", MessageText: "text"}}},
+		"article without text":   {InlineQueryID: "q1", Results: []*models.InlineQueryResult{{Type: "article", ID: "a", Title: "A"}}},
+		"photo without source":   {InlineQueryID: "q1", Results: []*models.InlineQueryResult{{Type: "photo", ID: "p"}}},
+		"video without title":    {InlineQueryID: "q1", Results: []*models.InlineQueryResult{{Type: "video", ID: "v", FileID: "video"}}},
+		"result without id":      {InlineQueryID: "q1", Results: []*models.InlineQueryResult{{Type: "photo", FileID: "photo"}}},
+		"unknown type of result": {InlineQueryID: "q1", Results: []*models.InlineQueryResult{{Type: "game", ID: "g", FileID: "game"}}},
+	} {
+		err := h.Handle(context.Background(), newTestEvent(t, "1", "answer_inline_query", payload))
+		if !errors.Is(err, ErrInvalidPayload) {
+			t.Errorf("%s: err = %v, want ErrInvalidPayload", name, err)
+		}
+	}
+
+	if calls := ft.Calls(); len(calls) != 0 {
+		t.Errorf("made %d calls, want none", len(calls))
+	}
+}
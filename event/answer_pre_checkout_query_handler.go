@@ -0,0 +1,103 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/botaas/telegram-bot-connector/bot"
+	"github.com/botaas/telegram-bot-connector/models"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+// AnswerPreCheckoutQueryHandler answers pre-checkout queries. An answer to a
+// query waited for with Wait is handed to the waiter, others are sent to
+// Telegram directly.
+type AnswerPreCheckoutQueryHandler struct {
+	Bot *bot.Bot
+
+	mu      sync.Mutex
+	pending map[string]chan *models.AnswerPreCheckoutQuery
+}
+
+// Expect registers a pre-checkout query before it is published so that an
+// early answer is not missed, Wait must follow.
+func (h *AnswerPreCheckoutQueryHandler) Expect(preCheckoutQueryID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.pending == nil {
+		h.pending = map[string]chan *models.AnswerPreCheckoutQuery{}
+	}
+	h.pending[preCheckoutQueryID] = make(chan *models.AnswerPreCheckoutQuery, 1)
+}
+
+// Wait returns the answer of the backend to an expected pre-checkout query,
+// false when none came within timeout.
+func (h *AnswerPreCheckoutQueryHandler) Wait(preCheckoutQueryID string, timeout time.Duration) (*models.AnswerPreCheckoutQuery, bool) {
+	h.mu.Lock()
+	ch, exist := h.pending[preCheckoutQueryID]
+	h.mu.Unlock()
+	if !exist {
+		return nil, false
+	}
+
+	defer func() {
+		h.mu.Lock()
+		delete(h.pending, preCheckoutQueryID)
+		h.mu.Unlock()
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case answer := <-ch:
+		return answer, true
+	case <-timer.C:
+		return nil, false
+	}
+}
+
+func (h *AnswerPreCheckoutQueryHandler) Handle(ctx context.Context, ev *cloudevents.Event) error {
+	var payload models.AnswerPreCheckoutQuery
+	err := json.Unmarshal(ev.Data(), &payload)
+	if err != nil {
+		return err
+	}
+
+	if payload.PreCheckoutQueryID == "" {
+		return fmt.Errorf("%w: pre_checkout_query_id is required", ErrInvalidPayload)
+	}
+
+	h.mu.Lock()
+	ch, exist := h.pending[payload.PreCheckoutQueryID]
+	h.mu.Unlock()
+	if exist {
+		select {
+		case ch <- &payload:
+		default:
+		}
+		return nil
+	}
+
+	return h.Answer(&payload)
+}
+
+// Answer sends answer to Telegram.
+func (h *AnswerPreCheckoutQueryHandler) Answer(answer *models.AnswerPreCheckoutQuery) error {
+	// tgbotapi.PreCheckoutConfig omits ok when false, though it is required
+	params := tgbotapi.Params{
+		"pre_checkout_query_id": answer.PreCheckoutQueryID,
+		"ok":                    strconv.FormatBool(answer.OK),
+	}
+	params.AddNonEmpty("error_message", answer.ErrorMessage)
+	_, err := h.Bot.API().MakeRequest("answerPreCheckoutQuery", params)
+
+	return err
+}
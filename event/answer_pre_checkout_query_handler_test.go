@@ -0,0 +1,63 @@
+package event
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/botaas/telegram-bot-connector/internal/telegramtest"
+	"github.com/botaas/telegram-bot-connector/models"
+)
+
+func TestAnswerPreCheckoutQueryHandlerAnswersUnexpectedQueries(t *testing.T) {
+	ft, b := telegramtest.New(t)
+	h := &AnswerPreCheckoutQueryHandler{Bot: b}
+
+	payload := &models.AnswerPreCheckoutQuery{PreCheckoutQueryID: "q1", ErrorMessage: "Out of stock"}
+	err := h.Handle(context.Background(), newTestEvent(t, "1", "answer_pre_checkout_query", payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := ft.Calls("answerPreCheckoutQuery")
+	if len(calls) != 1 {
+		t.Fatalf("answerPreCheckoutQuery called %d times, want 1", len(calls))
+	}
+	// ok is required, false included
+	params := calls[0].Params
+	if params.Get("pre_checkout_query_id") != "q1" || params.Get("ok") != "false" || params.Get("error_message") != "Out of stock" {
+		t.Errorf("answerPreCheckoutQuery params = %v, want the rejection of q1", params)
+	}
+}
+
+func TestAnswerPreCheckoutQueryHandlerHandsExpectedAnswersToTheWaiter(t *testing.T) {
+	ft, b := telegramtest.New(t)
+	h := &AnswerPreCheckoutQueryHandler{Bot: b}
+
+	h.Expect("q1")
+	err := h.Handle(context.Background(), newTestEvent(t, "1", "answer_pre_checkout_query", &models.AnswerPreCheckoutQuery{PreCheckoutQueryID: "q1", OK: true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	answer, answered := h.Wait("q1", time.Second)
+	if !answered || !answer.OK {
+		t.Errorf("waited for %+v, %v, want the answer of the backend", answer, answered)
+	}
+	if calls := ft.Calls(); len(calls) != 0 {
+		t.Errorf("made %d calls, want the waiter to answer", len(calls))
+	}
+}
+
+func TestAnswerPreCheckoutQueryHandlerWaitTimesOut(t *testing.T) {
+	_, b := telegramtest.New(t)
+	h := &AnswerPreCheckoutQueryHandler{Bot: b}
+
+	h.Expect("q1")
+	if _, answered := h.Wait("q1", 10*time.Millisecond); answered {
+		t.Error("answered without an answer of the backend")
+	}
+	if _, answered := h.Wait("q2", time.Second); answered {
+		t.Error("answered a query that was not expected")
+	}
+}
@@ -0,0 +1,75 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/botaas/telegram-bot-connector/bot"
+	"github.com/botaas/telegram-bot-connector/models"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+type AnswerShippingQueryHandler struct {
+	Bot *bot.Bot
+}
+
+func (h *AnswerShippingQueryHandler) Handle(ctx context.Context, ev *cloudevents.Event) error {
+	var payload models.AnswerShippingQuery
+	err := json.Unmarshal(ev.Data(), &payload)
+	if err != nil {
+		return err
+	}
+
+	if payload.ShippingQueryID == "" {
+		return fmt.Errorf("%w: shipping_query_id is required", ErrInvalidPayload)
+	}
+
+	if payload.OK && len(payload.ShippingOptions) == 0 {
+		return fmt.Errorf("%w: shipping_options are required when ok", ErrInvalidPayload)
+	}
+
+	if !payload.OK && payload.ErrorMessage == "" {
+		return fmt.Errorf("%w: error_message is required when not ok", ErrInvalidPayload)
+	}
+
+	var options []tgbotapi.ShippingOption
+	for _, o := range payload.ShippingOptions {
+		var prices []tgbotapi.LabeledPrice
+		for _, p := range o.Prices {
+			price := tgbotapi.LabeledPrice{
+				Label:  p.Label,
+				Amount: p.Amount,
+			}
+
+			prices = append(prices, price)
+		}
+
+		option := tgbotapi.ShippingOption{
+			ID:     o.ID,
+			Title:  o.Title,
+			Prices: prices,
+		}
+
+		options = append(options, option)
+	}
+
+	// tgbotapi.ShippingConfig omits ok when false, though it is required
+	params := tgbotapi.Params{
+		"shipping_query_id": payload.ShippingQueryID,
+		"ok":                strconv.FormatBool(payload.OK),
+	}
+	params.AddNonEmpty("error_message", payload.ErrorMessage)
+	if payload.OK {
+		err = params.AddInterface("shipping_options", options)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = h.Bot.API().MakeRequest("answerShippingQuery", params)
+
+	return err
+}
@@ -0,0 +1,89 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/botaas/telegram-bot-connector/internal/telegramtest"
+	"github.com/botaas/telegram-bot-connector/models"
+)
+
+func TestAnswerShippingQueryHandlerOffersTheShippingOptions(t *testing.T) {
+	ft, b := telegramtest.New(t)
+	h := &AnswerShippingQueryHandler{Bot: b}
+
+	payload := &models.AnswerShippingQuery{
+		ShippingQueryID: "s1",
+		OK:              true,
+		ShippingOptions: []models.ShippingOption{{
+			ID:     "post",
+			Title:  "Post",
+			Prices: []models.LabeledPrice{{Label: "Delivery", Amount: 500}},
+		}},
+	}
+	err := h.Handle(context.Background(), newTestEvent(t, "1", "answer_shipping_query", payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := ft.Calls("answerShippingQuery")
+	if len(calls) != 1 {
+		t.Fatalf("answerShippingQuery called %d times, want 1", len(calls))
+	}
+	params := calls[0].Params
+	if params.Get("shipping_query_id") != "s1" || params.Get("ok") != "true" {
+		t.Errorf("answerShippingQuery params = %v, want the approval of s1", params)
+	}
+
+	var options []models.ShippingOption
+	err = json.Unmarshal([]byte(params.Get("shipping_options")), &options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(options) != 1 || options[0].ID != "post" || options[0].Title != "Post" ||
+		len(options[0].Prices) != 1 || options[0].Prices[0].Amount != 500 {
+		t.Errorf("shipping_options = %+v, want the options of the payload", options)
+	}
+}
+
+func TestAnswerShippingQueryHandlerRejectsTheAddress(t *testing.T) {
+	ft, b := telegramtest.New(t)
+	h := &AnswerShippingQueryHandler{Bot: b}
+
+	payload := &models.AnswerShippingQuery{ShippingQueryID: "s1", ErrorMessage: "No delivery to Mars"}
+	err := h.Handle(context.Background(), newTestEvent(t, "1", "answer_shipping_query", payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := ft.Calls("answerShippingQuery")
+	if len(calls) != 1 {
+		t.Fatalf("answerShippingQuery called %d times, want 1", len(calls))
+	}
+	// ok is required, false included
+	params := calls[0].Params
+	if params.Get("ok") != "false" || params.Get("error_message") != "No delivery to Mars" || params.Has("shipping_options") {
+		t.Errorf("answerShippingQuery params = %v, want the rejection of s1", params)
+	}
+}
+
+func TestAnswerShippingQueryHandlerRejectsInvalidPayloads(t *testing.T) {
+	ft, b := telegramtest.New(t)
+	h := &AnswerShippingQueryHandler{Bot: b}
+
+	for _, payload := range []*models.AnswerShippingQuery{
+		{OK: true, ShippingOptions: []models.ShippingOption{{ID: "post"}}},
+		{ShippingQueryID: "s1", OK: true},
+		{ShippingQueryID: "s1"},
+	} {
+		err := h.Handle(context.Background(), newTestEvent(t, "1", "answer_shipping_query", payload))
+		if !errors.Is(err, ErrInvalidPayload) {
+			t.Errorf("handling %+v failed with %v, want ErrInvalidPayload", payload, err)
+		}
+	}
+	if calls := ft.Calls(); len(calls) != 0 {
+		t.Errorf("made %d calls, want none for invalid payloads", len(calls))
+	}
+}
@@ -23,7 +23,7 @@ func (h *ChatActionHandler) Handle(ctx context.Context, ev *cloudevents.Event) e
 	}
 
 	action := tgbotapi.NewChatAction(payload.ChatID, payload.Action)
-	_, err = h.Bot.API().Request(action)
+	_, err = h.Bot.ThreadAPI(payload.MessageThreadID).Request(action)
 
 	return err
 }
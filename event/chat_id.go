@@ -0,0 +1,43 @@
+package event
+
+import (
+	"encoding/json"
+	"strconv"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+type chatPayload struct {
+	ChatID int64 `json:"chat_id"`
+	Chat   *struct {
+		ID int64 `json:"id"`
+	} `json:"chat"`
+}
+
+// ChatID returns the destination chat of an outbox event, read from its
+// subject when it is a chat id, else from the chat_id or chat.id field of its
+// payload.
+func ChatID(ev *cloudevents.Event) (int64, bool) {
+	if subject := ev.Subject(); len(subject) > 0 {
+		chatID, err := strconv.ParseInt(subject, 10, 64)
+		if err == nil && chatID != 0 {
+			return chatID, true
+		}
+	}
+
+	var payload chatPayload
+	err := json.Unmarshal(ev.Data(), &payload)
+	if err != nil {
+		return 0, false
+	}
+
+	if payload.ChatID != 0 {
+		return payload.ChatID, true
+	}
+
+	if payload.Chat != nil && payload.Chat.ID != 0 {
+		return payload.Chat.ID, true
+	}
+
+	return 0, false
+}
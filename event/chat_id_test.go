@@ -0,0 +1,38 @@
+package event
+
+import (
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+func TestChatID(t *testing.T) {
+	tests := []struct {
+		name    string
+		subject string
+		data    string
+		want    int64
+		exist   bool
+	}{
+		{"subject", "42", `{"chat_id": 7}`, 42, true},
+		{"negative subject", "-100123", `{}`, -100123, true},
+		{"chat_id", "", `{"chat_id": 7}`, 7, true},
+		{"chat.id", "", `{"chat": {"id": -7}}`, -7, true},
+		{"chat_id before chat.id", "", `{"chat_id": 7, "chat": {"id": 8}}`, 7, true},
+		{"subject not a chat id", "stream-1", `{"chat_id": 7}`, 7, true},
+		{"zero subject", "0", `{"chat_id": 7}`, 7, true},
+		{"no chat", "", `{"callback_query_id": "1"}`, 0, false},
+		{"invalid payload", "", `not json`, 0, false},
+	}
+
+	for _, tt := range tests {
+		ev := cloudevents.NewEvent()
+		ev.SetSubject(tt.subject)
+		ev.DataEncoded = []byte(tt.data)
+
+		got, exist := ChatID(&ev)
+		if got != tt.want || exist != tt.exist {
+			t.Errorf("%s: ChatID = %d, %v, want %d, %v", tt.name, got, exist, tt.want, tt.exist)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/botaas/telegram-bot-connector/bot"
+	"github.com/botaas/telegram-bot-connector/models"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+// ChatJoinRequestHandler approves chat join requests, or declines them when
+// Decline is set.
+type ChatJoinRequestHandler struct {
+	Bot     *bot.Bot
+	Decline bool
+}
+
+func (h *ChatJoinRequestHandler) Handle(ctx context.Context, ev *cloudevents.Event) error {
+	var payload models.ChatJoinRequestAnswer
+	err := json.Unmarshal(ev.Data(), &payload)
+	if err != nil {
+		return err
+	}
+
+	if payload.ChatID == 0 || payload.UserID == 0 {
+		return fmt.Errorf("%w: chat_id and user_id are required", ErrInvalidPayload)
+	}
+
+	chat := tgbotapi.ChatConfig{ChatID: payload.ChatID}
+	if h.Decline {
+		_, err = h.Bot.API().Request(tgbotapi.DeclineChatJoinRequest{
+			ChatConfig: chat,
+			UserID:     payload.UserID,
+		})
+	} else {
+		_, err = h.Bot.API().Request(tgbotapi.ApproveChatJoinRequestConfig{
+			ChatConfig: chat,
+			UserID:     payload.UserID,
+		})
+	}
+
+	return err
+}
@@ -0,0 +1,51 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/botaas/telegram-bot-connector/internal/telegramtest"
+	"github.com/botaas/telegram-bot-connector/models"
+)
+
+func TestChatJoinRequestHandler(t *testing.T) {
+	for _, tt := range []struct {
+		decline bool
+		method  string
+	}{
+		{false, "approveChatJoinRequest"},
+		{true, "declineChatJoinRequest"},
+	} {
+		ft, b := telegramtest.New(t)
+		h := &ChatJoinRequestHandler{Bot: b, Decline: tt.decline}
+
+		err := h.Handle(context.Background(), newTestEvent(t, "1", "approve_chat_join_request", &models.ChatJoinRequestAnswer{ChatID: -100, UserID: 5}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		calls := ft.Calls()
+		if len(calls) != 1 || calls[0].Method != tt.method {
+			t.Fatalf("calls = %+v, want a single %s", calls, tt.method)
+		}
+		if params := calls[0].Params; params.Get("chat_id") != "-100" || params.Get("user_id") != "5" {
+			t.Errorf("%s params = %v, want the chat and the user", tt.method, params)
+		}
+	}
+}
+
+func TestChatJoinRequestHandlerRequiresTheChatAndTheUser(t *testing.T) {
+	ft, b := telegramtest.New(t)
+	h := &ChatJoinRequestHandler{Bot: b}
+
+	for _, payload := range []*models.ChatJoinRequestAnswer{{ChatID: -100}, {UserID: 5}} {
+		err := h.Handle(context.Background(), newTestEvent(t, "1", "approve_chat_join_request", payload))
+		if !errors.Is(err, ErrInvalidPayload) {
+			t.Errorf("handling %+v failed with %v, want ErrInvalidPayload", payload, err)
+		}
+	}
+	if calls := ft.Calls(); len(calls) != 0 {
+		t.Errorf("made %d calls, want none for invalid payloads", len(calls))
+	}
+}
@@ -0,0 +1,29 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/botaas/telegram-bot-connector/bot"
+	"github.com/botaas/telegram-bot-connector/models"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+type DeleteMessageHandler struct {
+	Bot *bot.Bot
+}
+
+func (h *DeleteMessageHandler) Handle(ctx context.Context, ev *cloudevents.Event) error {
+	var payload models.DeleteMessage
+	err := json.Unmarshal(ev.Data(), &payload)
+	if err != nil {
+		return err
+	}
+
+	msg := tgbotapi.NewDeleteMessage(payload.ChatID, payload.MessageID)
+	_, err = h.Bot.API().Request(msg)
+
+	return err
+}
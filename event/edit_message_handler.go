@@ -0,0 +1,184 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/botaas/telegram-bot-connector/bot"
+	"github.com/botaas/telegram-bot-connector/models"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+func newBaseEdit(e *models.EditMessage) (tgbotapi.BaseEdit, error) {
+	edit := tgbotapi.BaseEdit{
+		ChatID:          e.ChatID,
+		MessageID:       e.MessageID,
+		InlineMessageID: e.InlineMessageID,
+	}
+
+	if len(e.InlineMessageID) == 0 && (e.ChatID == 0 || e.MessageID == 0) {
+		return edit, fmt.Errorf("%w: either chat_id and message_id or inline_message_id is required", ErrInvalidPayload)
+	}
+
+	if e.InlineKeyboardMarkup != nil {
+		markup, err := marshalInlineKeyboardMarkup(e.InlineKeyboardMarkup)
+		if err != nil {
+			return edit, err
+		}
+		edit.ReplyMarkup = &markup
+	}
+
+	return edit, nil
+}
+
+// sendEdit sends an edit, Telegram only returns the edited message when it is
+// not an inline one.
+func sendEdit(api *tgbotapi.BotAPI, c tgbotapi.Chattable, inlineMessageID string) ([]tgbotapi.Message, error) {
+	if len(inlineMessageID) > 0 {
+		_, err := api.Request(c)
+		return nil, err
+	}
+
+	m, err := api.Send(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return []tgbotapi.Message{m}, nil
+}
+
+type EditMessageTextHandler struct {
+	Bot *bot.Bot
+	// Format is the default format, see MessageHandler.Format.
+	Format string
+}
+
+func (h *EditMessageTextHandler) Handle(ctx context.Context, ev *cloudevents.Event) error {
+	_, err := h.Send(ctx, ev)
+	return err
+}
+
+func (h *EditMessageTextHandler) Send(ctx context.Context, ev *cloudevents.Event) ([]tgbotapi.Message, error) {
+	var payload models.EditMessageText
+	err := json.Unmarshal(ev.Data(), &payload)
+	if err != nil {
+		return nil, err
+	}
+
+	edit, err := newBaseEdit(&payload.EditMessage)
+	if err != nil {
+		return nil, err
+	}
+
+	text, parseMode := formatText(h.Format, payload.Text, payload.ParseMode, payload.Entities)
+
+	msg := tgbotapi.EditMessageTextConfig{
+		BaseEdit:              edit,
+		Text:                  text,
+		ParseMode:             parseMode,
+		Entities:              newMessageEntities(payload.Entities),
+		DisableWebPagePreview: payload.DisableWebPagePreview,
+	}
+	return sendEdit(h.Bot.API(), msg, edit.InlineMessageID)
+}
+
+type EditMessageCaptionHandler struct {
+	Bot *bot.Bot
+	// Format is the default format, see MessageHandler.Format.
+	Format string
+}
+
+func (h *EditMessageCaptionHandler) Handle(ctx context.Context, ev *cloudevents.Event) error {
+	_, err := h.Send(ctx, ev)
+	return err
+}
+
+func (h *EditMessageCaptionHandler) Send(ctx context.Context, ev *cloudevents.Event) ([]tgbotapi.Message, error) {
+	var payload models.EditMessageCaption
+	err := json.Unmarshal(ev.Data(), &payload)
+	if err != nil {
+		return nil, err
+	}
+
+	edit, err := newBaseEdit(&payload.EditMessage)
+	if err != nil {
+		return nil, err
+	}
+
+	caption, parseMode := formatText(h.Format, payload.Caption, payload.ParseMode, payload.CaptionEntities)
+
+	msg := tgbotapi.EditMessageCaptionConfig{
+		BaseEdit:        edit,
+		Caption:         caption,
+		ParseMode:       parseMode,
+		CaptionEntities: newMessageEntities(payload.CaptionEntities),
+	}
+	return sendEdit(h.Bot.API(), msg, edit.InlineMessageID)
+}
+
+type EditMessageReplyMarkupHandler struct {
+	Bot *bot.Bot
+}
+
+func (h *EditMessageReplyMarkupHandler) Handle(ctx context.Context, ev *cloudevents.Event) error {
+	_, err := h.Send(ctx, ev)
+	return err
+}
+
+func (h *EditMessageReplyMarkupHandler) Send(ctx context.Context, ev *cloudevents.Event) ([]tgbotapi.Message, error) {
+	var payload models.EditMessageReplyMarkup
+	err := json.Unmarshal(ev.Data(), &payload)
+	if err != nil {
+		return nil, err
+	}
+
+	edit, err := newBaseEdit(&payload.EditMessage)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := tgbotapi.EditMessageReplyMarkupConfig{
+		BaseEdit: edit,
+	}
+	return sendEdit(h.Bot.API(), msg, edit.InlineMessageID)
+}
+
+type EditMessageMediaHandler struct {
+	Bot *bot.Bot
+	// Format is the default format, see MessageHandler.Format.
+	Format string
+}
+
+func (h *EditMessageMediaHandler) Handle(ctx context.Context, ev *cloudevents.Event) error {
+	_, err := h.Send(ctx, ev)
+	return err
+}
+
+func (h *EditMessageMediaHandler) Send(ctx context.Context, ev *cloudevents.Event) ([]tgbotapi.Message, error) {
+	var payload models.EditMessageMedia
+	err := json.Unmarshal(ev.Data(), &payload)
+	if err != nil {
+		return nil, err
+	}
+
+	edit, err := newBaseEdit(&payload.EditMessage)
+	if err != nil {
+		return nil, err
+	}
+
+	payload.Media.Caption, payload.Media.ParseMode = formatText(h.Format, payload.Media.Caption, payload.Media.ParseMode, payload.Media.CaptionEntities)
+
+	media := newInputMedia(&payload.Media)
+	if media == nil {
+		return nil, fmt.Errorf("%w: unsupported media type: %s", ErrInvalidPayload, payload.Media.Type)
+	}
+
+	msg := tgbotapi.EditMessageMediaConfig{
+		BaseEdit: edit,
+		Media:    media,
+	}
+	return sendEdit(h.Bot.API(), msg, edit.InlineMessageID)
+}
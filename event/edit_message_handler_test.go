@@ -0,0 +1,158 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/botaas/telegram-bot-connector/internal/telegramtest"
+	"github.com/botaas/telegram-bot-connector/models"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+func TestEditMessageTextHandlerEditsAMessageOfAChat(t *testing.T) {
+	ft, b := telegramtest.New(t)
+	h := &EditMessageTextHandler{Bot: b}
+
+	payload := &models.EditMessageText{
+		EditMessage: models.EditMessage{ChatID: 42, MessageID: 7},
+		Text:        "edited",
+	}
+	sent, err := h.Send(context.Background(), newTestEvent(t, "1", "edit_message_text", payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := ft.Calls("editMessageText")
+	if len(calls) != 1 {
+		t.Fatalf("editMessageText called %d times, want 1", len(calls))
+	}
+	params := calls[0].Params
+	if params.Get("chat_id") != "42" || params.Get("message_id") != "7" || params.Get("text") != "edited" {
+		t.Errorf("editMessageText params = %v, want the text of message 7 of chat 42", params)
+	}
+	if len(sent) != 1 || sent[0].MessageID != 7 {
+		t.Errorf("reported %v, want the edited message", sent)
+	}
+}
+
+func TestEditMessageTextHandlerEditsInlineMessages(t *testing.T) {
+	ft, b := telegramtest.New(t)
+	h := &EditMessageTextHandler{Bot: b}
+
+	payload := &models.EditMessageText{
+		EditMessage: models.EditMessage{InlineMessageID: "inline"},
+		Text:        "edited",
+	}
+	sent, err := h.Send(context.Background(), newTestEvent(t, "1", "edit_message_text", payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := ft.Calls("editMessageText")
+	if len(calls) != 1 || calls[0].Params.Get("inline_message_id") != "inline" || calls[0].Params.Has("chat_id") {
+		t.Fatalf("editMessageText calls = %v, want one for the inline message only", calls)
+	}
+	// Telegram does not return inline messages
+	if len(sent) != 0 {
+		t.Errorf("reported %d messages, want none", len(sent))
+	}
+}
+
+func TestEditHandlersRequireATarget(t *testing.T) {
+	ft, b := telegramtest.New(t)
+
+	for eventType, h := range map[string]interface {
+		Handle(ctx context.Context, ev *cloudevents.Event) error
+	}{
+		"edit_message_text":         &EditMessageTextHandler{Bot: b},
+		"edit_message_caption":      &EditMessageCaptionHandler{Bot: b},
+		"edit_message_reply_markup": &EditMessageReplyMarkupHandler{Bot: b},
+		"edit_message_media":        &EditMessageMediaHandler{Bot: b},
+	} {
+		// a message id without its chat is no target either
+		payload := &models.EditMessageText{EditMessage: models.EditMessage{MessageID: 7}, Text: "edited"}
+		err := h.Handle(context.Background(), newTestEvent(t, "1", eventType, payload))
+		if !errors.Is(err, ErrInvalidPayload) {
+			t.Errorf("%s without target: err = %v, want ErrInvalidPayload", eventType, err)
+		}
+	}
+
+	if calls := ft.Calls(); len(calls) != 0 {
+		t.Errorf("made %d calls, want none", len(calls))
+	}
+}
+
+func TestEditMessageCaptionHandlerReplacesTheKeyboard(t *testing.T) {
+	ft, b := telegramtest.New(t)
+	h := &EditMessageCaptionHandler{Bot: b}
+
+	callbackData := "ok"
+	payload := &models.EditMessageCaption{
+		EditMessage: models.EditMessage{
+			ChatID:    42,
+			MessageID: 7,
+			InlineKeyboardMarkup: &models.InlineKeyboardMarkup{
+				InlineKeyboard: [][]models.InlineKeyboardButton{{{Text: "OK", CallbackData: &callbackData}}},
+			},
+		},
+		Caption: "edited",
+	}
+	err := h.Handle(context.Background(), newTestEvent(t, "1", "edit_message_caption", payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := ft.Calls("editMessageCaption")
+	if len(calls) != 1 {
+		t.Fatalf("editMessageCaption called %d times, want 1", len(calls))
+	}
+	params := calls[0].Params
+	if params.Get("caption") != "edited" || !strings.Contains(params.Get("reply_markup"), `"callback_data":"ok"`) {
+		t.Errorf("editMessageCaption params = %v, want the caption and the keyboard", params)
+	}
+}
+
+func TestEditMessageMediaHandler(t *testing.T) {
+	ft, b := telegramtest.New(t)
+	h := &EditMessageMediaHandler{Bot: b}
+
+	payload := &models.EditMessageMedia{
+		EditMessage: models.EditMessage{ChatID: 42, MessageID: 7},
+		Media:       models.BaseInputMedia{Type: "photo", Media: "p1", Caption: "new"},
+	}
+	err := h.Handle(context.Background(), newTestEvent(t, "1", "edit_message_media", payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := ft.Calls("editMessageMedia")
+	if len(calls) != 1 {
+		t.Fatalf("editMessageMedia called %d times, want 1", len(calls))
+	}
+	if media := calls[0].Params.Get("media"); !strings.Contains(media, `"type":"photo"`) || !strings.Contains(media, `"media":"p1"`) {
+		t.Errorf("media = %s, want the photo p1", media)
+	}
+
+	payload.Media.Type = "sticker"
+	err = h.Handle(context.Background(), newTestEvent(t, "2", "edit_message_media", payload))
+	if !errors.Is(err, ErrInvalidPayload) {
+		t.Errorf("err = %v, want ErrInvalidPayload for an unsupported media type", err)
+	}
+}
+
+func TestDeleteMessageHandler(t *testing.T) {
+	ft, b := telegramtest.New(t)
+	h := &DeleteMessageHandler{Bot: b}
+
+	err := h.Handle(context.Background(), newTestEvent(t, "1", "delete_message", &models.DeleteMessage{ChatID: 42, MessageID: 7}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := ft.Calls("deleteMessage")
+	if len(calls) != 1 || calls[0].Params.Get("chat_id") != "42" || calls[0].Params.Get("message_id") != "7" {
+		t.Errorf("deleteMessage calls = %v, want one for message 7 of chat 42", calls)
+	}
+}
@@ -0,0 +1,21 @@
+package event
+
+import (
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+func newTestEvent(t *testing.T, id string, eventType string, data any) *cloudevents.Event {
+	t.Helper()
+
+	ev := cloudevents.NewEvent()
+	ev.SetID(id)
+	ev.SetType(eventType)
+	ev.SetSource("test")
+	err := ev.SetData(cloudevents.ApplicationJSON, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &ev
+}
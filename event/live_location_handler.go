@@ -0,0 +1,71 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/botaas/telegram-bot-connector/bot"
+	"github.com/botaas/telegram-bot-connector/models"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+type EditMessageLiveLocationHandler struct {
+	Bot *bot.Bot
+}
+
+func (h *EditMessageLiveLocationHandler) Handle(ctx context.Context, ev *cloudevents.Event) error {
+	_, err := h.Send(ctx, ev)
+	return err
+}
+
+func (h *EditMessageLiveLocationHandler) Send(ctx context.Context, ev *cloudevents.Event) ([]tgbotapi.Message, error) {
+	var payload models.EditMessageLiveLocation
+	err := json.Unmarshal(ev.Data(), &payload)
+	if err != nil {
+		return nil, err
+	}
+
+	edit, err := newBaseEdit(&payload.EditMessage)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := tgbotapi.EditMessageLiveLocationConfig{
+		BaseEdit:             edit,
+		Latitude:             payload.Latitude,
+		Longitude:            payload.Longitude,
+		HorizontalAccuracy:   payload.HorizontalAccuracy,
+		Heading:              payload.Heading,
+		ProximityAlertRadius: payload.ProximityAlertRadius,
+	}
+	return sendEdit(h.Bot.API(), msg, edit.InlineMessageID)
+}
+
+type StopMessageLiveLocationHandler struct {
+	Bot *bot.Bot
+}
+
+func (h *StopMessageLiveLocationHandler) Handle(ctx context.Context, ev *cloudevents.Event) error {
+	_, err := h.Send(ctx, ev)
+	return err
+}
+
+func (h *StopMessageLiveLocationHandler) Send(ctx context.Context, ev *cloudevents.Event) ([]tgbotapi.Message, error) {
+	var payload models.StopMessageLiveLocation
+	err := json.Unmarshal(ev.Data(), &payload)
+	if err != nil {
+		return nil, err
+	}
+
+	edit, err := newBaseEdit(&payload.EditMessage)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := tgbotapi.StopMessageLiveLocationConfig{
+		BaseEdit: edit,
+	}
+	return sendEdit(h.Bot.API(), msg, edit.InlineMessageID)
+}
@@ -0,0 +1,59 @@
+package event
+
+import (
+	"context"
+	"testing"
+
+	"github.com/botaas/telegram-bot-connector/internal/telegramtest"
+	"github.com/botaas/telegram-bot-connector/models"
+)
+
+func TestEditMessageLiveLocationHandlerMovesTheLocation(t *testing.T) {
+	ft, b := telegramtest.New(t)
+	h := &EditMessageLiveLocationHandler{Bot: b}
+
+	payload := &models.EditMessageLiveLocation{
+		EditMessage:          models.EditMessage{ChatID: 42, MessageID: 7},
+		Latitude:             48.8584,
+		Longitude:            2.2945,
+		Heading:              90,
+		ProximityAlertRadius: 100,
+	}
+	sent, err := h.Send(context.Background(), newTestEvent(t, "1", "edit_message_live_location", payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := ft.Calls("editMessageLiveLocation")
+	if len(calls) != 1 {
+		t.Fatalf("editMessageLiveLocation called %d times, want 1", len(calls))
+	}
+	params := calls[0].Params
+	if params.Get("chat_id") != "42" || params.Get("message_id") != "7" ||
+		params.Get("latitude") != "48.858400" || params.Get("longitude") != "2.294500" ||
+		params.Get("heading") != "90" || params.Get("proximity_alert_radius") != "100" {
+		t.Errorf("editMessageLiveLocation params = %v, want the new location of message 7", params)
+	}
+	if len(sent) != 1 || sent[0].MessageID != 7 {
+		t.Errorf("reported %v, want the edited message", sent)
+	}
+}
+
+func TestStopMessageLiveLocationHandlerStopsInlineMessages(t *testing.T) {
+	ft, b := telegramtest.New(t)
+	h := &StopMessageLiveLocationHandler{Bot: b}
+
+	payload := &models.StopMessageLiveLocation{EditMessage: models.EditMessage{InlineMessageID: "inline"}}
+	sent, err := h.Send(context.Background(), newTestEvent(t, "1", "stop_message_live_location", payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := ft.Calls("stopMessageLiveLocation")
+	if len(calls) != 1 || calls[0].Params.Get("inline_message_id") != "inline" {
+		t.Fatalf("stopMessageLiveLocation calls = %v, want one for the inline message", calls)
+	}
+	if len(sent) != 0 {
+		t.Errorf("reported %d messages, want none", len(sent))
+	}
+}
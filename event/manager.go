@@ -6,8 +6,32 @@ import (
 	"fmt"
 
 	cloudevents "github.com/cloudevents/sdk-go/v2"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
+var (
+	ErrNoEventHandler = errors.New("No EventHandler for type")
+	// ErrInvalidPayload is wrapped by handlers rejecting the payload of an event
+	ErrInvalidPayload = errors.New("invalid payload")
+)
+
+// PartialSendError is returned by handlers sending several messages for one
+// event when some were sent before Err, retrying the event would send them
+// again.
+type PartialSendError struct {
+	// Sent is the number of messages sent
+	Sent int
+	Err  error
+}
+
+func (e *PartialSendError) Error() string {
+	return fmt.Sprintf("%d messages sent before: %v", e.Sent, e.Err)
+}
+
+func (e *PartialSendError) Unwrap() error {
+	return e.Err
+}
+
 type EventContext struct {
 	context.Context
 }
@@ -16,6 +40,13 @@ type EventHandler interface {
 	Handle(ctx context.Context, ev *cloudevents.Event) error
 }
 
+// MessageSender is implemented by handlers whose events send or edit
+// messages, Process returns those messages so they can be reported back.
+type MessageSender interface {
+	EventHandler
+	Send(ctx context.Context, ev *cloudevents.Event) ([]tgbotapi.Message, error)
+}
+
 type EventManager struct {
 	handlers map[string]EventHandler
 }
@@ -30,11 +61,16 @@ func (em *EventManager) RegisterHandler(eventType string, handler EventHandler)
 	em.handlers[eventType] = handler
 }
 
-func (em *EventManager) Process(ctx context.Context, ev *cloudevents.Event) error {
+// Process handles ev and returns the messages it sent or edited, if any.
+func (em *EventManager) Process(ctx context.Context, ev *cloudevents.Event) ([]tgbotapi.Message, error) {
 	handler, exist := em.handlers[ev.Type()]
 	if !exist {
-		return errors.New(fmt.Sprintf("No EventHandler for type: %s\n", ev.Type()))
+		return nil, fmt.Errorf("%w: %s", ErrNoEventHandler, ev.Type())
+	}
+
+	if sender, ok := handler.(MessageSender); ok {
+		return sender.Send(ctx, ev)
 	}
 
-	return handler.Handle(ctx, ev)
+	return nil, handler.Handle(ctx, ev)
 }
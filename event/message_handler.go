@@ -3,10 +3,12 @@ package event
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 
@@ -28,6 +30,9 @@ func IsURL(str string) bool {
 
 type MessageHandler struct {
 	Bot *bot.Bot
+	// Format is the default format of texts and captions sent without parse
+	// mode or entities, see bot.TGGetParseMode.
+	Format string
 }
 
 func marshalInlineKeyboardMarkup(i *models.InlineKeyboardMarkup) (tgbotapi.InlineKeyboardMarkup, error) {
@@ -37,13 +42,230 @@ func marshalInlineKeyboardMarkup(i *models.InlineKeyboardMarkup) (tgbotapi.Inlin
 	return inlineKeyboardMarkup, err
 }
 
+// newInputMedia converts media to the matching tgbotapi.InputMedia type, nil
+// when the type is unknown.
+func newInputMedia(media *models.BaseInputMedia) any {
+	var requestFileData tgbotapi.RequestFileData
+	if IsURL(media.Media) {
+		requestFileData = tgbotapi.FileURL(media.Media)
+	} else {
+		requestFileData = tgbotapi.FileID(media.Media)
+	}
+
+	base := tgbotapi.BaseInputMedia{
+		Type:            media.Type,
+		Media:           requestFileData,
+		Caption:         media.Caption,
+		ParseMode:       media.ParseMode,
+		CaptionEntities: newMessageEntities(media.CaptionEntities),
+	}
+
+	switch media.Type {
+	case "photo":
+		return tgbotapi.InputMediaPhoto{BaseInputMedia: base}
+	case "audio":
+		return tgbotapi.InputMediaAudio{BaseInputMedia: base}
+	case "video":
+		return tgbotapi.InputMediaVideo{BaseInputMedia: base}
+	case "animation":
+		return tgbotapi.InputMediaAnimation{BaseInputMedia: base}
+	case "document":
+		return tgbotapi.InputMediaDocument{BaseInputMedia: base}
+	}
+
+	return nil
+}
+
+// formatText applies the default format to a text sent without parse mode
+// or entities.
+func formatText(format string, text string, parseMode string, entities []models.MessageEntity) (string, string) {
+	if len(parseMode) > 0 || len(entities) > 0 || len(text) == 0 {
+		return text, parseMode
+	}
+	return bot.TGGetParseMode(format, "", text)
+}
+
+// decodeInputMedia decodes a media group item by its JSON field names.
+func decodeInputMedia(f any) (models.BaseInputMedia, error) {
+	var media models.BaseInputMedia
+
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		TagName: "json",
+		Result:  &media,
+	})
+	if err != nil {
+		return media, err
+	}
+
+	err = decoder.Decode(f)
+	return media, err
+}
+
+// newMessageEntities converts entities to their tgbotapi counterparts.
+func newMessageEntities(entities []models.MessageEntity) []tgbotapi.MessageEntity {
+	if len(entities) == 0 {
+		return nil
+	}
+
+	o := make([]tgbotapi.MessageEntity, 0, len(entities))
+	for _, e := range entities {
+		entity := tgbotapi.MessageEntity{
+			Type:     e.Type,
+			Offset:   e.Offset,
+			Length:   e.Length,
+			URL:      e.URL,
+			Language: e.Language,
+		}
+		if e.User != nil {
+			entity.User = &tgbotapi.User{
+				ID:           e.User.ID,
+				IsBot:        e.User.IsBot,
+				FirstName:    e.User.FirstName,
+				LastName:     e.User.LastName,
+				UserName:     e.User.UserName,
+				LanguageCode: e.User.LanguageCode,
+			}
+		}
+		o = append(o, entity)
+	}
+	return o
+}
+
+// newRequestFile picks the source of an outbound file: a file already on the
+// Telegram servers, an URL Telegram downloads itself, or data uploaded with
+// the given name. It is nil when none is set.
+func newRequestFile(fileID string, url string, data []byte, name string) tgbotapi.RequestFileData {
+	if len(fileID) > 0 {
+		return tgbotapi.FileID(fileID)
+	} else if len(url) > 0 {
+		return tgbotapi.FileURL(url)
+	} else if len(data) > 0 {
+		return tgbotapi.FileBytes{Name: name, Bytes: data}
+	}
+	return nil
+}
+
+// downloadFile saves the file at url to a temporary file for the files
+// Telegram refuses to fetch by URL, the caller removes it.
+func downloadFile(ctx context.Context, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("download %s: %s", url, resp.Status)
+	}
+
+	f, err := os.CreateTemp("", "download-*")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, resp.Body)
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+
+	return f.Name(), nil
+}
+
+// sendPoll sends the poll of cmsg. tgbotapi.SendPollConfig always sends
+// is_anonymous and correct_option_id, here they are only sent when set.
+func sendPoll(api *tgbotapi.BotAPI, cmsg *models.Message) (tgbotapi.Message, error) {
+	var m tgbotapi.Message
+
+	poll := cmsg.Poll
+	if len(poll.Question) == 0 || len(poll.Options) < 2 {
+		return m, fmt.Errorf("%w: poll requires a question and at least 2 options", ErrInvalidPayload)
+	}
+
+	options := make([]string, 0, len(poll.Options))
+	for _, o := range poll.Options {
+		options = append(options, o.Text)
+	}
+
+	params := tgbotapi.Params{
+		"question": poll.Question,
+	}
+	params.AddNonZero64("chat_id", cmsg.Chat.ID)
+	err := params.AddInterface("options", options)
+	if err != nil {
+		return m, err
+	}
+	if poll.IsAnonymous != nil {
+		params["is_anonymous"] = strconv.FormatBool(*poll.IsAnonymous)
+	}
+	params.AddNonEmpty("type", poll.Type)
+	params.AddBool("allows_multiple_answers", poll.AllowsMultipleAnswers)
+	if poll.CorrectOptionID != nil {
+		params["correct_option_id"] = strconv.Itoa(*poll.CorrectOptionID)
+	}
+	params.AddNonEmpty("explanation", poll.Explanation)
+	err = params.AddInterface("explanation_entities", newMessageEntities(poll.ExplanationEntities))
+	if err != nil {
+		return m, err
+	}
+	params.AddNonZero("open_period", poll.OpenPeriod)
+	params.AddNonZero("close_date", poll.CloseDate)
+	params.AddBool("is_closed", poll.IsClosed)
+	params.AddNonZero("reply_to_message_id", cmsg.ReplyToMessageID)
+	params.AddBool("disable_notification", cmsg.DisableNotification)
+	params.AddBool("protect_content", cmsg.ProtectContent)
+	if cmsg.InlineKeyboardMarkup != nil {
+		markup, err := marshalInlineKeyboardMarkup(cmsg.InlineKeyboardMarkup)
+		if err != nil {
+			return m, err
+		}
+		err = params.AddInterface("reply_markup", markup)
+		if err != nil {
+			return m, err
+		}
+	}
+
+	resp, err := api.MakeRequest("sendPoll", params)
+	if err != nil {
+		return m, err
+	}
+
+	err = json.Unmarshal(resp.Result, &m)
+	return m, err
+}
+
+func fileName(name string, fallback string) string {
+	if len(name) > 0 {
+		return name
+	}
+	return fallback
+}
+
 func (h *MessageHandler) Handle(ctx context.Context, ev *cloudevents.Event) error {
+	_, err := h.Send(ctx, ev)
+	return err
+}
+
+func (h *MessageHandler) Send(ctx context.Context, ev *cloudevents.Event) ([]tgbotapi.Message, error) {
 	var cmsg models.Message
 	err := json.Unmarshal(ev.Data(), &cmsg)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	var sent []tgbotapi.Message
+
+	// replies land in the forum topic of the message
+	api := h.Bot.ThreadAPI(cmsg.MessageThreadID)
+
+	text, parseMode := formatText(h.Format, cmsg.Text, cmsg.ParseMode, cmsg.Entities)
+	caption, captionParseMode := formatText(h.Format, cmsg.Caption, cmsg.ParseMode, cmsg.CaptionEntities)
+
 	/*
 		username := ""
 		if cmsg.From != nil {
@@ -52,7 +274,9 @@ func (h *MessageHandler) Handle(ctx context.Context, ev *cloudevents.Event) erro
 	*/
 
 	if len(cmsg.Text) > 0 {
-		msg := tgbotapi.NewMessage(cmsg.Chat.ID, cmsg.Text)
+		msg := tgbotapi.NewMessage(cmsg.Chat.ID, text)
+		msg.ParseMode = parseMode
+		msg.Entities = newMessageEntities(cmsg.Entities)
 		if cmsg.InlineKeyboardMarkup != nil {
 			markup, err := marshalInlineKeyboardMarkup(cmsg.InlineKeyboardMarkup)
 			if err == nil {
@@ -63,10 +287,13 @@ func (h *MessageHandler) Handle(ctx context.Context, ev *cloudevents.Event) erro
 		msg.ReplyToMessageID = cmsg.ReplyToMessageID
 		msg.DisableNotification = cmsg.DisableNotification
 		msg.ProtectContent = cmsg.ProtectContent
-		_, err = h.Bot.API().Send(msg)
-		return err
+		m, err := api.Send(msg)
+		if err != nil {
+			return nil, err
+		}
+		sent = append(sent, m)
 	} else if cmsg.Photo != nil {
-		for _, p := range cmsg.Photo {
+		for i, p := range cmsg.Photo {
 			var file tgbotapi.RequestFileData
 			if len(p.FileID) > 0 {
 				file = tgbotapi.FileID(p.FileID)
@@ -75,12 +302,24 @@ func (h *MessageHandler) Handle(ctx context.Context, ev *cloudevents.Event) erro
 			}
 
 			msg := tgbotapi.NewPhoto(cmsg.Chat.ID, file)
+			// the caption goes with the first photo only
+			if i == 0 {
+				msg.Caption = caption
+				msg.ParseMode = captionParseMode
+				msg.CaptionEntities = newMessageEntities(cmsg.CaptionEntities)
+			}
 
 			msg.ReplyToMessageID = cmsg.ReplyToMessageID
 			msg.DisableNotification = cmsg.DisableNotification
 			msg.ProtectContent = cmsg.ProtectContent
 
-			_, err = h.Bot.API().Send(msg)
+			m, err := api.Send(msg)
+			if err != nil && len(sent) > 0 {
+				return sent, &PartialSendError{Sent: len(sent), Err: err}
+			} else if err != nil {
+				return nil, err
+			}
+			sent = append(sent, m)
 		}
 	} else if cmsg.Audio != nil {
 		var file tgbotapi.RequestFileData
@@ -91,40 +330,46 @@ func (h *MessageHandler) Handle(ctx context.Context, ev *cloudevents.Event) erro
 		}
 
 		msg := tgbotapi.NewAudio(cmsg.Chat.ID, file)
+		msg.Caption = caption
+		msg.ParseMode = captionParseMode
+		msg.CaptionEntities = newMessageEntities(cmsg.CaptionEntities)
 		msg.ReplyToMessageID = cmsg.ReplyToMessageID
 		msg.DisableNotification = cmsg.DisableNotification
 		msg.ProtectContent = cmsg.ProtectContent
 
-		_, err = h.Bot.API().Send(msg)
+		m, err := api.Send(msg)
+		if err != nil {
+			return nil, err
+		}
+		sent = append(sent, m)
 	} else if cmsg.Voice != nil {
 		var file tgbotapi.RequestFileData
 		if len(cmsg.Voice.FileID) > 0 {
 			file = tgbotapi.FileID(cmsg.Voice.FileID)
 		} else if len(cmsg.Voice.Url) > 0 {
-			resp, err := http.Get(cmsg.Voice.Url)
-			if err != nil {
-				return err
-			}
-			f, err := os.CreateTemp("", "download-*")
-			if err != nil {
-				return err
-			}
-			defer os.Remove(f.Name())
-			_, err = io.Copy(f, resp.Body)
+			path, err := downloadFile(ctx, cmsg.Voice.Url)
 			if err != nil {
-				return err
+				return nil, err
 			}
+			defer os.Remove(path)
 
-			file = tgbotapi.FilePath(f.Name())
+			file = tgbotapi.FilePath(path)
 		}
 
 		msg := tgbotapi.NewVoice(cmsg.Chat.ID, file)
+		msg.Caption = caption
+		msg.ParseMode = captionParseMode
+		msg.CaptionEntities = newMessageEntities(cmsg.CaptionEntities)
 		msg.ReplyToMessageID = cmsg.ReplyToMessageID
 		msg.DisableNotification = cmsg.DisableNotification
 		msg.ProtectContent = cmsg.ProtectContent
 		msg.Duration = cmsg.Voice.Duration
 
-		_, err = h.Bot.API().Send(msg)
+		m, err := api.Send(msg)
+		if err != nil {
+			return nil, err
+		}
+		sent = append(sent, m)
 	} else if cmsg.Video != nil {
 		var file tgbotapi.RequestFileData
 		if len(cmsg.Video.FileID) > 0 {
@@ -134,10 +379,154 @@ func (h *MessageHandler) Handle(ctx context.Context, ev *cloudevents.Event) erro
 		}
 
 		msg := tgbotapi.NewVideo(cmsg.Chat.ID, file)
+		msg.Caption = caption
+		msg.ParseMode = captionParseMode
+		msg.CaptionEntities = newMessageEntities(cmsg.CaptionEntities)
+		msg.ReplyToMessageID = cmsg.ReplyToMessageID
+		msg.DisableNotification = cmsg.DisableNotification
+		msg.ProtectContent = cmsg.ProtectContent
+		m, err := api.Send(msg)
+		if err != nil {
+			return nil, err
+		}
+		sent = append(sent, m)
+	} else if cmsg.Animation != nil {
+		file := newRequestFile(cmsg.Animation.FileID, cmsg.Animation.Url, cmsg.Animation.Data, fileName(cmsg.Animation.FileName, "animation.mp4"))
+		if file == nil {
+			return nil, fmt.Errorf("%w: animation without file_id, url or data", ErrInvalidPayload)
+		}
+
+		msg := tgbotapi.NewAnimation(cmsg.Chat.ID, file)
+		msg.Caption = caption
+		msg.ParseMode = captionParseMode
+		msg.CaptionEntities = newMessageEntities(cmsg.CaptionEntities)
+		msg.Duration = cmsg.Animation.Duration
 		msg.ReplyToMessageID = cmsg.ReplyToMessageID
 		msg.DisableNotification = cmsg.DisableNotification
 		msg.ProtectContent = cmsg.ProtectContent
-		_, err = h.Bot.API().Send(msg)
+		m, err := api.Send(msg)
+		if err != nil {
+			return nil, err
+		}
+		sent = append(sent, m)
+	} else if cmsg.Document != nil {
+		file := newRequestFile(cmsg.Document.FileID, cmsg.Document.Url, cmsg.Document.Data, fileName(cmsg.Document.FileName, "document"))
+		if file == nil {
+			return nil, fmt.Errorf("%w: document without file_id, url or data", ErrInvalidPayload)
+		}
+
+		msg := tgbotapi.NewDocument(cmsg.Chat.ID, file)
+		msg.Caption = caption
+		msg.ParseMode = captionParseMode
+		msg.CaptionEntities = newMessageEntities(cmsg.CaptionEntities)
+		msg.ReplyToMessageID = cmsg.ReplyToMessageID
+		msg.DisableNotification = cmsg.DisableNotification
+		msg.ProtectContent = cmsg.ProtectContent
+		m, err := api.Send(msg)
+		if err != nil {
+			return nil, err
+		}
+		sent = append(sent, m)
+	} else if cmsg.Sticker != nil {
+		file := newRequestFile(cmsg.Sticker.FileID, cmsg.Sticker.Url, cmsg.Sticker.Data, "sticker.webp")
+		if file == nil {
+			return nil, fmt.Errorf("%w: sticker without file_id, url or data", ErrInvalidPayload)
+		}
+
+		msg := tgbotapi.NewSticker(cmsg.Chat.ID, file)
+		msg.ReplyToMessageID = cmsg.ReplyToMessageID
+		msg.DisableNotification = cmsg.DisableNotification
+		msg.ProtectContent = cmsg.ProtectContent
+		m, err := api.Send(msg)
+		if err != nil {
+			return nil, err
+		}
+		sent = append(sent, m)
+	} else if cmsg.VideoNote != nil {
+		var file tgbotapi.RequestFileData
+		if len(cmsg.VideoNote.FileID) > 0 {
+			file = tgbotapi.FileID(cmsg.VideoNote.FileID)
+		} else if len(cmsg.VideoNote.Url) > 0 {
+			// sending video notes by URL is not supported by Telegram
+			path, err := downloadFile(ctx, cmsg.VideoNote.Url)
+			if err != nil {
+				return nil, err
+			}
+			defer os.Remove(path)
+
+			file = tgbotapi.FilePath(path)
+		} else if len(cmsg.VideoNote.Data) > 0 {
+			file = tgbotapi.FileBytes{Name: "video_note.mp4", Bytes: cmsg.VideoNote.Data}
+		} else {
+			return nil, fmt.Errorf("%w: video note without file_id, url or data", ErrInvalidPayload)
+		}
+
+		msg := tgbotapi.NewVideoNote(cmsg.Chat.ID, cmsg.VideoNote.Length, file)
+		msg.Duration = cmsg.VideoNote.Duration
+		msg.ReplyToMessageID = cmsg.ReplyToMessageID
+		msg.DisableNotification = cmsg.DisableNotification
+		msg.ProtectContent = cmsg.ProtectContent
+		m, err := api.Send(msg)
+		if err != nil {
+			return nil, err
+		}
+		sent = append(sent, m)
+	} else if cmsg.Venue != nil {
+		msg := tgbotapi.NewVenue(cmsg.Chat.ID, cmsg.Venue.Title, cmsg.Venue.Address, cmsg.Venue.Location.Latitude, cmsg.Venue.Location.Longitude)
+		msg.FoursquareID = cmsg.Venue.FoursquareID
+		msg.FoursquareType = cmsg.Venue.FoursquareType
+		msg.GooglePlaceID = cmsg.Venue.GooglePlaceID
+		msg.GooglePlaceType = cmsg.Venue.GooglePlaceType
+		msg.ReplyToMessageID = cmsg.ReplyToMessageID
+		msg.DisableNotification = cmsg.DisableNotification
+		msg.ProtectContent = cmsg.ProtectContent
+		m, err := api.Send(msg)
+		if err != nil {
+			return nil, err
+		}
+		sent = append(sent, m)
+	} else if cmsg.Location != nil {
+		msg := tgbotapi.NewLocation(cmsg.Chat.ID, cmsg.Location.Latitude, cmsg.Location.Longitude)
+		msg.HorizontalAccuracy = cmsg.Location.HorizontalAccuracy
+		msg.LivePeriod = cmsg.Location.LivePeriod
+		msg.Heading = cmsg.Location.Heading
+		msg.ProximityAlertRadius = cmsg.Location.ProximityAlertRadius
+		msg.ReplyToMessageID = cmsg.ReplyToMessageID
+		msg.DisableNotification = cmsg.DisableNotification
+		msg.ProtectContent = cmsg.ProtectContent
+		m, err := api.Send(msg)
+		if err != nil {
+			return nil, err
+		}
+		sent = append(sent, m)
+	} else if cmsg.Contact != nil {
+		msg := tgbotapi.NewContact(cmsg.Chat.ID, cmsg.Contact.PhoneNumber, cmsg.Contact.FirstName)
+		msg.LastName = cmsg.Contact.LastName
+		msg.VCard = cmsg.Contact.VCard
+		msg.ReplyToMessageID = cmsg.ReplyToMessageID
+		msg.DisableNotification = cmsg.DisableNotification
+		msg.ProtectContent = cmsg.ProtectContent
+		m, err := api.Send(msg)
+		if err != nil {
+			return nil, err
+		}
+		sent = append(sent, m)
+	} else if cmsg.Dice != nil {
+		msg := tgbotapi.NewDiceWithEmoji(cmsg.Chat.ID, cmsg.Dice.Emoji)
+		msg.ReplyToMessageID = cmsg.ReplyToMessageID
+		msg.DisableNotification = cmsg.DisableNotification
+		msg.ProtectContent = cmsg.ProtectContent
+		m, err := api.Send(msg)
+		if err != nil {
+			return nil, err
+		}
+		sent = append(sent, m)
+	} else if cmsg.Poll != nil {
+		m, err := sendPoll(api, &cmsg)
+		if err != nil {
+			return nil, err
+		}
+		sent = append(sent, m)
 	} else if cmsg.Invoice != nil {
 		var prices []tgbotapi.LabeledPrice
 		for _, p := range cmsg.Invoice.Prices {
@@ -150,76 +539,53 @@ func (h *MessageHandler) Handle(ctx context.Context, ev *cloudevents.Event) erro
 		}
 
 		msg := tgbotapi.InvoiceConfig{
-			BaseChat:            tgbotapi.BaseChat{ChatID: cmsg.Chat.ID},
-			Title:               cmsg.Invoice.Title,
-			Description:         cmsg.Invoice.Description,
-			Payload:             cmsg.Invoice.Payload,
-			ProviderToken:       cmsg.Invoice.ProviderToken,
-			StartParameter:      cmsg.Invoice.StartParameter,
-			Currency:            cmsg.Invoice.Currency,
-			Prices:              prices,
-			MaxTipAmount:        cmsg.Invoice.MaxTipAmount,
-			SuggestedTipAmounts: cmsg.Invoice.SuggestedTipAmounts,
-			PhotoURL:            cmsg.Invoice.PhotoURL,
-			PhotoSize:           cmsg.Invoice.PhotoSize,
-			PhotoWidth:          cmsg.Invoice.PhotoWidth,
-			PhotoHeight:         cmsg.Invoice.PhotoHeight,
+			BaseChat:                  tgbotapi.BaseChat{ChatID: cmsg.Chat.ID},
+			Title:                     cmsg.Invoice.Title,
+			Description:               cmsg.Invoice.Description,
+			Payload:                   cmsg.Invoice.Payload,
+			ProviderToken:             cmsg.Invoice.ProviderToken,
+			StartParameter:            cmsg.Invoice.StartParameter,
+			Currency:                  cmsg.Invoice.Currency,
+			Prices:                    prices,
+			MaxTipAmount:              cmsg.Invoice.MaxTipAmount,
+			SuggestedTipAmounts:       cmsg.Invoice.SuggestedTipAmounts,
+			ProviderData:              cmsg.Invoice.ProviderData,
+			PhotoURL:                  cmsg.Invoice.PhotoURL,
+			PhotoSize:                 cmsg.Invoice.PhotoSize,
+			PhotoWidth:                cmsg.Invoice.PhotoWidth,
+			PhotoHeight:               cmsg.Invoice.PhotoHeight,
+			NeedName:                  cmsg.Invoice.NeedName,
+			NeedPhoneNumber:           cmsg.Invoice.NeedPhoneNumber,
+			NeedEmail:                 cmsg.Invoice.NeedEmail,
+			NeedShippingAddress:       cmsg.Invoice.NeedShippingAddress,
+			SendPhoneNumberToProvider: cmsg.Invoice.SendPhoneNumberToProvider,
+			SendEmailToProvider:       cmsg.Invoice.SendEmailToProvider,
+			IsFlexible:                cmsg.Invoice.IsFlexible,
 		}
 		msg.ReplyToMessageID = cmsg.ReplyToMessageID
+		msg.DisableNotification = cmsg.DisableNotification
 		msg.ProtectContent = cmsg.ProtectContent
-		_, err = h.Bot.API().Send(msg)
+		m, err := api.Send(msg)
+		if err != nil {
+			return nil, err
+		}
+		sent = append(sent, m)
 	} else if cmsg.MediaGroup != nil {
 		files := []any{}
 
 		for _, f := range cmsg.MediaGroup.Files {
-			var media models.BaseInputMedia
-			err := mapstructure.Decode(f, &media)
+			media, err := decodeInputMedia(f)
 			if err != nil {
 				continue
 			}
+			media.Caption, media.ParseMode = formatText(h.Format, media.Caption, media.ParseMode, media.CaptionEntities)
 
-			var requestFileData tgbotapi.RequestFileData
-			if IsURL(media.Media) {
-				requestFileData = tgbotapi.FileURL(media.Media)
-			} else {
-				requestFileData = tgbotapi.FileID(media.Media)
+			file := newInputMedia(&media)
+			if file == nil {
+				continue
 			}
 
-			switch media.Type {
-			case "photo":
-				photo := tgbotapi.NewInputMediaPhoto(
-					requestFileData,
-				)
-				files = append(files, photo)
-			case "audio":
-				audio := tgbotapi.NewInputMediaAudio(
-					requestFileData,
-				)
-				files = append(files, audio)
-			case "video":
-				video := tgbotapi.NewInputMediaVideo(
-					requestFileData,
-				)
-
-				files = append(files, video)
-			case "animation":
-				animation := tgbotapi.NewInputMediaAnimation(
-					requestFileData,
-				)
-				files = append(files, animation)
-			case "document":
-				document := tgbotapi.NewInputMediaDocument(
-					requestFileData,
-				)
-
-				/*
-					document.Caption, document.ParseMode = bot.TGGetParseMode(h.Bot.API(), username, "")
-					if len(document.Caption) == 0 && len(document.ParseMode) == 0 {
-					}
-				*/
-
-				files = append(files, document)
-			}
+			files = append(files, file)
 		}
 
 		mediaGroup := tgbotapi.NewMediaGroup(
@@ -227,8 +593,11 @@ func (h *MessageHandler) Handle(ctx context.Context, ev *cloudevents.Event) erro
 			files,
 		)
 
-		_, err = h.Bot.API().SendMediaGroup(mediaGroup)
+		sent, err = api.SendMediaGroup(mediaGroup)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	return err
+	return sent, nil
 }
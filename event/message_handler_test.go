@@ -0,0 +1,309 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/botaas/telegram-bot-connector/bot"
+	"github.com/botaas/telegram-bot-connector/internal/telegramtest"
+	"github.com/botaas/telegram-bot-connector/models"
+)
+
+func TestMessageHandlerReportsPartiallySentPhotos(t *testing.T) {
+	ft, b := telegramtest.New(t)
+	h := &MessageHandler{Bot: b}
+
+	calls := 0
+	ft.Fail(func(call telegramtest.Call) *telegramtest.Failure {
+		if call.Method != "sendPhoto" {
+			return nil
+		}
+		calls++
+		if calls == 2 {
+			return &telegramtest.Failure{Code: 429, Description: "Too Many Requests", RetryAfter: 1}
+		}
+		return nil
+	})
+
+	msg := &models.Message{
+		Chat: &models.Chat{ID: 42},
+		Photo: []*models.Photo{
+			{FileID: "p1"},
+			{FileID: "p2"},
+			{FileID: "p3"},
+		},
+	}
+	sent, err := h.Send(context.Background(), newTestEvent(t, "1", "message", msg))
+
+	var partialErr *PartialSendError
+	if !errors.As(err, &partialErr) {
+		t.Fatalf("err = %v, want a PartialSendError", err)
+	}
+	if partialErr.Sent != 1 || len(sent) != 1 {
+		t.Errorf("sent %d messages, reported %d, want 1", len(sent), partialErr.Sent)
+	}
+}
+
+func TestMessageHandlerFirstPhotoFailureIsNotPartial(t *testing.T) {
+	ft, b := telegramtest.New(t)
+	h := &MessageHandler{Bot: b}
+
+	ft.Fail(telegramtest.FailOnce("sendPhoto", &telegramtest.Failure{Code: 500, Description: "Internal Server Error"}))
+
+	msg := &models.Message{
+		Chat:  &models.Chat{ID: 42},
+		Photo: []*models.Photo{{FileID: "p1"}, {FileID: "p2"}},
+	}
+	sent, err := h.Send(context.Background(), newTestEvent(t, "1", "message", msg))
+
+	var partialErr *PartialSendError
+	if err == nil || errors.As(err, &partialErr) {
+		t.Fatalf("err = %v, want a plain error", err)
+	}
+	if len(sent) != 0 {
+		t.Errorf("sent %d messages, want 0", len(sent))
+	}
+}
+
+func TestMessageHandlerSendsEveryInvoiceField(t *testing.T) {
+	ft, b := telegramtest.New(t)
+	h := &MessageHandler{Bot: b}
+
+	msg := &models.Message{
+		Chat:                &models.Chat{ID: 42},
+		DisableNotification: true,
+		Invoice: &models.Invoice{
+			Title:                     "T-shirt",
+			Description:               "A blue T-shirt",
+			Payload:                   "order-1",
+			ProviderToken:             "token",
+			Currency:                  "USD",
+			Prices:                    []models.LabeledPrice{{Label: "T-shirt", Amount: 1000}},
+			ProviderData:              `{"receipt": true}`,
+			NeedName:                  true,
+			NeedPhoneNumber:           true,
+			NeedEmail:                 true,
+			NeedShippingAddress:       true,
+			SendPhoneNumberToProvider: true,
+			SendEmailToProvider:       true,
+			IsFlexible:                true,
+		},
+	}
+	_, err := h.Send(context.Background(), newTestEvent(t, "1", "message", msg))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := ft.Calls("sendInvoice")
+	if len(calls) != 1 {
+		t.Fatalf("sendInvoice called %d times, want 1", len(calls))
+	}
+	params := calls[0].Params
+	for _, name := range []string{
+		"need_name",
+		"need_phone_number",
+		"need_email",
+		"need_shipping_address",
+		"send_phone_number_to_provider",
+		"send_email_to_provider",
+		"is_flexible",
+		"disable_notification",
+	} {
+		if params.Get(name) != "true" {
+			t.Errorf("%s = %q, want true", name, params.Get(name))
+		}
+	}
+	if params.Get("provider_data") != `{"receipt": true}` {
+		t.Errorf("provider_data = %q, want the invoice's", params.Get("provider_data"))
+	}
+}
+
+func TestMessageHandlerEscapesTextsInTheDefaultFormat(t *testing.T) {
+	ft, b := telegramtest.New(t)
+	h := &MessageHandler{Bot: b, Format: bot.MarkdownV2}
+
+	for _, msg := range []*models.Message{
+		{Chat: &models.Chat{ID: 42}, Text: "Total: 1.5!"},
+		{Chat: &models.Chat{ID: 42}, Text: "*Total*: 1\\.5\\!", ParseMode: bot.MarkdownV2},
+	} {
+		_, err := h.Send(context.Background(), newTestEvent(t, "1", "message", msg))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	calls := ft.Calls("sendMessage")
+	if len(calls) != 2 {
+		t.Fatalf("sendMessage called %d times, want 2", len(calls))
+	}
+	if got := calls[0].Params; got.Get("text") != "Total: 1\\.5\\!" || got.Get("parse_mode") != "MarkdownV2" {
+		t.Errorf("plain text sent as %q in %q, want it escaped", got.Get("text"), got.Get("parse_mode"))
+	}
+	// markup with its own parse mode is sent as is
+	if got := calls[1].Params; got.Get("text") != "*Total*: 1\\.5\\!" || got.Get("parse_mode") != "MarkdownV2" {
+		t.Errorf("markup sent as %q in %q, want it untouched", got.Get("text"), got.Get("parse_mode"))
+	}
+}
+
+func TestMessageHandlerSendsFiles(t *testing.T) {
+	ft, b := telegramtest.New(t)
+	h := &MessageHandler{Bot: b}
+
+	entities := []models.MessageEntity{{Type: "bold", Offset: 0, Length: 3}}
+	for _, msg := range []*models.Message{
+		{Chat: &models.Chat{ID: 42}, Animation: &models.Animation{Url: "https://example.com/cat.mp4", Duration: 3}, Caption: "cat", CaptionEntities: entities},
+		{Chat: &models.Chat{ID: 42}, Document: &models.Document{Data: []byte("%PDF"), FileName: "report.pdf"}, Caption: "report"},
+		{Chat: &models.Chat{ID: 42}, Sticker: &models.Sticker{FileID: "sticker"}},
+		{Chat: &models.Chat{ID: 42}, VideoNote: &models.VideoNote{Data: []byte("mp4"), Length: 240}},
+	} {
+		_, err := h.Send(context.Background(), newTestEvent(t, "1", "message", msg))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	calls := ft.Calls()
+	if len(calls) != 4 {
+		t.Fatalf("made %d calls, want one per message", len(calls))
+	}
+
+	animation := calls[0]
+	if animation.Method != "sendAnimation" || animation.Params.Get("animation") != "https://example.com/cat.mp4" ||
+		animation.Params.Get("duration") != "3" || animation.Params.Get("caption") != "cat" {
+		t.Errorf("%s params = %v, want the animation by url with its caption", animation.Method, animation.Params)
+	}
+	var sentEntities []models.MessageEntity
+	err := json.Unmarshal([]byte(animation.Params.Get("caption_entities")), &sentEntities)
+	if err != nil || len(sentEntities) != 1 || sentEntities[0].Type != "bold" || sentEntities[0].Length != 3 {
+		t.Errorf("caption_entities = %s, want the bold entity", animation.Params.Get("caption_entities"))
+	}
+
+	document := calls[1]
+	if f := document.Files["document"]; document.Method != "sendDocument" || f.Name != "report.pdf" || string(f.Data) != "%PDF" {
+		t.Errorf("%s uploaded %+v, want report.pdf", document.Method, document.Files)
+	}
+	if document.Params.Get("caption") != "report" {
+		t.Errorf("document caption = %q, want report", document.Params.Get("caption"))
+	}
+
+	sticker := calls[2]
+	if sticker.Method != "sendSticker" || sticker.Params.Get("sticker") != "sticker" {
+		t.Errorf("%s params = %v, want the sticker by file id", sticker.Method, sticker.Params)
+	}
+
+	videoNote := calls[3]
+	if f := videoNote.Files["video_note"]; videoNote.Method != "sendVideoNote" || string(f.Data) != "mp4" || videoNote.Params.Get("length") != "240" {
+		t.Errorf("%s uploaded %+v with params %v, want the video note and its length", videoNote.Method, videoNote.Files, videoNote.Params)
+	}
+}
+
+func TestMessageHandlerRejectsFilesWithoutSource(t *testing.T) {
+	ft, b := telegramtest.New(t)
+	h := &MessageHandler{Bot: b}
+
+	for _, msg := range []*models.Message{
+		{Chat: &models.Chat{ID: 42}, Animation: &models.Animation{}},
+		{Chat: &models.Chat{ID: 42}, Document: &models.Document{FileName: "report.pdf"}},
+		{Chat: &models.Chat{ID: 42}, Sticker: &models.Sticker{}},
+		{Chat: &models.Chat{ID: 42}, VideoNote: &models.VideoNote{Length: 240}},
+	} {
+		_, err := h.Send(context.Background(), newTestEvent(t, "1", "message", msg))
+		if !errors.Is(err, ErrInvalidPayload) {
+			t.Errorf("sending %+v failed with %v, want ErrInvalidPayload", msg, err)
+		}
+	}
+	if calls := ft.Calls(); len(calls) != 0 {
+		t.Errorf("made %d calls, want none for files without source", len(calls))
+	}
+}
+
+func TestMessageHandlerSendsLocationsVenuesContactsAndDices(t *testing.T) {
+	ft, b := telegramtest.New(t)
+	h := &MessageHandler{Bot: b}
+
+	location := models.Location{Latitude: 48.8584, Longitude: 2.2945}
+	live := location
+	live.LivePeriod = 3600
+	for _, msg := range []*models.Message{
+		{Chat: &models.Chat{ID: 42}, Location: &live},
+		{Chat: &models.Chat{ID: 42}, Venue: &models.Venue{Location: location, Title: "Eiffel Tower", Address: "Champ de Mars", GooglePlaceID: "place"}},
+		{Chat: &models.Chat{ID: 42}, Contact: &models.Contact{PhoneNumber: "+33123456789", FirstName: "Gustave", LastName: "Eiffel"}},
+		{Chat: &models.Chat{ID: 42}, Dice: &models.Dice{Emoji: "🎯"}},
+	} {
+		_, err := h.Send(context.Background(), newTestEvent(t, "1", "message", msg))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	calls := ft.Calls()
+	if len(calls) != 4 {
+		t.Fatalf("made %d calls, want one per message", len(calls))
+	}
+	if c := calls[0]; c.Method != "sendLocation" || c.Params.Get("latitude") != "48.858400" || c.Params.Get("longitude") != "2.294500" || c.Params.Get("live_period") != "3600" {
+		t.Errorf("%s params = %v, want the live location", c.Method, c.Params)
+	}
+	if c := calls[1]; c.Method != "sendVenue" || c.Params.Get("title") != "Eiffel Tower" || c.Params.Get("address") != "Champ de Mars" ||
+		c.Params.Get("latitude") != "48.858400" || c.Params.Get("google_place_id") != "place" {
+		t.Errorf("%s params = %v, want the venue", c.Method, c.Params)
+	}
+	if c := calls[2]; c.Method != "sendContact" || c.Params.Get("phone_number") != "+33123456789" ||
+		c.Params.Get("first_name") != "Gustave" || c.Params.Get("last_name") != "Eiffel" {
+		t.Errorf("%s params = %v, want the contact", c.Method, c.Params)
+	}
+	if c := calls[3]; c.Method != "sendDice" || c.Params.Get("emoji") != "🎯" {
+		t.Errorf("%s params = %v, want the darts", c.Method, c.Params)
+	}
+}
+
+func TestMessageHandlerSendsPolls(t *testing.T) {
+	ft, b := telegramtest.New(t)
+	h := &MessageHandler{Bot: b}
+
+	isAnonymous := false
+	correctOptionID := 0
+	options := []models.PollOption{{Text: "Paris"}, {Text: "Lyon"}}
+	for _, poll := range []*models.Poll{
+		{Question: "Where?", Options: options},
+		{Question: "Capital?", Options: options, Type: "quiz", IsAnonymous: &isAnonymous, CorrectOptionID: &correctOptionID},
+	} {
+		_, err := h.Send(context.Background(), newTestEvent(t, "1", "message", &models.Message{Chat: &models.Chat{ID: 42}, Poll: poll}))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	calls := ft.Calls("sendPoll")
+	if len(calls) != 2 {
+		t.Fatalf("sendPoll called %d times, want 2", len(calls))
+	}
+
+	var sentOptions []string
+	err := json.Unmarshal([]byte(calls[0].Params.Get("options")), &sentOptions)
+	if err != nil || len(sentOptions) != 2 || sentOptions[0] != "Paris" || sentOptions[1] != "Lyon" {
+		t.Errorf("options = %s, want the texts of the options", calls[0].Params.Get("options"))
+	}
+	// unset, Telegram defaults to anonymous polls without correct option
+	if regular := calls[0].Params; regular.Get("question") != "Where?" || regular.Has("is_anonymous") || regular.Has("correct_option_id") {
+		t.Errorf("sendPoll params = %v, want the regular poll with the defaults of Telegram", regular)
+	}
+	if quiz := calls[1].Params; quiz.Get("type") != "quiz" || quiz.Get("is_anonymous") != "false" || quiz.Get("correct_option_id") != "0" {
+		t.Errorf("sendPoll params = %v, want the named quiz answered by the first option", quiz)
+	}
+}
+
+func TestMessageHandlerRejectsPollsWithoutOptions(t *testing.T) {
+	ft, b := telegramtest.New(t)
+	h := &MessageHandler{Bot: b}
+
+	msg := &models.Message{Chat: &models.Chat{ID: 42}, Poll: &models.Poll{Question: "Where?", Options: []models.PollOption{{Text: "Paris"}}}}
+	_, err := h.Send(context.Background(), newTestEvent(t, "1", "message", msg))
+	if !errors.Is(err, ErrInvalidPayload) {
+		t.Errorf("err = %v, want ErrInvalidPayload", err)
+	}
+	if calls := ft.Calls(); len(calls) != 0 {
+		t.Errorf("made %d calls, want none", len(calls))
+	}
+}
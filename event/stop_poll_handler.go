@@ -0,0 +1,43 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/botaas/telegram-bot-connector/bot"
+	"github.com/botaas/telegram-bot-connector/models"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+// StopPollHandler closes a poll, Telegram returns the final poll rather than
+// the message so no message is reported as sent.
+type StopPollHandler struct {
+	Bot *bot.Bot
+}
+
+func (h *StopPollHandler) Handle(ctx context.Context, ev *cloudevents.Event) error {
+	var payload models.StopPoll
+	err := json.Unmarshal(ev.Data(), &payload)
+	if err != nil {
+		return err
+	}
+
+	if len(payload.InlineMessageID) > 0 {
+		return fmt.Errorf("%w: polls can't be inline messages", ErrInvalidPayload)
+	}
+
+	edit, err := newBaseEdit(&payload.EditMessage)
+	if err != nil {
+		return err
+	}
+
+	msg := tgbotapi.StopPollConfig{
+		BaseEdit: edit,
+	}
+	_, err = h.Bot.API().Request(msg)
+
+	return err
+}
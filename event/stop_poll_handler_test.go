@@ -0,0 +1,40 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/botaas/telegram-bot-connector/internal/telegramtest"
+	"github.com/botaas/telegram-bot-connector/models"
+)
+
+func TestStopPollHandler(t *testing.T) {
+	ft, b := telegramtest.New(t)
+	h := &StopPollHandler{Bot: b}
+
+	payload := &models.StopPoll{EditMessage: models.EditMessage{ChatID: 42, MessageID: 7}}
+	err := h.Handle(context.Background(), newTestEvent(t, "1", "stop_poll", payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := ft.Calls("stopPoll")
+	if len(calls) != 1 || calls[0].Params.Get("chat_id") != "42" || calls[0].Params.Get("message_id") != "7" {
+		t.Errorf("stopPoll calls = %v, want one for message 7 of chat 42", calls)
+	}
+}
+
+func TestStopPollHandlerRejectsInlineMessages(t *testing.T) {
+	ft, b := telegramtest.New(t)
+	h := &StopPollHandler{Bot: b}
+
+	payload := &models.StopPoll{EditMessage: models.EditMessage{InlineMessageID: "inline"}}
+	err := h.Handle(context.Background(), newTestEvent(t, "1", "stop_poll", payload))
+	if !errors.Is(err, ErrInvalidPayload) {
+		t.Errorf("err = %v, want ErrInvalidPayload", err)
+	}
+	if calls := ft.Calls(); len(calls) != 0 {
+		t.Errorf("made %d calls, want none", len(calls))
+	}
+}
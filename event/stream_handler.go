@@ -0,0 +1,291 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/botaas/telegram-bot-connector/bot"
+	"github.com/botaas/telegram-bot-connector/models"
+	"github.com/botaas/telegram-bot-connector/scheduler"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+const (
+	maxMessageLength = 4096
+	// streams without chunk for streamTTL are dropped
+	streamTTL = 10 * time.Minute
+)
+
+type messageStream struct {
+	mu sync.Mutex
+
+	chatID           int64
+	replyToMessageID int
+	messageThreadID  int
+	// messageID of the message currently edited, 0 until it is sent
+	messageID int
+	// text of the current message received so far, sentText the part of it
+	// Telegram has
+	text     string
+	sentText string
+	lastEdit time.Time
+	timer    *time.Timer
+	closed   bool
+	// applied holds the ids of the chunk events appended to text, so that a
+	// retried chunk is not appended twice
+	applied map[string]struct{}
+
+	// lastChunk is guarded by the StreamHandler mutex
+	lastChunk time.Time
+}
+
+// StreamHandler sends the first chunk of a stream as a new message and
+// coalesces the following ones into edits, no more often than the bot's
+// EditInterval.
+type StreamHandler struct {
+	Bot *bot.Bot
+	// Scheduler paces the edits sent after the last chunk, outside of the
+	// processing of an event, they are sent right away when nil
+	Scheduler *scheduler.Scheduler
+
+	mu      sync.Mutex
+	streams map[string]*messageStream
+}
+
+func (h *StreamHandler) Handle(ctx context.Context, ev *cloudevents.Event) error {
+	_, err := h.Send(ctx, ev)
+	return err
+}
+
+func (h *StreamHandler) Send(ctx context.Context, ev *cloudevents.Event) ([]tgbotapi.Message, error) {
+	var payload models.MessageChunk
+	err := json.Unmarshal(ev.Data(), &payload)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(payload.StreamID) == 0 {
+		return nil, fmt.Errorf("%w: stream_id is required", ErrInvalidPayload)
+	}
+
+	s := h.stream(&payload)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// the stream was completed, this is a redelivered chunk
+	if s.closed {
+		return nil, nil
+	}
+
+	if _, exist := s.applied[ev.ID()]; !exist {
+		s.applied[ev.ID()] = struct{}{}
+		s.text += payload.Text
+	}
+
+	var sent []tgbotapi.Message
+
+	// roll over to a new message once the current one is full, the text only
+	// moves to the next message once the full one was sent
+	for utf8.RuneCountInString(s.text) > maxMessageLength {
+		runes := []rune(s.text)
+		m, err := h.send(s, string(runes[:maxMessageLength]))
+		if err != nil {
+			return sent, err
+		}
+		sent = append(sent, m...)
+
+		s.messageID = 0
+		s.sentText = ""
+		s.text = string(runes[maxMessageLength:])
+	}
+
+	if payload.Done {
+		s.stopTimer()
+
+		m, err := h.flush(s)
+		if err != nil {
+			return sent, err
+		}
+
+		h.close(payload.StreamID, s)
+		return append(sent, m...), nil
+	}
+
+	if s.messageID == 0 || time.Since(s.lastEdit) >= h.Bot.EditInterval() {
+		s.stopTimer()
+
+		m, err := h.flush(s)
+		return append(sent, m...), err
+	}
+
+	if s.timer == nil {
+		h.schedule(payload.StreamID, s, h.Bot.EditInterval()-time.Since(s.lastEdit))
+	}
+
+	return sent, nil
+}
+
+// schedule flushes s after delay, once the scheduler allows a message to its
+// chat. It must be called with s.mu held.
+func (h *StreamHandler) schedule(streamID string, s *messageStream, delay time.Duration) {
+	s.timer = time.AfterFunc(delay, func() {
+		h.pace(s.chatID, func() {
+			h.flushScheduled(streamID, s)
+		})
+	})
+}
+
+// pace calls fn once the global and chat rates of the scheduler allow a
+// message to chatID.
+func (h *StreamHandler) pace(chatID int64, fn func()) {
+	if h.Scheduler == nil {
+		fn()
+		return
+	}
+
+	h.Scheduler.Schedule(chatID, func() {
+		_ = h.Scheduler.Wait(context.Background())
+		fn()
+	})
+}
+
+// flushScheduled flushes s, a failed flush is retried so that the text is
+// sent even when no chunk follows, after a 429 the chat is paused meanwhile.
+func (h *StreamHandler) flushScheduled(streamID string, s *messageStream) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.timer = nil
+	if s.closed {
+		return
+	}
+
+	_, err := h.flush(s)
+	if err == nil {
+		return
+	}
+
+	var tgErr *tgbotapi.Error
+	if errors.As(err, &tgErr) && tgErr.RetryAfter > 0 {
+		retryAfter := time.Duration(tgErr.RetryAfter) * time.Second
+		log.Printf("flush stream %s rate limited, retrying in %v: %v", streamID, retryAfter, err)
+		if h.Scheduler != nil {
+			h.Scheduler.Pause(s.chatID, retryAfter, func() {
+				_ = h.Scheduler.Wait(context.Background())
+				h.flushScheduled(streamID, s)
+			})
+			return
+		}
+		h.schedule(streamID, s, retryAfter)
+		return
+	}
+
+	log.Printf("flush stream %s error, retrying in %v: %v", streamID, h.Bot.EditInterval(), err)
+	h.schedule(streamID, s, h.Bot.EditInterval())
+}
+
+// stream returns the stream of the chunk, creating it for its first chunk.
+func (h *StreamHandler) stream(chunk *models.MessageChunk) *messageStream {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.streams == nil {
+		h.streams = map[string]*messageStream{}
+	}
+
+	for id, s := range h.streams {
+		if time.Since(s.lastChunk) > streamTTL {
+			if !s.closed {
+				log.Printf("stream %s expired without done chunk", id)
+			}
+			delete(h.streams, id)
+			// s.mu is taken before h.mu elsewhere, expire it asynchronously
+			go s.expire()
+		}
+	}
+
+	s, exist := h.streams[chunk.StreamID]
+	if !exist {
+		s = &messageStream{
+			chatID:           chunk.ChatID,
+			replyToMessageID: chunk.ReplyToMessageID,
+			messageThreadID:  chunk.MessageThreadID,
+			applied:          map[string]struct{}{},
+		}
+		h.streams[chunk.StreamID] = s
+	}
+	s.lastChunk = time.Now()
+
+	return s
+}
+
+// close completes the stream, it is kept until it expires so that its
+// redelivered chunks are ignored. It must be called with s.mu held.
+func (h *StreamHandler) close(streamID string, s *messageStream) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s.closed = true
+	s.stopTimer()
+	s.text = ""
+	s.sentText = ""
+	s.lastChunk = time.Now()
+}
+
+// flush sends or edits the current message of s with the text received so
+// far, it must be called with s.mu held.
+func (h *StreamHandler) flush(s *messageStream) ([]tgbotapi.Message, error) {
+	return h.send(s, s.text)
+}
+
+// send sends or edits the current message of s with text, s only records it
+// once Telegram accepted it. It must be called with s.mu held.
+func (h *StreamHandler) send(s *messageStream, text string) ([]tgbotapi.Message, error) {
+	if len(text) == 0 || text == s.sentText {
+		return nil, nil
+	}
+
+	var m tgbotapi.Message
+	var err error
+	if s.messageID == 0 {
+		msg := tgbotapi.NewMessage(s.chatID, text)
+		msg.ReplyToMessageID = s.replyToMessageID
+		m, err = h.Bot.ThreadAPI(s.messageThreadID).Send(msg)
+	} else {
+		m, err = h.Bot.API().Send(tgbotapi.NewEditMessageText(s.chatID, s.messageID, text))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.messageID = m.MessageID
+	s.sentText = text
+	s.lastEdit = time.Now()
+
+	return []tgbotapi.Message{m}, nil
+}
+
+// expire stops the pending flush of a stream dropped from the handler.
+func (s *messageStream) expire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	s.stopTimer()
+}
+
+func (s *messageStream) stopTimer() {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+}
@@ -0,0 +1,253 @@
+package event
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/botaas/telegram-bot-connector/internal/telegramtest"
+	"github.com/botaas/telegram-bot-connector/models"
+	"github.com/botaas/telegram-bot-connector/scheduler"
+	"golang.org/x/time/rate"
+)
+
+func chunk(text string, done bool) *models.MessageChunk {
+	return &models.MessageChunk{StreamID: "s1", ChatID: 42, Text: text, Done: done}
+}
+
+func sendChunk(t *testing.T, h *StreamHandler, id string, text string, done bool) error {
+	t.Helper()
+
+	_, err := h.Send(context.Background(), newTestEvent(t, id, "message_chunk", chunk(text, done)))
+	return err
+}
+
+// lastText is the text of the last message sent or edited.
+func lastText(ft *telegramtest.Server) string {
+	calls := ft.Calls("sendMessage", "editMessageText")
+	if len(calls) == 0 {
+		return ""
+	}
+	return calls[len(calls)-1].Params.Get("text")
+}
+
+func TestStreamHandlerCoalescesChunksIntoEdits(t *testing.T) {
+	ft, b := telegramtest.New(t)
+	h := &StreamHandler{Bot: b}
+
+	for i, text := range []string{"Hello", ", ", "world"} {
+		if err := sendChunk(t, h, string(rune('a'+i)), text, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sendChunk(t, h, "d", "!", true); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(ft.Calls("sendMessage")); n != 1 {
+		t.Fatalf("sent %d messages, want 1", n)
+	}
+	if got := lastText(ft); got != "Hello, world!" {
+		t.Fatalf("text = %q, want %q", got, "Hello, world!")
+	}
+}
+
+func TestStreamHandlerRetriedChunkIsAppliedOnce(t *testing.T) {
+	ft, b := telegramtest.New(t)
+	h := &StreamHandler{Bot: b}
+
+	ft.Fail(telegramtest.FailOnce("sendMessage", &telegramtest.Failure{Code: 429, Description: "Too Many Requests", RetryAfter: 1}))
+	if err := sendChunk(t, h, "a", "Hello", false); err == nil {
+		t.Fatal("expected the rate limited send to fail")
+	}
+	// the retry of the same event
+	if err := sendChunk(t, h, "a", "Hello", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := sendChunk(t, h, "b", "", true); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := lastText(ft); got != "Hello" {
+		t.Fatalf("text = %q, want %q", got, "Hello")
+	}
+}
+
+func TestStreamHandlerRolloverKeepsTextOnFailure(t *testing.T) {
+	ft, b := telegramtest.New(t)
+	h := &StreamHandler{Bot: b}
+
+	text := strings.Repeat("a", maxMessageLength) + strings.Repeat("b", 10)
+
+	ft.Fail(telegramtest.FailOnce("sendMessage", &telegramtest.Failure{Code: 500, Description: "Internal Server Error"}))
+	if err := sendChunk(t, h, "a", text, true); err == nil {
+		t.Fatal("expected the first send to fail")
+	}
+	if err := sendChunk(t, h, "a", text, true); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := ft.Calls("sendMessage")
+	// the failed send, the full message and the rest
+	if len(calls) != 3 {
+		t.Fatalf("sent %d messages, want 3", len(calls))
+	}
+	if got := calls[1].Params.Get("text"); utf8.RuneCountInString(got) != maxMessageLength || strings.Contains(got, "b") {
+		t.Fatalf("first message has %d runes, want %d a", utf8.RuneCountInString(got), maxMessageLength)
+	}
+	if got := calls[2].Params.Get("text"); got != strings.Repeat("b", 10) {
+		t.Fatalf("second message = %q, want the remaining text", got)
+	}
+}
+
+func TestStreamHandlerRetriedDoneChunkEditsTheSameMessage(t *testing.T) {
+	ft, b := telegramtest.New(t)
+	h := &StreamHandler{Bot: b}
+
+	if err := sendChunk(t, h, "a", "Hello", false); err != nil {
+		t.Fatal(err)
+	}
+
+	ft.Fail(telegramtest.FailOnce("editMessageText", &telegramtest.Failure{Code: 500, Description: "Internal Server Error"}))
+	if err := sendChunk(t, h, "b", " world", true); err == nil {
+		t.Fatal("expected the final edit to fail")
+	}
+	if err := sendChunk(t, h, "b", " world", true); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(ft.Calls("sendMessage")); n != 1 {
+		t.Fatalf("sent %d messages, want 1", n)
+	}
+	if got := lastText(ft); got != "Hello world" {
+		t.Fatalf("text = %q, want %q", got, "Hello world")
+	}
+}
+
+func TestStreamHandlerIgnoresRedeliveredChunksOfCompletedStream(t *testing.T) {
+	ft, b := telegramtest.New(t)
+	h := &StreamHandler{Bot: b}
+
+	if err := sendChunk(t, h, "a", "Hello", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := sendChunk(t, h, "a", "Hello", true); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(ft.Calls("sendMessage")); n != 1 {
+		t.Fatalf("sent %d messages, want 1", n)
+	}
+}
+
+func TestStreamHandlerRetriesFailedTimerFlush(t *testing.T) {
+	ft, b := telegramtest.New(t)
+	h := &StreamHandler{Bot: b}
+
+	if err := sendChunk(t, h, "a", "Hello", false); err != nil {
+		t.Fatal(err)
+	}
+
+	// the chunk arrives within the edit interval, it is flushed by the timer
+	ft.Fail(telegramtest.FailOnce("editMessageText", &telegramtest.Failure{Code: 500, Description: "Internal Server Error"}))
+	if err := sendChunk(t, h, "b", " world", false); err != nil {
+		t.Fatal(err)
+	}
+
+	// the failed edit and its retry
+	deadline := time.Now().Add(2 * time.Second)
+	for len(ft.Calls("editMessageText")) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("timer did not retry the failed edit")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := lastText(ft); got != "Hello world" {
+		t.Fatalf("text = %q, want %q", got, "Hello world")
+	}
+
+	if err := sendChunk(t, h, "c", "", true); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStreamHandlerTimerFlushIsPacedByTheScheduler(t *testing.T) {
+	ft, b := telegramtest.New(t)
+	s := scheduler.New(rate.Inf, rate.Every(300*time.Millisecond), rate.Inf)
+	h := &StreamHandler{Bot: b, Scheduler: s}
+
+	// the first chunk event took the message allowed to the chat
+	start := time.Now()
+	s.Schedule(42, func() {
+		if err := sendChunk(t, h, "a", "Hello", false); err != nil {
+			t.Error(err)
+		}
+	})
+	if err := sendChunk(t, h, "b", " world", false); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for lastText(ft) != "Hello world" {
+		if time.Now().After(deadline) {
+			t.Fatalf("text = %q, want the timer to flush the chunk", lastText(ft))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if elapsed := time.Since(start); elapsed < 250*time.Millisecond {
+		t.Errorf("timer flush edited the message after %v, before the chat rate allowed it", elapsed)
+	}
+
+	if err := sendChunk(t, h, "c", "", true); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStreamHandlerRateLimitedTimerFlushPausesTheChat(t *testing.T) {
+	ft, b := telegramtest.New(t)
+	s := scheduler.New(rate.Inf, rate.Inf, rate.Inf)
+	h := &StreamHandler{Bot: b, Scheduler: s}
+
+	if err := sendChunk(t, h, "a", "Hello", false); err != nil {
+		t.Fatal(err)
+	}
+	ft.Fail(telegramtest.FailOnce("editMessageText", &telegramtest.Failure{Code: 429, Description: "Too Many Requests", RetryAfter: 1}))
+	if err := sendChunk(t, h, "b", " world", false); err != nil {
+		t.Fatal(err)
+	}
+
+	// wait for the rate limited flush to be answered
+	deadline := time.Now().Add(time.Second)
+	for len(ft.Calls("editMessageText")) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("timer did not flush the chunk")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	limited := time.Now()
+	time.Sleep(100 * time.Millisecond)
+
+	// the chat is held back until the retry, which runs first
+	sent := make(chan time.Time, 1)
+	s.Schedule(42, func() { sent <- time.Now() })
+	select {
+	case at := <-sent:
+		if at.Sub(limited) < 900*time.Millisecond {
+			t.Errorf("next message of the chat sent after %v, during the pause", at.Sub(limited))
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("chat not resumed")
+	}
+	if n := len(ft.Calls("editMessageText")); n != 2 {
+		t.Errorf("edited %d times, want the rate limited edit and its retry", n)
+	}
+	if got := lastText(ft); got != "Hello world" {
+		t.Errorf("text = %q, want the retried edit", got)
+	}
+
+	if err := sendChunk(t, h, "c", "", true); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,125 @@
+// Package brokertest provides helpers and a conformance test for brokers.
+package brokertest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/botaas/telegram-bot-connector/broker"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+// NewEvent returns a message event with the given id and a JSON text.
+func NewEvent(id string) *cloudevents.Event {
+	ev := cloudevents.NewEvent()
+	ev.SetID(id)
+	ev.SetType("message")
+	ev.SetSource("test")
+	_ = ev.SetData(cloudevents.ApplicationJSON, map[string]string{"text": "hello"})
+	return &ev
+}
+
+// Subscribe subscribes to channel of b and returns the events it receives
+// along with the function canceling the subscription.
+func Subscribe(t *testing.T, ctx context.Context, b broker.Broker, channel string) (<-chan *cloudevents.Event, func()) {
+	t.Helper()
+
+	received := make(chan *cloudevents.Event, 10)
+	unsub, err := b.Subscribe(ctx, channel, func(ev *cloudevents.Event) error {
+		received <- ev
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return received, unsub.Cancel
+}
+
+// ExpectEvent waits for the event with the given id to be received next.
+func ExpectEvent(t *testing.T, received <-chan *cloudevents.Event, id string) *cloudevents.Event {
+	t.Helper()
+
+	select {
+	case ev := <-received:
+		if ev.ID() != id {
+			t.Fatalf("received event %s, want %s", ev.ID(), id)
+		}
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatalf("event %s was not delivered", id)
+		return nil
+	}
+}
+
+// ExpectNoEvent checks that no event is received for a while.
+func ExpectNoEvent(t *testing.T, received <-chan *cloudevents.Event) {
+	t.Helper()
+
+	select {
+	case ev := <-received:
+		t.Fatalf("received event %s, want none", ev.ID())
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// Run runs the behaviour every broker shares against the brokers newBroker
+// returns, a new one for each test. The brokers publish to and receive from
+// the inbox and outbox channels.
+func Run(t *testing.T, newBroker func(t *testing.T) broker.Broker) {
+	t.Run("DeliversEvents", func(t *testing.T) {
+		b := newBroker(t)
+		ctx := context.Background()
+
+		received, cancel := Subscribe(t, ctx, b, "outbox")
+		defer cancel()
+
+		sent := NewEvent("1")
+		sent.SetSubject("42")
+		err := b.Publish(ctx, "outbox", sent)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ev := ExpectEvent(t, received, "1")
+		if ev.Type() != sent.Type() || ev.Source() != sent.Source() || ev.Subject() != sent.Subject() {
+			t.Errorf("received %s from %s about %s, want %s from %s about %s",
+				ev.Type(), ev.Source(), ev.Subject(), sent.Type(), sent.Source(), sent.Subject())
+		}
+		if string(ev.Data()) != string(sent.Data()) {
+			t.Errorf("received data %s, want %s", ev.Data(), sent.Data())
+		}
+	})
+
+	t.Run("KeepsChannelsApart", func(t *testing.T) {
+		b := newBroker(t)
+		ctx := context.Background()
+
+		inbox, cancelInbox := Subscribe(t, ctx, b, "inbox")
+		defer cancelInbox()
+		outbox, cancelOutbox := Subscribe(t, ctx, b, "outbox")
+		defer cancelOutbox()
+
+		err := b.Publish(ctx, "outbox", NewEvent("1"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ExpectEvent(t, outbox, "1")
+		ExpectNoEvent(t, inbox)
+	})
+
+	t.Run("CancelStopsDelivery", func(t *testing.T) {
+		b := newBroker(t)
+		ctx := context.Background()
+
+		received, cancel := Subscribe(t, ctx, b, "outbox")
+		cancel()
+		// let the subscription wind down
+		time.Sleep(50 * time.Millisecond)
+
+		// brokers without subscriber may refuse the event, or keep it
+		_ = b.Publish(ctx, "outbox", NewEvent("1"))
+		ExpectNoEvent(t, received)
+	})
+}
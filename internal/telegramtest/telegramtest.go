@@ -0,0 +1,206 @@
+// Package telegramtest provides a fake Bot API server for tests.
+package telegramtest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/botaas/telegram-bot-connector/bot"
+)
+
+// Call is a Bot API call received by a Server.
+type Call struct {
+	Method string
+	Params url.Values
+	// Files uploaded with the call, by parameter
+	Files map[string]File
+}
+
+// File is a file uploaded to a Server.
+type File struct {
+	Name string
+	Data []byte
+}
+
+// Failure is the error a Server answers a call with.
+type Failure struct {
+	Code        int
+	Description string
+	RetryAfter  int
+}
+
+// Server is a Bot API server recording the calls it receives. Calls succeed
+// with a message of the chat_id, message_thread_id and text of the call,
+// getFile with the file of the file_id at files/<file_id> unless it fails.
+type Server struct {
+	mu            sync.Mutex
+	calls         []Call
+	nextMessageID int
+	// fail returns the failure to answer call with, nil for success
+	fail func(call Call) *Failure
+}
+
+// New starts a Server for the duration of the test and returns a bot using it.
+func New(t testing.TB) (*Server, *bot.Bot) {
+	t.Helper()
+
+	s := &Server{}
+	srv := httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(srv.Close)
+
+	t.Setenv("TELEGRAM_API_ENDPOINT", srv.URL+"/bot%s/%s")
+	b, err := bot.New("token", 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s, b
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseMultipartForm(1 << 20)
+	call := Call{
+		Method: r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:],
+		Params: r.Form,
+		Files:  uploadedFiles(r),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if call.Method == "getMe" {
+		writeJSON(w, map[string]any{"ok": true, "result": map[string]any{"id": 1, "is_bot": true, "username": "test_bot"}})
+		return
+	}
+
+	s.calls = append(s.calls, call)
+
+	if s.fail != nil {
+		if failure := s.fail(call); failure != nil {
+			resp := map[string]any{"ok": false, "error_code": failure.Code, "description": failure.Description}
+			if failure.RetryAfter > 0 {
+				resp["parameters"] = map[string]any{"retry_after": failure.RetryAfter}
+			}
+			writeJSON(w, resp)
+			return
+		}
+	}
+
+	if call.Method == "getFile" {
+		fileID := call.Params.Get("file_id")
+		writeJSON(w, map[string]any{"ok": true, "result": map[string]any{"file_id": fileID, "file_path": "files/" + fileID}})
+		return
+	}
+
+	chatID, _ := strconv.ParseInt(call.Params.Get("chat_id"), 10, 64)
+	messageID, _ := strconv.Atoi(call.Params.Get("message_id"))
+	if messageID == 0 {
+		s.nextMessageID++
+		messageID = s.nextMessageID
+	}
+
+	message := map[string]any{
+		"message_id": messageID,
+		"chat":       map[string]any{"id": chatID, "type": "private"},
+		"text":       call.Params.Get("text"),
+	}
+	if threadID, _ := strconv.Atoi(call.Params.Get("message_thread_id")); threadID != 0 {
+		message["message_thread_id"] = threadID
+		message["is_topic_message"] = true
+		message["chat"] = map[string]any{"id": chatID, "type": "supergroup", "is_forum": true}
+	}
+	writeJSON(w, map[string]any{"ok": true, "result": message})
+}
+
+func uploadedFiles(r *http.Request) map[string]File {
+	if r.MultipartForm == nil {
+		return nil
+	}
+
+	files := map[string]File{}
+	for param, headers := range r.MultipartForm.File {
+		f, err := headers[0].Open()
+		if err != nil {
+			continue
+		}
+		data, _ := io.ReadAll(f)
+		f.Close()
+		files[param] = File{Name: headers[0].Filename, Data: data}
+	}
+	return files
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// Calls returns the calls of the methods received so far, all calls without
+// methods.
+func (s *Server) Calls(methods ...string) []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var calls []Call
+	for _, c := range s.calls {
+		if len(methods) == 0 || contains(methods, c.Method) {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// WaitCalls waits up to 2 seconds for n calls of the methods, all calls
+// without methods, and returns the calls received.
+func (s *Server) WaitCalls(t testing.TB, n int, methods ...string) []Call {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		calls := s.Calls(methods...)
+		if len(calls) >= n {
+			return calls
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("received %d calls of %v, want %d", len(calls), methods, n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Fail answers the next calls with the failure fail returns, nil for success.
+func (s *Server) Fail(fail func(call Call) *Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.fail = fail
+}
+
+// FailOnce fails the first call of method.
+func FailOnce(method string, failure *Failure) func(call Call) *Failure {
+	failed := false
+	return func(call Call) *Failure {
+		if call.Method != method || failed {
+			return nil
+		}
+		failed = true
+		return failure
+	}
+}
+
+func contains(methods []string, method string) bool {
+	for _, m := range methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
@@ -2,35 +2,96 @@ package main
 
 import (
 	"context"
-	"encoding/json"
-	"errors"
-	"fmt"
-	"math/rand"
+	"expvar"
+	"net/http"
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
-	"golang.org/x/time/rate"
-
 	"github.com/botaas/telegram-bot-connector/bot"
-	"github.com/botaas/telegram-bot-connector/broker/redis"
+	"github.com/botaas/telegram-bot-connector/connector"
 	"github.com/botaas/telegram-bot-connector/converter"
-	"github.com/botaas/telegram-bot-connector/event"
-	"github.com/botaas/telegram-bot-connector/models"
-	cloudevents "github.com/cloudevents/sdk-go/v2"
-	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/botaas/telegram-bot-connector/transcriber"
 	log "github.com/sirupsen/logrus"
 )
 
+func webhookConfigFromEnv(webhookURL string) bot.WebhookConfig {
+	webhookConfig := bot.WebhookConfig{
+		URL:        webhookURL,
+		ListenAddr: ":8080",
+	}
+
+	if listenAddr, exist := os.LookupEnv("WEBHOOK_LISTEN_ADDR"); exist && listenAddr != "" {
+		webhookConfig.ListenAddr = listenAddr
+	}
+
+	webhookConfig.SecretToken, _ = os.LookupEnv("WEBHOOK_SECRET_TOKEN")
+	webhookConfig.CertFile, _ = os.LookupEnv("WEBHOOK_CERT_FILE")
+	webhookConfig.KeyFile, _ = os.LookupEnv("WEBHOOK_KEY_FILE")
+
+	if maxConnectionsStr, exist := os.LookupEnv("WEBHOOK_MAX_CONNECTIONS"); exist {
+		maxConnections, err := strconv.Atoi(maxConnectionsStr)
+		if err != nil {
+			log.Fatalf("invalid WEBHOOK_MAX_CONNECTIONS: %v", err)
+		}
+		webhookConfig.MaxConnections = maxConnections
+	}
+
+	webhookConfig.AllowedUpdates = allowedUpdatesFromEnv()
+
+	return webhookConfig
+}
+
+// receiveUpdates listens for the webhook when WEBHOOK_URL is set, else polls.
+func receiveUpdates(b *bot.Bot) bot.UpdatesChannel {
+	webhookURL, exist := os.LookupEnv("WEBHOOK_URL")
+	if !exist || webhookURL == "" {
+		return b.GetUpdatesChan(allowedUpdatesFromEnv())
+	}
+
+	webhookConfig := webhookConfigFromEnv(webhookURL)
+	updates, err := b.ListenForWebhook(webhookConfig)
+	if err != nil {
+		log.Fatalf("Couldn't listen for webhook: %v", err)
+	}
+	log.Infof("Listening for webhook on %s", webhookConfig.ListenAddr)
+
+	return updates
+}
+
+// allowedUpdatesFromEnv reads the update types to receive, empty for the
+// defaults of the bot package.
+func allowedUpdatesFromEnv() []string {
+	if allowedUpdates, exist := os.LookupEnv("ALLOWED_UPDATES"); exist && allowedUpdates != "" {
+		return strings.Split(allowedUpdates, ",")
+	}
+
+	return nil
+}
+
+// messageFormatFromEnv reads the default format of texts and captions, empty
+// for plain text.
+func messageFormatFromEnv() string {
+	format, _ := os.LookupEnv("MESSAGE_FORMAT")
+	if !bot.IsMessageFormat(format) {
+		log.Fatalf("invalid MESSAGE_FORMAT: %s", format)
+	}
+	return format
+}
+
 func main() {
 	log.SetLevel(log.DebugLevel)
 
+	cfg := connector.DefaultConfig()
+
 	outbox, exist := os.LookupEnv("OUTBOX")
 	if !exist || outbox == "" {
 		log.Fatal("outbox not provide")
 	}
+	cfg.Outbox = outbox
 
 	log.Printf("outbox %s\n", outbox)
 
@@ -38,45 +99,98 @@ func main() {
 	if !exist || inbox == "" {
 		log.Fatal("inbox not provide")
 	}
+	cfg.Inbox = inbox
 	log.Printf("inbox %s\n", inbox)
 
-	addr, exist := os.LookupEnv("REDIS_ADDR")
-	if !exist || addr == "" {
-		log.Fatal("redis addr not provide")
-	}
-
-	redisPassword, exist := os.LookupEnv("REDIS_PASSWORD")
-
-	broker := redis.New(
-		redis.WithAddr(addr),
-		redis.WithPassword(redisPassword),
-	)
-
 	token, exist := os.LookupEnv("TELEGRAM_BOT_TOKEN")
 	if !exist || token == "" {
 		log.Fatal("token not provide")
 	}
 
 	var err error
-	var concurrency int
 	concurrencyStr, exist := os.LookupEnv("CONCURRENCY")
-	if !exist {
-		concurrency = 8
-	} else {
-		concurrency, err = strconv.Atoi(concurrencyStr)
-		if err != nil {
-			concurrency = 8
+	if exist {
+		concurrency, err := strconv.Atoi(concurrencyStr)
+		if err == nil {
+			cfg.Concurrency = concurrency
 		}
 	}
 
-	var ratelimit int
+	broker := newBroker(cfg.Concurrency)
+
+	// messages per minute, overall and per chat
 	ratelimitStr, exist := os.LookupEnv("RATELIMIT")
-	if !exist {
-		ratelimit = 1000
-	} else {
-		ratelimit, err = strconv.Atoi(ratelimitStr)
+	if exist {
+		ratelimit, err := strconv.Atoi(ratelimitStr)
+		if err == nil {
+			cfg.RateLimit = ratelimit
+		}
+	}
+
+	privateChatRatelimitStr, exist := os.LookupEnv("PRIVATE_CHAT_RATELIMIT")
+	if exist {
+		privateChatRatelimit, err := strconv.Atoi(privateChatRatelimitStr)
+		if err == nil {
+			cfg.PrivateChatRateLimit = privateChatRatelimit
+		}
+	}
+
+	groupChatRatelimitStr, exist := os.LookupEnv("GROUP_CHAT_RATELIMIT")
+	if exist {
+		groupChatRatelimit, err := strconv.Atoi(groupChatRatelimitStr)
+		if err == nil {
+			cfg.GroupChatRateLimit = groupChatRatelimit
+		}
+	}
+
+	transcriberName, exist := os.LookupEnv("TRANSCRIBER")
+	if !exist || transcriberName == "" {
+		transcriberName = "none"
+		if _, exist := os.LookupEnv("OPENAI_API_KEY"); exist {
+			transcriberName = "openai"
+		}
+	}
+
+	switch transcriberName {
+	case "none":
+		converter.SetTranscriber(transcriber.NewNoop())
+	case "openai":
+		baseURL, exist := os.LookupEnv("TRANSCRIBER_BASE_URL")
+		if !exist || baseURL == "" {
+			baseURL = "https://api.openai.com/v1"
+		}
+
+		model, exist := os.LookupEnv("TRANSCRIBER_MODEL")
+		if !exist || model == "" {
+			model = "whisper-1"
+		}
+
+		apiKey, exist := os.LookupEnv("TRANSCRIBER_API_KEY")
+		if !exist {
+			apiKey = os.Getenv("OPENAI_API_KEY")
+		}
+
+		converter.SetTranscriber(transcriber.NewOpenAI(baseURL, model, apiKey))
+	case "command":
+		command := strings.Fields(os.Getenv("TRANSCRIBER_COMMAND"))
+		if len(command) == 0 {
+			log.Fatal("transcriber command not provide")
+		}
+
+		converter.SetTranscriber(transcriber.NewCommand(command[0], command[1:]...))
+	default:
+		log.Fatalf("unknown transcriber: %s", transcriberName)
+	}
+	log.Printf("transcriber %s\n", transcriberName)
+
+	transcriptionTimeoutStr, exist := os.LookupEnv("TRANSCRIPTION_TIMEOUT")
+	if exist {
+		cfg.TranscriptionTimeout, err = time.ParseDuration(transcriptionTimeoutStr)
 		if err != nil {
-			ratelimit = 1000
+			log.Fatalf("invalid TRANSCRIPTION_TIMEOUT: %v", err)
+		}
+		if cfg.TranscriptionTimeout <= 0 {
+			log.Fatalf("invalid TRANSCRIPTION_TIMEOUT: %v, must be greater than 0", cfg.TranscriptionTimeout)
 		}
 	}
 
@@ -96,171 +210,115 @@ func main() {
 	ctx := context.Background()
 	log.Infof("Started Telegram bot! Bot username: @%s.", bot.Self.UserName)
 
-	eventManager := event.New()
-	eventManager.RegisterHandler("message", &event.MessageHandler{
-		Bot: bot,
-	})
-	eventManager.RegisterHandler("chat_action", &event.ChatActionHandler{
-		Bot: bot,
-	})
-
-	var outboxChans = make([]chan *cloudevents.Event, concurrency)
-	for i := 0; i < concurrency; i++ {
-		outboxChans[i] = make(chan *cloudevents.Event, 1)
-	}
-
-	for i := 0; i < concurrency; i++ {
-		ch := outboxChans[i]
-		go func(index int) {
-			for ev := range ch {
-				err := eventManager.Process(ctx, ev)
-				if err != nil {
-					log.WithFields(
-						log.Fields{
-							"chain": index,
-							"data":  string(ev.Data()),
-						},
-					).Error("Process outbox message error", err)
-				}
+	// expvar serves /debug/vars on the default mux
+	metricsAddr, exist := os.LookupEnv("METRICS_ADDR")
+	if exist && metricsAddr != "" {
+		go func() {
+			err := http.ListenAndServe(metricsAddr, nil)
+			if err != nil {
+				log.Errorf("metrics server error: %v", err)
 			}
-		}(i)
+		}()
 	}
 
-	limiter := rate.NewLimiter(rate.Every(time.Minute/time.Duration(ratelimit)), ratelimit)
-
-	unsubscriber, err := broker.Subscribe(ctx, outbox, func(ev *cloudevents.Event) error {
-		log.Printf("outbox event: %v\n", string(ev.Data()))
+	// texts and captions sent without parse mode or entities use MESSAGE_FORMAT
+	cfg.MessageFormat = messageFormatFromEnv()
 
-		err := limiter.Wait(ctx)
+	autoAckStr, exist := os.LookupEnv("CALLBACK_QUERY_AUTO_ACK")
+	if exist && autoAckStr != "" {
+		cfg.CallbackQueryAutoAck, err = time.ParseDuration(autoAckStr)
 		if err != nil {
-			return err
-			// return errors.New(fmt.Sprintf("发送消息被限制: %v", err))
+			log.Fatalf("invalid CALLBACK_QUERY_AUTO_ACK: %v", err)
 		}
-
-		i := rand.Intn(concurrency)
-		outboxChans[i] <- ev
-
-		return nil
-	})
-
-	if err != nil {
-		log.Fatal("Subscribe outbox error")
 	}
 
-	defer unsubscriber.Cancel()
-
-	/* 切换为 webhook
-	webHook := "https://api.telegram.org/bot%s/setWebhook?url=https://cargo-telegram-bot.herokuapp.com/%s"
-	webhookConfig := tgbotapi.NewWebhook(fmt.Sprintf(webHook, core.Config.BotToken, core.Config.BotToken))
-	_, _ = bot.SetWebhook(webhookConfig)
-	updates = bot.ListenForWebhook("/" + bot.Token)
-	*/
-
-	var chans = make([]chan *tgbotapi.Update, concurrency)
-	for i := 0; i < concurrency; i++ {
-		chans[i] = make(chan *tgbotapi.Update, 1)
+	preCheckoutMode, _ := os.LookupEnv("PRE_CHECKOUT_MODE")
+	if preCheckoutMode != "" && preCheckoutMode != "auto" && preCheckoutMode != "backend" {
+		log.Fatalf("invalid PRE_CHECKOUT_MODE: %s", preCheckoutMode)
 	}
+	cfg.PreCheckoutBackend = preCheckoutMode == "backend"
 
-	go func() {
-		process_update := func(update *tgbotapi.Update) error {
-			if update.PreCheckoutQuery != nil {
-				event := cloudevents.NewEvent()
-				event.SetType("pre_checkout_query")
-				event.SetData(cloudevents.ApplicationJSON, update.PreCheckoutQuery)
-				err = broker.Publish(ctx, inbox, &event)
-				if err != nil {
-					log.Printf("publish to redis error: %v", err)
-				}
-
-				pca := tgbotapi.PreCheckoutConfig{
-					OK:                 true,
-					PreCheckoutQueryID: update.PreCheckoutQuery.ID,
-				}
-
-				_, err := bot.API().Request(pca)
-				if err != nil {
-					log.Printf("send pre_checkout error: %v", err)
-				}
-			}
+	preCheckoutTimeoutStr, exist := os.LookupEnv("PRE_CHECKOUT_TIMEOUT")
+	if exist {
+		cfg.PreCheckoutTimeout, err = time.ParseDuration(preCheckoutTimeoutStr)
+		if err != nil {
+			log.Fatalf("invalid PRE_CHECKOUT_TIMEOUT: %v", err)
+		}
+	}
 
-			if update.CallbackQuery != nil {
-				m, err := converter.NormalizeTelegramMessage(bot.API(), update.CallbackQuery.Message)
-				if err != nil {
-					return errors.New(fmt.Sprintf("Error normalize Telegram Message: %v", err))
-				}
-
-				callbackQuery := &models.CallbackQuery{
-					ID:              update.CallbackQuery.ID,
-					From:            converter.NormalizeTelegramUser(update.CallbackQuery.From),
-					Message:         m,
-					InlineMessageID: update.CallbackQuery.InlineMessageID,
-					ChatInstance:    update.CallbackQuery.ChatInstance,
-					Data:            update.CallbackQuery.Data,
-					GameShortName:   update.CallbackQuery.GameShortName,
-				}
-
-				event := cloudevents.NewEvent()
-				event.SetType("callback_query")
-				event.SetData(cloudevents.ApplicationJSON, callbackQuery)
-				err = broker.Publish(ctx, inbox, &event)
-				if err != nil {
-					return errors.New(fmt.Sprintf("publish to redis error: %v", err))
-				}
-			}
+	preCheckoutDefaultStr, exist := os.LookupEnv("PRE_CHECKOUT_DEFAULT")
+	if exist {
+		switch preCheckoutDefaultStr {
+		case "ok":
+			cfg.PreCheckoutDefault = true
+		case "reject":
+		default:
+			log.Fatalf("invalid PRE_CHECKOUT_DEFAULT: %s", preCheckoutDefaultStr)
+		}
+	}
 
-			if update.Message != nil {
-				m, err := converter.NormalizeTelegramMessage(bot.API(), update.Message)
-				if err != nil {
-					return errors.New(fmt.Sprintf("Error normalize Telegram Message: %v", err))
-				}
-
-				event := cloudevents.NewEvent()
-				event.SetType("message")
-				event.SetData(cloudevents.ApplicationJSON, m)
-				err = broker.Publish(ctx, inbox, &event)
-				if err != nil {
-					return errors.New(fmt.Sprintf("publish to redis error: %v", err))
-				}
-			}
+	preCheckoutErrorMessage, exist := os.LookupEnv("PRE_CHECKOUT_ERROR_MESSAGE")
+	if exist && preCheckoutErrorMessage != "" {
+		cfg.PreCheckoutErrorMessage = preCheckoutErrorMessage
+	}
 
-			return nil
+	deliveryReceiptsStr, exist := os.LookupEnv("DELIVERY_RECEIPTS")
+	if exist {
+		cfg.DeliveryReceipts, err = strconv.ParseBool(deliveryReceiptsStr)
+		if err != nil {
+			log.Fatalf("invalid DELIVERY_RECEIPTS: %v", err)
 		}
+	}
 
-		for i := 0; i < concurrency; i++ {
-			ch := chans[i]
-			go func(index int) {
-				for update := range ch {
-					b, _ := json.Marshal(update)
-					log.Debugf("inbox, chan: %v: %v\n", index, string(b))
-
-					err := process_update(update)
-					if err != nil {
-						log.WithFields(
-							log.Fields{
-								"chain":  index,
-								"update": string(b),
-							},
-						).Error("Process update error", err)
-					}
-				}
-			}(i)
+	outboxQueueSizeStr, exist := os.LookupEnv("OUTBOX_QUEUE_SIZE")
+	if exist {
+		outboxQueueSize, err := strconv.Atoi(outboxQueueSizeStr)
+		if err == nil {
+			cfg.OutboxQueueSize = outboxQueueSize
 		}
-	}()
+	}
 
-	for update := range bot.GetUpdatesChan() {
-		i := 0
-		if update.PreCheckoutQuery != nil {
-			i = int(update.PreCheckoutQuery.From.ID % int64(concurrency))
+	maxAttemptsStr, exist := os.LookupEnv("RETRY_MAX_ATTEMPTS")
+	if exist {
+		cfg.RetryPolicy.MaxAttempts, err = strconv.Atoi(maxAttemptsStr)
+		if err != nil {
+			log.Fatalf("invalid RETRY_MAX_ATTEMPTS: %v", err)
 		}
+	}
 
-		if update.CallbackQuery != nil {
-			i = int(update.CallbackQuery.From.ID % int64(concurrency))
+	baseDelayStr, exist := os.LookupEnv("RETRY_BASE_DELAY")
+	if exist {
+		cfg.RetryPolicy.BaseDelay, err = time.ParseDuration(baseDelayStr)
+		if err != nil {
+			log.Fatalf("invalid RETRY_BASE_DELAY: %v", err)
 		}
+	}
 
-		if update.Message != nil {
-			i = int(update.Message.Chat.ID % int64(concurrency))
+	maxDelayStr, exist := os.LookupEnv("RETRY_MAX_DELAY")
+	if exist {
+		cfg.RetryPolicy.MaxDelay, err = time.ParseDuration(maxDelayStr)
+		if err != nil {
+			log.Fatalf("invalid RETRY_MAX_DELAY: %v", err)
 		}
+	}
 
-		chans[i] <- &update
+	cfg.DeadLetter, _ = os.LookupEnv("DEAD_LETTER")
+	if cfg.DeadLetter != "" {
+		log.Printf("dead letter %s\n", cfg.DeadLetter)
 	}
+
+	connector := connector.New(bot, broker, cfg)
+
+	expvar.Publish("outbox_queue_depth", expvar.Func(func() any {
+		return connector.QueueDepths()
+	}))
+
+	err = connector.Start(ctx)
+	if err != nil {
+		log.Fatal("Subscribe outbox error")
+	}
+
+	defer connector.Stop()
+
+	connector.Run(receiveUpdates(bot))
 }
@@ -28,6 +28,30 @@ type User struct {
 
 type Chat struct {
 	ID int64 `json:"id,omitempty"`
+	// Type of chat, can be either "private", "group", "supergroup" or "channel"
+	//
+	// optional
+	Type string `json:"type,omitempty"`
+	// Title for supergroups, channels and group chats
+	//
+	// optional
+	Title string `json:"title,omitempty"`
+	// UserName for private chats, supergroups and channels if available
+	//
+	// optional
+	UserName string `json:"username,omitempty"`
+	// FirstName of the other party in a private chat
+	//
+	// optional
+	FirstName string `json:"first_name,omitempty"`
+	// LastName of the other party in a private chat
+	//
+	// optional
+	LastName string `json:"last_name,omitempty"`
+	// IsForum is set when the supergroup has topics enabled
+	//
+	// optional
+	IsForum bool `json:"is_forum,omitempty"`
 }
 
 type Photo struct {
@@ -62,6 +86,180 @@ type Video struct {
 	FileSize int    `json:"file_size,omitempty"`
 }
 
+// Document is a general file, a file uploaded from Data is named FileName.
+type Document struct {
+	Url    string `json:"url"`
+	FileID string `json:"file_id,omitempty"`
+	// Data is uploaded when neither FileID nor Url is set, base64 encoded in JSON
+	Data     []byte `json:"data,omitempty"`
+	FileName string `json:"file_name,omitempty"`
+	MimeType string `json:"mime_type,omitempty"`
+	FileSize int    `json:"file_size,omitempty"`
+}
+
+type Sticker struct {
+	Url    string `json:"url"`
+	FileID string `json:"file_id,omitempty"`
+	// Data is uploaded when neither FileID nor Url is set, a .webp, .tgs or
+	// .webm sticker
+	Data       []byte `json:"data,omitempty"`
+	Width      int    `json:"width,omitempty"`
+	Height     int    `json:"height,omitempty"`
+	IsAnimated bool   `json:"is_animated,omitempty"`
+	IsVideo    bool   `json:"is_video,omitempty"`
+	// Emoji associated with the sticker
+	Emoji string `json:"emoji,omitempty"`
+	// SetName of the sticker set the sticker belongs to
+	SetName  string `json:"set_name,omitempty"`
+	FileSize int    `json:"file_size,omitempty"`
+}
+
+// Animation is a GIF or an H.264/MPEG-4 AVC video without sound.
+type Animation struct {
+	Url    string `json:"url"`
+	FileID string `json:"file_id,omitempty"`
+	// Data is uploaded when neither FileID nor Url is set
+	Data     []byte `json:"data,omitempty"`
+	Width    int    `json:"width,omitempty"`
+	Height   int    `json:"height,omitempty"`
+	Duration int    `json:"duration,omitempty"`
+	FileName string `json:"file_name,omitempty"`
+	MimeType string `json:"mime_type,omitempty"`
+	FileSize int    `json:"file_size,omitempty"`
+}
+
+// VideoNote is a rounded square video message.
+type VideoNote struct {
+	Url    string `json:"url"`
+	FileID string `json:"file_id,omitempty"`
+	// Data is uploaded when neither FileID nor Url is set
+	Data []byte `json:"data,omitempty"`
+	// Length video width and height (diameter of the video message)
+	Length   int `json:"length,omitempty"`
+	Duration int `json:"duration,omitempty"`
+	FileSize int `json:"file_size,omitempty"`
+}
+
+type Location struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	// HorizontalAccuracy radius of uncertainty for the location, in meters, 0-1500
+	//
+	// optional
+	HorizontalAccuracy float64 `json:"horizontal_accuracy,omitempty"`
+	// LivePeriod period in seconds the location is updated for, 60-86400,
+	// makes it a live location
+	//
+	// optional
+	LivePeriod int `json:"live_period,omitempty"`
+	// Heading direction a live location is moving to, in degrees, 1-360
+	//
+	// optional
+	Heading int `json:"heading,omitempty"`
+	// ProximityAlertRadius maximum distance in meters for proximity alerts
+	// about approaching another chat member of a live location
+	//
+	// optional
+	ProximityAlertRadius int `json:"proximity_alert_radius,omitempty"`
+}
+
+type Venue struct {
+	Location        Location `json:"location"`
+	Title           string   `json:"title"`
+	Address         string   `json:"address"`
+	FoursquareID    string   `json:"foursquare_id,omitempty"`
+	FoursquareType  string   `json:"foursquare_type,omitempty"`
+	GooglePlaceID   string   `json:"google_place_id,omitempty"`
+	GooglePlaceType string   `json:"google_place_type,omitempty"`
+}
+
+type Contact struct {
+	PhoneNumber string `json:"phone_number"`
+	FirstName   string `json:"first_name"`
+	LastName    string `json:"last_name,omitempty"`
+	// UserID of the contact when it is a Telegram user
+	UserID int64 `json:"user_id,omitempty"`
+	// VCard additional data about the contact
+	VCard string `json:"vcard,omitempty"`
+}
+
+// Dice is an animated emoji with a random value, sent dices get their value
+// from Telegram.
+type Dice struct {
+	// Emoji the dice animation is based on, 🎲 by default
+	Emoji string `json:"emoji,omitempty"`
+	Value int    `json:"value,omitempty"`
+}
+
+type PollOption struct {
+	Text       string `json:"text"`
+	VoterCount int    `json:"voter_count,omitempty"`
+}
+
+type Poll struct {
+	ID       string       `json:"id,omitempty"`
+	Question string       `json:"question"`
+	Options  []PollOption `json:"options"`
+	// TotalVoterCount is only set on received polls
+	TotalVoterCount int  `json:"total_voter_count,omitempty"`
+	IsClosed        bool `json:"is_closed,omitempty"`
+	// IsAnonymous is true by default for sent polls
+	IsAnonymous *bool `json:"is_anonymous,omitempty"`
+	// Type "regular" (default) or "quiz"
+	Type                  string `json:"type,omitempty"`
+	AllowsMultipleAnswers bool   `json:"allows_multiple_answers,omitempty"`
+	// CorrectOptionID 0-based index of the right answer of a quiz, required
+	// to send one
+	//
+	// optional
+	CorrectOptionID *int `json:"correct_option_id,omitempty"`
+	// Explanation shown when a user chooses a wrong answer in a quiz
+	//
+	// optional
+	Explanation         string          `json:"explanation,omitempty"`
+	ExplanationEntities []MessageEntity `json:"explanation_entities,omitempty"`
+	// OpenPeriod time in seconds the poll is active after creation, 5-600
+	//
+	// optional
+	OpenPeriod int `json:"open_period,omitempty"`
+	// CloseDate unix time the poll is closed at
+	//
+	// optional
+	CloseDate int `json:"close_date,omitempty"`
+}
+
+// PollAnswer is a changed answer of a user in a non-anonymous poll sent by
+// the bot, OptionIDs is empty when the vote was retracted.
+type PollAnswer struct {
+	PollID    string `json:"poll_id"`
+	User      *User  `json:"user"`
+	OptionIDs []int  `json:"option_ids"`
+}
+
+// MessageEntity is a special entity in a text or a caption, e.g. a hashtag,
+// a mention or a bold part.
+type MessageEntity struct {
+	// Type of the entity, e.g. "mention", "hashtag", "bot_command", "url",
+	// "bold", "italic", "code", "pre", "text_link" or "text_mention"
+	Type string `json:"type"`
+	// Offset in UTF-16 code units to the start of the entity
+	Offset int `json:"offset"`
+	// Length of the entity in UTF-16 code units
+	Length int `json:"length"`
+	// URL opened for "text_link"
+	//
+	// optional
+	URL string `json:"url,omitempty"`
+	// User mentioned for "text_mention"
+	//
+	// optional
+	User *User `json:"user,omitempty"`
+	// Language of the text for "pre"
+	//
+	// optional
+	Language string `json:"language,omitempty"`
+}
+
 type SuccessfulPayment struct {
 	// Currency three-letter ISO 4217 currency code
 	// (see https://core.telegram.org/bots/payments#supported-currencies)
@@ -89,6 +287,77 @@ type SuccessfulPayment struct {
 	ProviderPaymentChargeID string `json:"provider_payment_charge_id"`
 }
 
+// PreCheckoutQuery is sent once the user confirmed the payment, it must be
+// answered within 10 seconds.
+type PreCheckoutQuery struct {
+	// ID unique query identifier
+	ID string `json:"id"`
+	// From user who sent the query
+	From *User `json:"from"`
+	// Currency three-letter ISO 4217 currency code
+	Currency string `json:"currency"`
+	// TotalAmount total price in the smallest units of the currency
+	TotalAmount int `json:"total_amount"`
+	// InvoicePayload bot specified invoice payload
+	InvoicePayload string `json:"invoice_payload"`
+	// ShippingOptionID identifier of the shipping option chosen by the user
+	//
+	// optional
+	ShippingOptionID string `json:"shipping_option_id,omitempty"`
+	// OrderInfo order info provided by the user
+	//
+	// optional
+	OrderInfo *OrderInfo `json:"order_info,omitempty"`
+}
+
+// AnswerPreCheckoutQuery approves or rejects a checkout.
+type AnswerPreCheckoutQuery struct {
+	PreCheckoutQueryID string `json:"pre_checkout_query_id"`
+	// OK is set when the goods are available and the checkout can proceed
+	OK bool `json:"ok"`
+	// ErrorMessage shown to the user when OK is false, e.g. "Sorry, somebody
+	// just bought the last of our amazing black T-shirts"
+	//
+	// optional
+	ErrorMessage string `json:"error_message,omitempty"`
+}
+
+// ShippingQuery is sent for flexible invoices once the user entered the
+// shipping address, it is answered by an answer_shipping_query event.
+type ShippingQuery struct {
+	// ID unique query identifier
+	ID string `json:"id"`
+	// From user who sent the query
+	From *User `json:"from"`
+	// InvoicePayload bot specified invoice payload
+	InvoicePayload string `json:"invoice_payload"`
+	// ShippingAddress user specified shipping address
+	ShippingAddress *ShippingAddress `json:"shipping_address"`
+}
+
+// AnswerShippingQuery offers the shipping options available for the
+// address, or an error message when delivery is not possible.
+type AnswerShippingQuery struct {
+	ShippingQueryID string `json:"shipping_query_id"`
+	// OK is set when delivery to the address is possible
+	OK bool `json:"ok"`
+	// ShippingOptions required when OK is set
+	//
+	// optional
+	ShippingOptions []ShippingOption `json:"shipping_options,omitempty"`
+	// ErrorMessage shown to the user when OK is false, e.g. "Sorry, delivery
+	// to your desired address is unavailable"
+	//
+	// optional
+	ErrorMessage string `json:"error_message,omitempty"`
+}
+
+type ShippingOption struct {
+	ID     string         `json:"id"`
+	Title  string         `json:"title"`
+	Prices []LabeledPrice `json:"prices"`
+}
+
 // OrderInfo represents information about an order.
 type OrderInfo struct {
 	// Name user name
@@ -163,20 +432,40 @@ type Invoice struct {
 type Message struct {
 	ID                   int                   `json:"id,omitempty"`
 	ReplyToMessageID     int                   `json:"reply_to_message_id,omitempty"`
+	MessageThreadID      int                   `json:"message_thread_id,omitempty"`
 	DisableNotification  bool                  `json:"disable_notification,omitempty"`
 	ProtectContent       bool                  `json:"protect_content,omitempty"`
 	Chat                 *Chat                 `json:"chat,omitempty"`
 	Text                 string                `json:"text,omitempty"`
+	Entities             []MessageEntity       `json:"entities,omitempty"`
+	ParseMode            string                `json:"parse_mode,omitempty"`
 	From                 *User                 `json:"from,omitempty"`
 	To                   *User                 `json:"to,omitempty"`
 	Photo                []*Photo              `json:"photo,omitempty"`
 	Audio                *Audio                `json:"audio,omitempty"`
 	Voice                *Voice                `json:"voice,omitempty"`
 	Video                *Video                `json:"video,omitempty"`
+	Document             *Document             `json:"document,omitempty"`
+	Sticker              *Sticker              `json:"sticker,omitempty"`
+	Animation            *Animation            `json:"animation,omitempty"`
+	VideoNote            *VideoNote            `json:"video_note,omitempty"`
+	Location             *Location             `json:"location,omitempty"`
+	Venue                *Venue                `json:"venue,omitempty"`
+	Contact              *Contact              `json:"contact,omitempty"`
+	Dice                 *Dice                 `json:"dice,omitempty"`
+	Poll                 *Poll                 `json:"poll,omitempty"`
+	Caption              string                `json:"caption,omitempty"`
+	CaptionEntities      []MessageEntity       `json:"caption_entities,omitempty"`
 	Invoice              *Invoice              `json:"invoice,omitempty"`
 	MediaGroup           *MediaGroup           `json:"media_group,omitempty"`
 	SuccessfulPayment    *SuccessfulPayment    `json:"successful_payment,omitempty"`
 	InlineKeyboardMarkup *InlineKeyboardMarkup `json:"inline_keyboard_markup,omitempty"`
+	// EditDate is the unix time the message was last edited at, set on
+	// edited_message and edited_channel_post events
+	EditDate int `json:"edit_date,omitempty"`
+	// IsChannelPost is set when the message was posted to a channel, such
+	// messages have no From
+	IsChannelPost bool `json:"is_channel_post,omitempty"`
 }
 
 type InlineKeyboardMarkup struct {
@@ -295,9 +584,326 @@ type CallbackQuery struct {
 	GameShortName string `json:"game_short_name,omitempty"`
 }
 
+// AnswerCallbackQuery answers a callback query, the client stops showing
+// the progress bar on the pressed button.
+type AnswerCallbackQuery struct {
+	CallbackQueryID string `json:"callback_query_id"`
+	// Text of the notification, 0-200 characters
+	//
+	// optional
+	Text string `json:"text,omitempty"`
+	// ShowAlert shows an alert instead of a notification at the top of the chat
+	//
+	// optional
+	ShowAlert bool `json:"show_alert,omitempty"`
+	// URL opened by the client, a game URL or a t.me/your_bot?start=XXXX link
+	//
+	// optional
+	URL string `json:"url,omitempty"`
+	// CacheTime seconds the answer may be cached client-side
+	//
+	// optional
+	CacheTime int `json:"cache_time,omitempty"`
+}
+
+// InlineQuery is sent when a user types @bot followed by a query in any
+// chat, it is answered by an answer_inline_query event.
+type InlineQuery struct {
+	ID   string `json:"id"`
+	From *User  `json:"from"`
+	// Query text of the query (up to 256 characters)
+	Query string `json:"query"`
+	// Offset of the results to be returned, the NextOffset of the previous
+	// answer
+	Offset string `json:"offset"`
+	// ChatType type of the chat the query was sent from, "sender" for the
+	// private chat with the bot
+	//
+	// optional
+	ChatType string `json:"chat_type,omitempty"`
+}
+
+// ChosenInlineResult is the result of an inline query chosen by a user and
+// sent to their chat partner, it requires inline feedback to be enabled
+// with @BotFather.
+type ChosenInlineResult struct {
+	ResultID string `json:"result_id"`
+	From     *User  `json:"from"`
+	// InlineMessageID identifier of the sent message, only set when it has
+	// an inline keyboard, it can be edited.
+	//
+	// optional
+	InlineMessageID string `json:"inline_message_id,omitempty"`
+	Query           string `json:"query"`
+}
+
+// AnswerInlineQuery answers an inline query with up to 50 results.
+type AnswerInlineQuery struct {
+	InlineQueryID string               `json:"inline_query_id"`
+	Results       []*InlineQueryResult `json:"results"`
+	// CacheTime seconds the result may be cached on the server, 300 by default
+	//
+	// optional
+	CacheTime int `json:"cache_time,omitempty"`
+	// IsPersonal caches the results for the user who sent the query only
+	//
+	// optional
+	IsPersonal bool `json:"is_personal,omitempty"`
+	// NextOffset is sent back as Offset when the user scrolls to get more
+	// results, empty when there are no more results.
+	//
+	// optional
+	NextOffset string `json:"next_offset,omitempty"`
+	// SwitchPMText shows a button above the results that opens the private
+	// chat with the bot and sends /start SwitchPMParameter.
+	//
+	// optional
+	SwitchPMText      string `json:"switch_pm_text,omitempty"`
+	SwitchPMParameter string `json:"switch_pm_parameter,omitempty"`
+}
+
+// InlineQueryResult is one result of an inline query. Type is article,
+// photo, gif, video or document; media results are sent from URL, or from
+// FileID for files already on the Telegram servers.
+type InlineQueryResult struct {
+	Type string `json:"type"`
+	// ID unique identifier of the result, 1-64 bytes
+	ID string `json:"id"`
+	// Title required for article, video and document results
+	//
+	// optional
+	Title string `json:"title,omitempty"`
+	// Description shown under the title
+	//
+	// optional
+	Description string `json:"description,omitempty"`
+	// URL of the media, or of the article
+	//
+	// optional
+	URL string `json:"url,omitempty"`
+	// FileID of a media already on the Telegram servers, used instead of URL
+	//
+	// optional
+	FileID string `json:"file_id,omitempty"`
+	// MimeType of the video or document URL
+	//
+	// optional
+	MimeType string `json:"mime_type,omitempty"`
+	// ThumbURL of the thumbnail, required for gif results sent from URL
+	//
+	// optional
+	ThumbURL string `json:"thumb_url,omitempty"`
+	Width    int    `json:"width,omitempty"`
+	Height   int    `json:"height,omitempty"`
+	Duration int    `json:"duration,omitempty"`
+	// Caption of the media
+	//
+	// optional
+	Caption string `json:"caption,omitempty"`
+	// MessageText is sent when the result is chosen, required for article
+	// results, media results send the media otherwise
+	//
+	// optional
+	MessageText           string `json:"message_text,omitempty"`
+	DisableWebPagePreview bool   `json:"disable_web_page_preview,omitempty"`
+	// InlineKeyboardMarkup attached to the sent message
+	//
+	// optional
+	InlineKeyboardMarkup *InlineKeyboardMarkup `json:"inline_keyboard_markup,omitempty"`
+}
+
+// ChatMemberUpdated is sent on my_chat_member events when the bot itself
+// was added, removed, promoted or restricted, and on chat_member events
+// for other members.
+type ChatMemberUpdated struct {
+	Chat *Chat `json:"chat"`
+	// From performer of the action, which resulted in the change
+	From *User `json:"from"`
+	// Date the change was done in Unix time
+	Date          int         `json:"date"`
+	OldChatMember *ChatMember `json:"old_chat_member"`
+	NewChatMember *ChatMember `json:"new_chat_member"`
+	// InviteLink used by the user to join the chat
+	//
+	// optional
+	InviteLink *ChatInviteLink `json:"invite_link,omitempty"`
+}
+
+// ChatMember is the status and the permissions of a member of a chat.
+type ChatMember struct {
+	User *User `json:"user"`
+	// Status is one of "creator", "administrator", "member", "restricted",
+	// "left" or "kicked"
+	Status string `json:"status"`
+	// CustomTitle of the administrator or the owner
+	//
+	// optional
+	CustomTitle string `json:"custom_title,omitempty"`
+	IsAnonymous bool   `json:"is_anonymous,omitempty"`
+	// UntilDate the restrictions or the ban will be lifted, in Unix time
+	//
+	// optional
+	UntilDate int64 `json:"until_date,omitempty"`
+	// IsMember is set when a restricted user is a member of the chat
+	IsMember bool `json:"is_member,omitempty"`
+
+	// administrator rights
+	CanBeEdited         bool `json:"can_be_edited,omitempty"`
+	CanManageChat       bool `json:"can_manage_chat,omitempty"`
+	CanPostMessages     bool `json:"can_post_messages,omitempty"`
+	CanEditMessages     bool `json:"can_edit_messages,omitempty"`
+	CanDeleteMessages   bool `json:"can_delete_messages,omitempty"`
+	CanManageVideoChats bool `json:"can_manage_video_chats,omitempty"`
+	CanRestrictMembers  bool `json:"can_restrict_members,omitempty"`
+	CanPromoteMembers   bool `json:"can_promote_members,omitempty"`
+	CanChangeInfo       bool `json:"can_change_info,omitempty"`
+	CanInviteUsers      bool `json:"can_invite_users,omitempty"`
+	CanPinMessages      bool `json:"can_pin_messages,omitempty"`
+
+	// restricted member permissions
+	CanSendMessages       bool `json:"can_send_messages,omitempty"`
+	CanSendMediaMessages  bool `json:"can_send_media_messages,omitempty"`
+	CanSendPolls          bool `json:"can_send_polls,omitempty"`
+	CanSendOtherMessages  bool `json:"can_send_other_messages,omitempty"`
+	CanAddWebPagePreviews bool `json:"can_add_web_page_previews,omitempty"`
+}
+
+// ChatJoinRequest is sent when a user asks to join a chat the bot can
+// invite users to, it is answered by an approve_chat_join_request or
+// decline_chat_join_request event.
+type ChatJoinRequest struct {
+	Chat *Chat `json:"chat"`
+	// From user that sent the join request
+	From *User `json:"from"`
+	// Date the request was sent in Unix time
+	Date int `json:"date"`
+	// Bio of the user
+	//
+	// optional
+	Bio string `json:"bio,omitempty"`
+	// InviteLink used by the user to send the join request
+	//
+	// optional
+	InviteLink *ChatInviteLink `json:"invite_link,omitempty"`
+}
+
+type ChatInviteLink struct {
+	InviteLink              string `json:"invite_link"`
+	Creator                 *User  `json:"creator"`
+	CreatesJoinRequest      bool   `json:"creates_join_request,omitempty"`
+	IsPrimary               bool   `json:"is_primary"`
+	IsRevoked               bool   `json:"is_revoked"`
+	Name                    string `json:"name,omitempty"`
+	ExpireDate              int    `json:"expire_date,omitempty"`
+	MemberLimit             int    `json:"member_limit,omitempty"`
+	PendingJoinRequestCount int    `json:"pending_join_request_count,omitempty"`
+}
+
+// ChatJoinRequestAnswer approves or declines the join request of UserID,
+// depending on the event type.
+type ChatJoinRequestAnswer struct {
+	ChatID int64 `json:"chat_id"`
+	UserID int64 `json:"user_id"`
+}
+
 type ChatAction struct {
 	ChatID int64  `json:"chat_id"`
 	Action string `json:"action"`
+	// MessageThreadID forum topic the action is shown in
+	//
+	// optional
+	MessageThreadID int `json:"message_thread_id,omitempty"`
+}
+
+// EditMessage identifies a previously sent message, either by ChatID and
+// MessageID or by InlineMessageID for messages sent in inline mode.
+type EditMessage struct {
+	ChatID          int64  `json:"chat_id,omitempty"`
+	MessageID       int    `json:"message_id,omitempty"`
+	InlineMessageID string `json:"inline_message_id,omitempty"`
+	// InlineKeyboardMarkup replaces the inline keyboard of the message,
+	// it is removed when empty.
+	//
+	// optional
+	InlineKeyboardMarkup *InlineKeyboardMarkup `json:"inline_keyboard_markup,omitempty"`
+}
+
+type EditMessageText struct {
+	EditMessage
+	Text string `json:"text"`
+	// Entities in the text, which can be specified instead of ParseMode
+	//
+	// optional
+	Entities []MessageEntity `json:"entities,omitempty"`
+	// ParseMode HTML, MarkdownV2 or Markdown, the default format of the
+	// connector when neither ParseMode nor Entities are set
+	//
+	// optional
+	ParseMode             string `json:"parse_mode,omitempty"`
+	DisableWebPagePreview bool   `json:"disable_web_page_preview,omitempty"`
+}
+
+type EditMessageCaption struct {
+	EditMessage
+	Caption string `json:"caption"`
+	// CaptionEntities in the caption, which can be specified instead of ParseMode
+	//
+	// optional
+	CaptionEntities []MessageEntity `json:"caption_entities,omitempty"`
+	// ParseMode HTML, MarkdownV2 or Markdown, the default format of the
+	// connector when neither ParseMode nor CaptionEntities are set
+	//
+	// optional
+	ParseMode string `json:"parse_mode,omitempty"`
+}
+
+type EditMessageReplyMarkup struct {
+	EditMessage
+}
+
+type EditMessageMedia struct {
+	EditMessage
+	Media BaseInputMedia `json:"media"`
+}
+
+// EditMessageLiveLocation moves a live location until its LivePeriod
+// expires or it is stopped.
+type EditMessageLiveLocation struct {
+	EditMessage
+	Latitude             float64 `json:"latitude"`
+	Longitude            float64 `json:"longitude"`
+	HorizontalAccuracy   float64 `json:"horizontal_accuracy,omitempty"`
+	Heading              int     `json:"heading,omitempty"`
+	ProximityAlertRadius int     `json:"proximity_alert_radius,omitempty"`
+}
+
+type StopMessageLiveLocation struct {
+	EditMessage
+}
+
+// StopPoll closes a poll sent by the bot, it can't be an inline message.
+type StopPoll struct {
+	EditMessage
+}
+
+// MessageChunk is an increment of a streamed reply, chunks with the same
+// StreamID are appended to the same message.
+type MessageChunk struct {
+	StreamID         string `json:"stream_id"`
+	ChatID           int64  `json:"chat_id"`
+	ReplyToMessageID int    `json:"reply_to_message_id,omitempty"`
+	// MessageThreadID forum topic the stream is sent to
+	MessageThreadID int `json:"message_thread_id,omitempty"`
+	// Text appended to the stream
+	Text string `json:"text"`
+	// Done is set on the last chunk of the stream
+	Done bool `json:"done,omitempty"`
+}
+
+// DeleteMessage deletes a message, messages sent in inline mode can't be deleted.
+type DeleteMessage struct {
+	ChatID    int64 `json:"chat_id"`
+	MessageID int   `json:"message_id"`
 }
 
 type MediaGroup struct {
@@ -329,5 +935,36 @@ type BaseInputMedia struct {
 	// which can be specified instead of parse_mode
 	//
 	// optional
-	// CaptionEntities []MessageEntity `json:"caption_entities,omitempty"`
+	CaptionEntities []MessageEntity `json:"caption_entities,omitempty"`
+}
+
+// DeliveryReceipt reports the outcome of an outbox event, it is published to
+// the inbox as a message_sent or message_failed event.
+type DeliveryReceipt struct {
+	// CorrelationID is the id of the outbox event
+	CorrelationID string `json:"correlation_id"`
+	// Type of the outbox event
+	Type string `json:"type"`
+	// Messages sent or edited by the outbox event
+	//
+	// optional
+	Messages []*Message `json:"messages,omitempty"`
+	// Error why the outbox event failed
+	//
+	// optional
+	Error *DeliveryError `json:"error,omitempty"`
+}
+
+type DeliveryError struct {
+	// Code is the Telegram error code, 0 when the request did not reach Telegram
+	Code        int    `json:"code,omitempty"`
+	Description string `json:"description"`
+	// RetryAfter seconds left to wait before the request can be repeated
+	//
+	// optional
+	RetryAfter int `json:"retry_after,omitempty"`
+	// MigrateToChatID the group has been migrated to a supergroup with this id
+	//
+	// optional
+	MigrateToChatID int64 `json:"migrate_to_chat_id,omitempty"`
 }
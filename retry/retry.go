@@ -0,0 +1,84 @@
+package retry
+
+import (
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/botaas/telegram-bot-connector/event"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Policy decides how often and when a failed event is retried.
+type Policy struct {
+	// MaxAttempts is the number of attempts, including the first one
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry, doubled on each retry
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between two attempts
+	MaxDelay time.Duration
+}
+
+// Retry reports whether err is worth another attempt after attempts attempts.
+func (p Policy) Retry(attempts int, err error) bool {
+	return attempts < p.MaxAttempts && !IsPermanent(err)
+}
+
+// Backoff returns the delay after attempts attempts, exponential with full
+// jitter.
+func (p Policy) Backoff(attempts int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempts && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(delay)) + 1)
+}
+
+// IsPermanent reports whether err will occur again however often the request
+// is retried: Telegram rejecting the request itself, e.g. "Forbidden: bot was
+// blocked by the user" or "Bad Request: chat not found", or a payload that
+// can't be decoded or handled. Network failures and Telegram server errors are transient.
+// An event that was partially sent is not retried either, whatever the error.
+func IsPermanent(err error) bool {
+	var partialErr *event.PartialSendError
+	if errors.As(err, &partialErr) {
+		return true
+	}
+
+	var tgErr *tgbotapi.Error
+	if errors.As(err, &tgErr) {
+		if tgErr.RetryAfter > 0 {
+			return false
+		}
+
+		switch {
+		case tgErr.Code == 400 || tgErr.Code == 401 || tgErr.Code == 403 || tgErr.Code == 404:
+			return true
+		case tgErr.Code >= 500:
+			return false
+		}
+
+		// errors of uploads come without code
+		return strings.HasPrefix(tgErr.Message, "Bad Request") ||
+			strings.HasPrefix(tgErr.Message, "Unauthorized") ||
+			strings.HasPrefix(tgErr.Message, "Forbidden") ||
+			strings.HasPrefix(tgErr.Message, "Not Found")
+	}
+
+	if errors.Is(err, event.ErrNoEventHandler) || errors.Is(err, event.ErrInvalidPayload) {
+		return true
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	return errors.As(err, &syntaxErr) || errors.As(err, &typeErr)
+}
@@ -0,0 +1,76 @@
+package retry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/botaas/telegram-bot-connector/event"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestIsPermanent(t *testing.T) {
+	var syntaxErr error = json.Unmarshal([]byte("{"), &struct{}{})
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"bad request", &tgbotapi.Error{Code: 400, Message: "Bad Request: chat not found"}, true},
+		{"blocked", &tgbotapi.Error{Code: 403, Message: "Forbidden: bot was blocked by the user"}, true},
+		{"too many requests", &tgbotapi.Error{Code: 429, Message: "Too Many Requests", ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 3}}, false},
+		{"server error", &tgbotapi.Error{Code: 502, Message: "Bad Gateway"}, false},
+		{"upload bad request", &tgbotapi.Error{Message: "Bad Request: wrong file identifier"}, true},
+		{"invalid payload", fmt.Errorf("%w: stream_id is required", event.ErrInvalidPayload), true},
+		{"no handler", event.ErrNoEventHandler, true},
+		{"undecodable payload", syntaxErr, true},
+		{"network", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, false},
+		{"partially sent", &event.PartialSendError{Sent: 1, Err: &tgbotapi.Error{Code: 502, Message: "Bad Gateway"}}, true},
+	}
+
+	for _, tt := range tests {
+		if got := IsPermanent(tt.err); got != tt.want {
+			t.Errorf("%s: IsPermanent = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPolicyRetry(t *testing.T) {
+	p := Policy{MaxAttempts: 3}
+	transient := &tgbotapi.Error{Code: 500, Message: "Internal Server Error"}
+
+	if !p.Retry(1, transient) || !p.Retry(2, transient) {
+		t.Error("transient error not retried before MaxAttempts")
+	}
+	if p.Retry(3, transient) {
+		t.Error("transient error retried after MaxAttempts")
+	}
+	if p.Retry(1, &tgbotapi.Error{Code: 400, Message: "Bad Request"}) {
+		t.Error("permanent error retried")
+	}
+}
+
+func TestPolicyBackoff(t *testing.T) {
+	p := Policy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	for attempts := 1; attempts <= 10; attempts++ {
+		limit := p.BaseDelay << (attempts - 1)
+		if limit > p.MaxDelay {
+			limit = p.MaxDelay
+		}
+
+		for i := 0; i < 100; i++ {
+			if d := p.Backoff(attempts); d <= 0 || d > limit {
+				t.Fatalf("Backoff(%d) = %v, want in (0, %v]", attempts, d, limit)
+			}
+		}
+	}
+
+	if d := (Policy{}).Backoff(1); d != 0 {
+		t.Errorf("Backoff without delay = %v, want 0", d)
+	}
+}
@@ -0,0 +1,192 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// chats idle for idleTimeout forget their limiter
+	idleTimeout = time.Minute
+
+	// NoChat is the chat id of messages not sent to a chat, e.g. answers to
+	// callback or inline queries, they are only held to the global rate.
+	NoChat int64 = 0
+)
+
+// Scheduler paces outbound messages under Telegram's limits: a global rate,
+// a rate per private chat and a rate per group or channel. A chat can be
+// paused, e.g. after a 429 Too Many Requests, without holding back others.
+type Scheduler struct {
+	global  *rate.Limiter
+	private rate.Limit
+	group   rate.Limit
+
+	mu        sync.Mutex
+	chats     map[int64]*chat
+	lastSweep time.Time
+}
+
+type chat struct {
+	limiter  *rate.Limiter
+	lastUsed time.Time
+
+	// paused until resumeAt and until parked is drained
+	paused   bool
+	resumeAt time.Time
+	parked   []func()
+}
+
+// New returns a Scheduler allowing global messages per second overall,
+// private messages per second to a private chat and group messages per
+// second to a group or channel.
+func New(global rate.Limit, private rate.Limit, group rate.Limit) *Scheduler {
+	burst := int(global)
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &Scheduler{
+		global:  rate.NewLimiter(global, burst),
+		private: private,
+		group:   group,
+		chats:   map[int64]*chat{},
+	}
+}
+
+// Wait blocks until the global rate allows a message.
+func (s *Scheduler) Wait(ctx context.Context) error {
+	return s.global.Wait(ctx)
+}
+
+// Schedule calls fn right away when chatID may be sent a message, else it
+// parks fn, parked functions are called in order once the chat is allowed
+// again. A chat over its rate is paused until its next message is allowed,
+// so that it holds back none of the other chats.
+func (s *Scheduler) Schedule(chatID int64, fn func()) {
+	if chatID == NoChat {
+		fn()
+		return
+	}
+
+	s.mu.Lock()
+	c := s.chat(chatID)
+	if c.paused {
+		c.parked = append(c.parked, fn)
+		s.mu.Unlock()
+		return
+	}
+
+	now := time.Now()
+	if d := c.delay(now); d > 0 {
+		c.paused = true
+		c.resumeAt = now.Add(d)
+		c.parked = []func(){fn}
+		s.mu.Unlock()
+
+		go s.resume(c)
+		return
+	}
+	s.mu.Unlock()
+
+	fn()
+}
+
+// Pause holds back chatID for d, fn is the call that has to be retried, it
+// runs before the ones parked meanwhile. Without chat only fn is delayed.
+func (s *Scheduler) Pause(chatID int64, d time.Duration, fn func()) {
+	if chatID == NoChat {
+		time.AfterFunc(d, fn)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := s.chat(chatID)
+	if resumeAt := time.Now().Add(d); resumeAt.After(c.resumeAt) {
+		c.resumeAt = resumeAt
+	}
+	c.parked = append([]func(){fn}, c.parked...)
+
+	if !c.paused {
+		c.paused = true
+		go s.resume(c)
+	}
+}
+
+// resume drains the functions parked for c once its pause is over, at the
+// rate of the chat.
+func (s *Scheduler) resume(c *chat) {
+	for {
+		s.mu.Lock()
+		if wait := time.Until(c.resumeAt); wait > 0 {
+			s.mu.Unlock()
+			time.Sleep(wait)
+			continue
+		}
+
+		if len(c.parked) == 0 {
+			c.paused = false
+			s.mu.Unlock()
+			return
+		}
+
+		now := time.Now()
+		if d := c.delay(now); d > 0 {
+			c.resumeAt = now.Add(d)
+			s.mu.Unlock()
+			continue
+		}
+
+		fn := c.parked[0]
+		c.parked = c.parked[1:]
+		s.mu.Unlock()
+
+		fn()
+	}
+}
+
+// delay takes the next message of c from its limiter when it is allowed at
+// now, else it returns how long until it is.
+func (c *chat) delay(now time.Time) time.Duration {
+	r := c.limiter.ReserveN(now, 1)
+	d := r.DelayFrom(now)
+	if d > 0 {
+		r.CancelAt(now)
+	}
+	return d
+}
+
+// chat returns the state of chatID, it must be called with s.mu held.
+func (s *Scheduler) chat(chatID int64) *chat {
+	now := time.Now()
+	if now.Sub(s.lastSweep) > idleTimeout {
+		for id, c := range s.chats {
+			if !c.paused && now.Sub(c.lastUsed) > idleTimeout {
+				delete(s.chats, id)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	c, exist := s.chats[chatID]
+	if !exist {
+		// private chats have positive ids, groups and channels negative ones
+		limit := s.private
+		if chatID < 0 {
+			limit = s.group
+		}
+
+		c = &chat{
+			limiter: rate.NewLimiter(limit, 1),
+		}
+		s.chats[chatID] = c
+	}
+	c.lastUsed = now
+
+	return c
+}
@@ -0,0 +1,149 @@
+package scheduler
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// ran records the names of the functions it returns once they ran.
+type ran struct {
+	mu    sync.Mutex
+	names []string
+	wg    sync.WaitGroup
+}
+
+func (r *ran) fn(name string) func() {
+	r.wg.Add(1)
+	return func() {
+		r.mu.Lock()
+		r.names = append(r.names, name)
+		r.mu.Unlock()
+		r.wg.Done()
+	}
+}
+
+func (r *ran) Names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]string(nil), r.names...)
+}
+
+func TestScheduleLimitsEachChat(t *testing.T) {
+	s := New(rate.Inf, rate.Every(200*time.Millisecond), rate.Every(200*time.Millisecond))
+	r := &ran{}
+
+	start := time.Now()
+	s.Schedule(1, r.fn("first"))
+	s.Schedule(1, r.fn("second"))
+	if names := r.Names(); len(names) != 1 || names[0] != "first" {
+		t.Fatalf("ran %v right away, want only the first message of the chat", names)
+	}
+
+	// other chats have their own limiter and are not held back
+	s.Schedule(2, r.fn("other"))
+	s.Schedule(-3, r.fn("group"))
+	if names := r.Names(); len(names) != 3 {
+		t.Fatalf("ran %v right away, want the messages to the other chats too", names)
+	}
+
+	r.wg.Wait()
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("second message to the same chat ran after %v, want about 200ms", elapsed)
+	}
+	if names := r.Names(); names[3] != "second" {
+		t.Errorf("ran %v, want the second message of the chat last", names)
+	}
+}
+
+func TestScheduleKeepsTheOrderOfAThrottledChat(t *testing.T) {
+	s := New(rate.Inf, rate.Every(20*time.Millisecond), rate.Inf)
+	r := &ran{}
+
+	want := []string{"1", "2", "3", "4"}
+	for _, name := range want {
+		s.Schedule(1, r.fn(name))
+	}
+	r.wg.Wait()
+
+	names := r.Names()
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("ran %v, want %v", names, want)
+		}
+	}
+}
+
+func TestScheduleWithoutChatOnlyAppliesTheGlobalRate(t *testing.T) {
+	s := New(rate.Inf, rate.Every(time.Second), rate.Every(time.Second))
+	r := &ran{}
+
+	for i := 0; i < 10; i++ {
+		s.Schedule(NoChat, r.fn("answer"))
+	}
+	if n := len(r.Names()); n != 10 {
+		t.Errorf("ran %d messages without chat right away, want 10", n)
+	}
+}
+
+func TestPauseRunsRetryBeforeParked(t *testing.T) {
+	s := New(rate.Inf, rate.Inf, rate.Inf)
+	r := &ran{}
+
+	start := time.Now()
+	s.Pause(1, 100*time.Millisecond, r.fn("retry"))
+	s.Schedule(1, r.fn("first"))
+	s.Schedule(1, r.fn("second"))
+	if names := r.Names(); len(names) != 0 {
+		t.Fatalf("ran %v while the chat is paused", names)
+	}
+
+	other := false
+	s.Schedule(2, func() { other = true })
+	if !other {
+		t.Fatal("another chat was held back by the pause")
+	}
+
+	r.wg.Wait()
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("parked functions ran after %v, before the pause was over", elapsed)
+	}
+
+	want := []string{"retry", "first", "second"}
+	names := r.Names()
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("order = %v, want %v", names, want)
+		}
+	}
+
+	// the pause is over once the parked functions are drained
+	time.Sleep(10 * time.Millisecond)
+	after := false
+	s.Schedule(1, func() { after = true })
+	if !after {
+		t.Error("chat still held back after the pause was over")
+	}
+}
+
+func TestPauseWithoutChatHoldsBackNothingElse(t *testing.T) {
+	s := New(rate.Inf, rate.Inf, rate.Inf)
+
+	retried := make(chan struct{})
+	s.Pause(NoChat, 50*time.Millisecond, func() { close(retried) })
+
+	ran := false
+	s.Schedule(NoChat, func() { ran = true })
+	if !ran {
+		t.Error("message without chat held back by a pause")
+	}
+
+	select {
+	case <-retried:
+	case <-time.After(time.Second):
+		t.Fatal("paused function without chat was not retried")
+	}
+}
@@ -0,0 +1,79 @@
+package transcriber
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// fileArg is replaced by the path of the voice note in the command arguments
+const fileArg = "{file}"
+
+// command transcribes with a local command-line engine printing the text on
+// its standard output.
+type command struct {
+	name string
+	args []string
+}
+
+// NewCommand returns a Transcriber running name with args. An argument equal
+// to {file} is replaced by the path of the voice note, without one the voice
+// note is written to the standard input of the command.
+func NewCommand(name string, args ...string) Transcriber {
+	return &command{
+		name: name,
+		args: args,
+	}
+}
+
+func (t *command) Transcribe(ctx context.Context, audio io.Reader, name string) (string, error) {
+	args := make([]string, len(t.args))
+	copy(args, t.args)
+
+	var stdin io.Reader = audio
+	for _, arg := range args {
+		if arg != fileArg {
+			continue
+		}
+
+		f, err := os.CreateTemp("", "voice-*.ogg")
+		if err != nil {
+			return "", err
+		}
+		defer os.Remove(f.Name())
+
+		_, err = io.Copy(f, audio)
+		f.Close()
+		if err != nil {
+			return "", err
+		}
+
+		for i := range args {
+			if args[i] == fileArg {
+				args[i] = f.Name()
+			}
+		}
+		stdin = nil
+		break
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, t.name, args...)
+	cmd.Stdin = stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil {
+		if stderr.Len() > 0 {
+			return "", errors.New(strings.TrimSpace(stderr.String()))
+		}
+		return "", err
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,112 @@
+package transcriber
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os/exec"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type TranscriptionResponse struct {
+	Text string `json:"text"`
+}
+
+type ErrorResponse struct {
+	Error struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+		Param   string `json:"param"`
+		Code    string `json:"code"`
+	} `json:"error"`
+}
+
+// openAI transcribes with an OpenAI compatible /audio/transcriptions endpoint,
+// the OpenAI API itself or e.g. a local whisper server.
+type openAI struct {
+	baseURL string
+	model   string
+	apiKey  string
+}
+
+// NewOpenAI returns a Transcriber posting to baseURL + "/audio/transcriptions",
+// e.g. https://api.openai.com/v1 with model whisper-1.
+func NewOpenAI(baseURL string, model string, apiKey string) Transcriber {
+	return &openAI{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		model:   model,
+		apiKey:  apiKey,
+	}
+}
+
+func (t *openAI) Transcribe(ctx context.Context, audio io.Reader, name string) (string, error) {
+	cmd := exec.CommandContext(ctx, "ffmpeg", "-i", "pipe:0", "-f", "mp3", "pipe:1")
+	cmd.Stdin = audio
+
+	reader, writer := io.Pipe()
+	cmd.Stdout = writer
+
+	go func() {
+		err := cmd.Run()
+		if err != nil {
+			log.Printf("ffmpeg error: %v", err)
+		}
+		writer.CloseWithError(err)
+	}()
+
+	var requestBody bytes.Buffer
+	multipartWriter := multipart.NewWriter(&requestBody)
+	err := multipartWriter.WriteField("model", t.model)
+	if err != nil {
+		return "", err
+	}
+
+	fileWriter, err := multipartWriter.CreateFormFile("file", name+".mp3")
+	if err != nil {
+		return "", err
+	}
+	if _, err = io.Copy(fileWriter, reader); err != nil {
+		return "", err
+	}
+	if err := multipartWriter.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", t.baseURL+"/audio/transcriptions", &requestBody)
+	if err != nil {
+		return "", err
+	}
+	if len(t.apiKey) > 0 {
+		req.Header.Set("Authorization", "Bearer "+t.apiKey)
+	}
+	req.Header.Set("Content-Type", multipartWriter.FormDataContentType())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errorResponse ErrorResponse
+		json.NewDecoder(resp.Body).Decode(&errorResponse)
+		if len(errorResponse.Error.Message) == 0 {
+			return "", errors.New(resp.Status)
+		}
+		return "", errors.New(errorResponse.Error.Message)
+	}
+
+	var transcriptionResponse TranscriptionResponse
+	err = json.NewDecoder(resp.Body).Decode(&transcriptionResponse)
+	if err != nil {
+		return "", err
+	}
+
+	return transcriptionResponse.Text, nil
+}
@@ -0,0 +1,31 @@
+package transcriber
+
+import (
+	"context"
+	"io"
+)
+
+// Transcriber turns a voice note into text.
+type Transcriber interface {
+	// Transcribe reads the audio, an OGG/Opus voice note named name, and
+	// returns its text.
+	Transcribe(ctx context.Context, audio io.Reader, name string) (string, error)
+}
+
+type noop struct{}
+
+// NewNoop returns a Transcriber that leaves voice notes untranscribed.
+func NewNoop() Transcriber {
+	return noop{}
+}
+
+// IsNoop reports whether t leaves voice notes untranscribed, voice notes
+// then don't need to be downloaded.
+func IsNoop(t Transcriber) bool {
+	_, ok := t.(noop)
+	return t == nil || ok
+}
+
+func (noop) Transcribe(ctx context.Context, audio io.Reader, name string) (string, error) {
+	return "", nil
+}
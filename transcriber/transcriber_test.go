@@ -0,0 +1,134 @@
+package transcriber
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNoop(t *testing.T) {
+	if !IsNoop(NewNoop()) || !IsNoop(nil) {
+		t.Error("noop transcriber not reported as noop")
+	}
+	if IsNoop(NewCommand("cat")) {
+		t.Error("command transcriber reported as noop")
+	}
+
+	text, err := NewNoop().Transcribe(context.Background(), strings.NewReader("ogg"), "v")
+	if err != nil || text != "" {
+		t.Errorf("noop transcribed %q, %v, want nothing", text, err)
+	}
+}
+
+func TestCommandReadsTheAudioFromItsStandardInput(t *testing.T) {
+	text, err := NewCommand("cat").Transcribe(context.Background(), strings.NewReader(" hello \n"), "v")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "hello" {
+		t.Errorf("text = %q, want the trimmed output of the command", text)
+	}
+}
+
+func TestCommandReplacesTheFileArgument(t *testing.T) {
+	text, err := NewCommand("cat", fileArg).Transcribe(context.Background(), strings.NewReader("hello"), "v")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "hello" {
+		t.Errorf("text = %q, want the content of the voice file", text)
+	}
+}
+
+func TestCommandFailsWithItsStandardError(t *testing.T) {
+	_, err := NewCommand("sh", "-c", "echo no speech found >&2; exit 1").Transcribe(context.Background(), strings.NewReader(""), "v")
+	if err == nil || err.Error() != "no speech found" {
+		t.Errorf("error = %v, want the standard error of the command", err)
+	}
+}
+
+func TestCommandIsBoundedByTheContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := NewCommand("sleep", "10").Transcribe(ctx, strings.NewReader(""), "v")
+	if err == nil {
+		t.Fatal("command outlived its context")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("command stopped after %v, want the context timeout", elapsed)
+	}
+}
+
+// fakeFFmpeg puts an ffmpeg copying its input to its output first in PATH.
+func fakeFFmpeg(t *testing.T) {
+	t.Helper()
+
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "ffmpeg"), []byte("#!/bin/sh\ncat\n"), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestOpenAIPostsTheConvertedAudio(t *testing.T) {
+	fakeFFmpeg(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/audio/transcriptions" {
+			t.Errorf("posted to %s, want /v1/audio/transcriptions", r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer key" {
+			t.Errorf("Authorization = %q, want the api key", auth)
+		}
+		if model := r.FormValue("model"); model != "whisper-1" {
+			t.Errorf("model = %q, want whisper-1", model)
+		}
+
+		f, header, err := r.FormFile("file")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer f.Close()
+		audio, _ := io.ReadAll(f)
+		if header.Filename != "v.mp3" || string(audio) != "ogg" {
+			t.Errorf("file %s = %q, want v.mp3 with the converted audio", header.Filename, audio)
+		}
+
+		_ = json.NewEncoder(w).Encode(TranscriptionResponse{Text: "hello"})
+	}))
+	defer srv.Close()
+
+	text, err := NewOpenAI(srv.URL+"/v1/", "whisper-1", "key").Transcribe(context.Background(), strings.NewReader("ogg"), "v")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "hello" {
+		t.Errorf("text = %q, want hello", text)
+	}
+}
+
+func TestOpenAIFailsWithTheErrorOfTheAPI(t *testing.T) {
+	fakeFFmpeg(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = io.WriteString(w, `{"error": {"message": "Invalid file format."}}`)
+	}))
+	defer srv.Close()
+
+	_, err := NewOpenAI(srv.URL, "whisper-1", "").Transcribe(context.Background(), strings.NewReader("ogg"), "v")
+	if err == nil || err.Error() != "Invalid file format." {
+		t.Errorf("error = %v, want the message of the API", err)
+	}
+}